@@ -1,8 +1,14 @@
 package pirsch
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,13 +23,76 @@ const (
 		GROUP BY "%s"
 		ORDER BY visitors DESC, "%s" ASC
 		%s`
+	relativePlatformQuery = `"platform_desktop" / greatest("platform_desktop" + "platform_mobile" + "platform_tablet" + "platform_unknown", 1) AS relative_platform_desktop,
+		"platform_mobile" / greatest("platform_desktop" + "platform_mobile" + "platform_tablet" + "platform_unknown", 1) AS relative_platform_mobile,
+		"platform_tablet" / greatest("platform_desktop" + "platform_mobile" + "platform_tablet" + "platform_unknown", 1) AS relative_platform_tablet,
+		"platform_unknown" / greatest("platform_desktop" + "platform_mobile" + "platform_tablet" + "platform_unknown", 1) AS relative_platform_unknown`
+
+	// sessionBounceQuery is true for hits grouped by session if the session bounced, which means a single distinct path was viewed.
+	// Reloading the same page doesn't count as a second page view.
+	sessionBounceQuery = `uniq("path") = 1`
+)
+
+const (
+	// TrendOther is the name used to summarize all values that are not part of the top values of a trend.
+	TrendOther = "other"
+
+	// UTMNotSet is the name used for an empty utm source or medium in Analyzer.UTMSourceMedium.
+	UTMNotSet = "(not set)"
+
+	defaultTrendLimit         = 5
+	defaultAnomalyWindow      = 7
+	defaultAnomalySigma       = 3
+	maxTrendCountries         = 10
+	maxIntervals              = 10_000
+	maxHitsLimit              = 1000
+	topSessionsLimit          = 10
+	pagesStreamBatchSize      = 1000
+	sessionsPerVisitorBuckets = 3
+	maxVisitDepth             = 10
 )
 
 var (
 	// ErrNoPeriodOrDay is returned in case no period or day was specified to calculate the growth rate.
 	ErrNoPeriodOrDay = errors.New("no period or day specified")
+
+	// ErrInvalidInterval is returned in case the interval to group visitors is less than a second.
+	ErrInvalidInterval = errors.New("invalid interval")
+
+	// ErrTooManyIntervals is returned in case the selected period would be split into too many intervals.
+	ErrTooManyIntervals = errors.New("too many intervals")
+
+	// ErrTooManyCountries is returned in case more than 10 countries are requested for the country trend.
+	ErrTooManyCountries = errors.New("too many countries")
+
+	// ErrInvalidWeights is returned in case a weight for the engagement score is negative.
+	ErrInvalidWeights = errors.New("invalid weights")
 )
 
+type dayHourStats struct {
+	Day      time.Time
+	Hour     int
+	Visitors int
+}
+
+type periodStats struct {
+	Period   time.Time
+	Visitors int
+}
+
+type pathDayStats struct {
+	Day      time.Time
+	Path     string
+	Visitors int
+	Views    int
+}
+
+type trendDayStats struct {
+	Day      time.Time
+	Name     string
+	Visitors int
+}
+
 type growthStats struct {
 	Visitors int `json:"visitors"`
 	Views    int `json:"views"`
@@ -33,28 +102,113 @@ type growthStats struct {
 
 // Analyzer provides an interface to analyze statistics.
 type Analyzer struct {
-	store Store
+	store            Store
+	referrerChannels map[string]string
+	m                sync.RWMutex
 }
 
 // NewAnalyzer returns a new Analyzer for given Store.
 func NewAnalyzer(store Store) *Analyzer {
 	return &Analyzer{
-		store,
+		store:            store,
+		referrerChannels: defaultReferrerChannels,
 	}
 }
 
+// SetReferrerChannels sets the referrer hostnames mapped to the channels used by Analyzer.Channels.
+// A hostname matches itself and all of its subdomains, so "google.com" matches "www.google.com" too.
+// If more than one hostname matches, the longest (most specific) one is used.
+// The map is copied, use DefaultReferrerChannels to extend the defaults.
+func (analyzer *Analyzer) SetReferrerChannels(channels map[string]string) {
+	channels = copyReferrerChannels(channels)
+	analyzer.m.Lock()
+	defer analyzer.m.Unlock()
+	analyzer.referrerChannels = channels
+}
+
+func (analyzer *Analyzer) getReferrerChannels() map[string]string {
+	analyzer.m.RLock()
+	defer analyzer.m.RUnlock()
+	return analyzer.referrerChannels
+}
+
 // ActiveVisitors returns the active visitors per path and the total number of active visitors for given duration.
 // Use time.Minute*5 for example to get the active visitors for the past 5 minutes.
 func (analyzer *Analyzer) ActiveVisitors(filter *Filter, duration time.Duration) ([]ActiveVisitorStats, int, error) {
-	filter = analyzer.getFilter(filter)
-	filter.Start = time.Now().UTC().Add(-duration)
+	filter = analyzer.getActiveFilter(filter, duration)
 	args, filterQuery := filter.query()
 	query := fmt.Sprintf(`SELECT path, count(DISTINCT fingerprint) visitors
 		FROM hit
 		WHERE %s
 		GROUP BY path
-		ORDER BY visitors DESC, path ASC`, filterQuery)
-	var stats []ActiveVisitorStats
+		ORDER BY visitors DESC, path ASC
+		%s`, filterQuery, filter.withLimit())
+	stats := make([]ActiveVisitorStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query = fmt.Sprintf(`SELECT count(DISTINCT fingerprint) visitors FROM hit WHERE %s`, filterQuery)
+	count, err := analyzer.store.Count(query, args...)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return stats, count, nil
+}
+
+// CountEvents returns the number of events matching given filter.
+// All events except heartbeats are counted if Filter.EventName is empty. If Filter.EventMetaKey is set, only events having the meta key are counted.
+// This can be used to check whether events are stored as expected.
+func (analyzer *Analyzer) CountEvents(filter *Filter) (int, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.queryTable("event")
+	query := fmt.Sprintf(`SELECT count(*) FROM event WHERE %s`, filterQuery)
+	return analyzer.store.Count(query, args...)
+}
+
+// CountSessions returns the number of distinct sessions matching given filter.
+// Unlike the sum of the daily session counts, sessions spanning midnight are counted once.
+func (analyzer *Analyzer) CountSessions(filter *Filter) (int, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT count(DISTINCT (fingerprint, session)) FROM %s WHERE %s`, filter.table(), filterQuery)
+	return analyzer.store.Count(query, args...)
+}
+
+// CountBounces returns the number of sessions in which a single distinct path was viewed.
+// Reloading the same page doesn't count as a second page view, and sessions are counted separately, so that returning visitors can bounce too.
+// This is the same definition used for Filter.SessionBounces, Analyzer.EngagementRate, and Analyzer.ReferrerBounceRate.
+// Note that filters restrict the hits of a session, so filtering for a path counts all sessions that viewed it as bounces.
+func (analyzer *Analyzer) CountBounces(filter *Filter) (int, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT count(*) FROM (
+			SELECT fingerprint, session
+			FROM %s
+			WHERE %s
+			GROUP BY fingerprint, session
+			HAVING %s
+		)`, filter.table(), filterQuery, sessionBounceQuery)
+	return analyzer.store.Count(query, args...)
+}
+
+// ActiveVisitorsByCountry returns the active visitors per country code and the total number of active visitors for given duration.
+// The relative visitor count is relative to the total number of active visitors. Use time.Minute*5 for example to get the active visitors for the past 5 minutes.
+func (analyzer *Analyzer) ActiveVisitorsByCountry(filter *Filter, duration time.Duration) ([]CountryStats, int, error) {
+	filter = analyzer.getActiveFilter(filter, duration)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT country_code, count(DISTINCT fingerprint) visitors
+		FROM hit
+		WHERE %s
+		GROUP BY country_code
+		ORDER BY visitors DESC, country_code ASC
+		%s`, filterQuery, filter.withLimit())
+	stats := make([]CountryStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, 0, err
@@ -67,10 +221,52 @@ func (analyzer *Analyzer) ActiveVisitors(filter *Filter, duration time.Duration)
 		return nil, 0, err
 	}
 
+	for i := range stats {
+		if count > 0 {
+			stats[i].RelativeVisitors = float64(stats[i].Visitors) / float64(count)
+		}
+	}
+
 	return stats, count, nil
 }
 
+// Hits returns the raw hits for given filter ordered by time, starting at given offset.
+// The limit must be between 1 and 1000, it will be set to 1000 otherwise. Filter.Limit is ignored.
+// This can be used to check what has been stored or to export the raw data.
+func (analyzer *Analyzer) Hits(filter *Filter, offset, limit int) ([]Hit, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 || limit > maxHitsLimit {
+		limit = maxHitsLimit
+	}
+
+	query := fmt.Sprintf(`SELECT client_id, fingerprint, time, session, previous_time_on_page_seconds,
+		user_agent, path, url, language, country_code, referrer, referrer_name, referrer_icon, os, os_version,
+		browser, browser_version, desktop, mobile, screen_width, screen_height, screen_class,
+		utm_source, utm_medium, utm_campaign, utm_content, utm_term, search_query, status_code, tablet, method, locale, ip_hash
+		FROM hit
+		WHERE %s
+		ORDER BY time ASC, fingerprint ASC
+		LIMIT %d, %d`, filterQuery, offset, limit)
+	hits := make([]Hit, 0)
+
+	if err := analyzer.store.Select(&hits, query, args...); err != nil {
+		return nil, err
+	}
+
+	return hits, nil
+}
+
 // Visitors returns the visitor count, session count, bounce rate, views, and average session duration grouped by day.
+// The statistics are calculated from the raw hits and all filter fields are applied, so it can be used to chart any combination of them,
+// like the visitors from a country to a specific page.
+// Days fully covered by one of the Filter.ExcludedWindows are left out, so they show up as gaps instead of zeros.
 func (analyzer *Analyzer) Visitors(filter *Filter) ([]VisitorStats, error) {
 	filter = analyzer.getFilter(filter)
 	args, filterQuery := filter.query()
@@ -81,118 +277,251 @@ func (analyzer *Analyzer) Visitors(filter *Filter) ([]VisitorStats, error) {
 		sum(visitors) visitors,
 		sum(sessions) sessions,
 		sum(views) views,
-		countIf(bounce = 1) bounces,
-		bounces / IF(visitors = 0, 1, visitors) bounce_rate
+		sum(bounce) bounces,
+		bounces / IF(%s = 0, 1, %s) bounce_rate
 		FROM (
 			SELECT toDate(time, '%s') day,
 			count(DISTINCT fingerprint) visitors,
 			count(DISTINCT(fingerprint, session)) sessions,
 			count(*) views,
-			length(groupArray(path)) = 1 bounce
+			%s bounce
 			FROM %s
 			WHERE %s
 			GROUP BY toDate(time, '%s'), fingerprint
 		)
 		GROUP BY day
-		ORDER BY day ASC %s, visitors DESC`, timezone, filter.table(), filterQuery, timezone, withFillQuery)
-	var stats []VisitorStats
+		ORDER BY day ASC %s, visitors DESC`, analyzer.bounceRateBase(filter), analyzer.bounceRateBase(filter), timezone, analyzer.bounceQuery(filter), filter.table(), filterQuery, timezone, withFillQuery)
+	stats := make([]VisitorStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
 	}
 
+	if len(filter.ExcludedWindows) > 0 {
+		result := make([]VisitorStats, 0, len(stats))
+
+		for _, s := range stats {
+			if !filter.excludedDay(s.Day) {
+				result = append(result, s)
+			}
+		}
+
+		stats = result
+	}
+
 	return stats, nil
 }
 
-// Growth returns the growth rate for visitor count, session count, bounces, views, and average session duration or average time on page (if path is set).
-// The growth rate is relative to the previous time range or day.
-// The period or day for the filter must be set, else an error is returned.
-func (analyzer *Analyzer) Growth(filter *Filter) (*Growth, error) {
-	filter = analyzer.getFilter(filter)
+// WithExcludedWindows returns a copy of the filter with the ExcludedWindows set to the time windows stored for the Filter.ClientID.
+// ErrNotSupported is returned if the Store does not implement ExclusionWindowStore.
+func (analyzer *Analyzer) WithExcludedWindows(filter *Filter) (*Filter, error) {
+	store, ok := analyzer.store.(ExclusionWindowStore)
 
-	if filter.Day.IsZero() && (filter.From.IsZero() || filter.To.IsZero()) {
-		return nil, ErrNoPeriodOrDay
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	f := NewFilter(NullClient)
+
+	if filter != nil {
+		*f = *filter
+	}
+
+	windows, err := store.ExclusionWindows(f.ClientID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.ExcludedWindows = windows
+	return f, nil
+}
+
+// VisitorsForClients returns the visitor statistics per day, like Visitors, for each of given client IDs using a single query.
+// Filter.ClientID is ignored. The Filter.ExcludedWindows are applied to all clients as they are,
+// so only use Analyzer.WithExcludedWindows if the clients share the same windows.
+// Missing days are filled with zeros for the selected period, including clients without any data.
+// Days fully covered by one of the Filter.ExcludedWindows are left out, like for Visitors.
+func (analyzer *Analyzer) VisitorsForClients(clientIDs []int64, filter *Filter) (map[int64][]VisitorStats, error) {
+	result := make(map[int64][]VisitorStats, len(clientIDs))
+
+	if len(clientIDs) == 0 {
+		return result, nil
+	}
+
+	clientsFilter := NewFilter(NullClient)
+
+	if filter != nil {
+		*clientsFilter = *filter
 	}
 
+	clientsFilter.clientIDs = clientIDs
+	filter = analyzer.getFilter(clientsFilter)
 	args, filterQuery := filter.query()
-	query := fmt.Sprintf(`SELECT sum(visitors) visitors,
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT client_id, day,
+		sum(visitors) visitors,
 		sum(sessions) sessions,
 		sum(views) views,
-		countIf(bounce = 1) bounces
+		sum(bounce) bounces,
+		bounces / IF(%s = 0, 1, %s) bounce_rate
 		FROM (
-			SELECT count(DISTINCT fingerprint) visitors,
+			SELECT client_id,
+			toDate(time, '%s') day,
+			count(DISTINCT fingerprint) visitors,
 			count(DISTINCT(fingerprint, session)) sessions,
 			count(*) views,
-			length(groupArray(path)) = 1 bounce
+			%s bounce
 			FROM %s
 			WHERE %s
-			GROUP BY toDate(time, '%s'), fingerprint
-		)`, filter.table(), filterQuery, filter.Timezone.String())
-	current := new(growthStats)
+			GROUP BY client_id, toDate(time, '%s'), fingerprint
+		)
+		GROUP BY client_id, day
+		ORDER BY client_id ASC, day ASC`, analyzer.bounceRateBase(filter), analyzer.bounceRateBase(filter), timezone, analyzer.bounceQuery(filter), filter.table(), filterQuery, timezone)
+	var stats []struct {
+		ClientID int64 `db:"client_id"`
+		VisitorStats
+	}
 
-	if err := analyzer.store.Get(current, query, args...); err != nil {
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
 	}
 
-	var currentTimeSpent int
-	var err error
+	days := filter.days()
+	clientDays := make(map[int64]map[int64]VisitorStats, len(clientIDs))
 
-	if filter.Path == "" {
-		currentTimeSpent, err = analyzer.TotalSessionDuration(filter)
-	} else {
-		currentTimeSpent, err = analyzer.TotalTimeOnPage(filter)
+	for _, clientID := range clientIDs {
+		result[clientID] = make([]VisitorStats, 0, len(days))
+		clientDays[clientID] = make(map[int64]VisitorStats)
+	}
+
+	for _, s := range stats {
+		if filter.excludedDay(s.Day) {
+			continue
+		}
+
+		if days == nil {
+			result[s.ClientID] = append(result[s.ClientID], s.VisitorStats)
+		} else if _, found := clientDays[s.ClientID]; found {
+			clientDays[s.ClientID][s.Day.Unix()] = s.VisitorStats
+		}
+	}
+
+	if days != nil {
+		for _, clientID := range clientIDs {
+			for _, day := range days {
+				if filter.excludedDay(day) {
+					continue
+				}
+
+				s, found := clientDays[clientID][day.Unix()]
+
+				if !found {
+					s.Day = day
+				}
+
+				result[clientID] = append(result[clientID], s)
+			}
+		}
 	}
 
+	return result, nil
+}
+
+// WeekdayWeekendSplit compares the visitors, sessions, and bounces on weekdays (Monday to Friday) to those on weekends.
+// The totals are the sum of the daily statistics, the averages are divided by the number of days of each type within the period.
+// Without a period, only days with data are counted.
+func (analyzer *Analyzer) WeekdayWeekendSplit(filter *Filter) (*WeekdayWeekendStats, error) {
+	filter = analyzer.getFilter(filter)
+	days, err := analyzer.Visitors(filter)
+
 	if err != nil {
 		return nil, err
 	}
 
-	if filter.Day.IsZero() {
-		days := filter.To.Sub(filter.From)
-		filter.To = filter.From.Add(-time.Hour * 24)
-		filter.From = filter.To.Add(-days)
-	} else {
-		filter.Day = filter.Day.Add(-time.Hour * 24)
+	stats := new(WeekdayWeekendStats)
+
+	for _, day := range days {
+		dayType := &stats.Weekday
+
+		if weekday := day.Day.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+			dayType = &stats.Weekend
+		}
+
+		dayType.Days++
+		dayType.Visitors += day.Visitors
+		dayType.Sessions += day.Sessions
+		dayType.Bounces += day.Bounces
 	}
 
-	args, _ = filter.query()
-	previous := new(growthStats)
+	analyzer.calculateDayTypeStats(&stats.Weekday, filter.SessionBounces)
+	analyzer.calculateDayTypeStats(&stats.Weekend, filter.SessionBounces)
+	return stats, nil
+}
 
-	if err := analyzer.store.Get(previous, query, args...); err != nil {
-		return nil, err
+// AverageVisitorsPerDay returns the mean of the unique visitors per day.
+// Days without visitors within the period are included in the average, unless excludeEmptyDays is set.
+// Without a period, only days with visitors are counted. Days fully covered by one of the Filter.ExcludedWindows are not counted.
+func (analyzer *Analyzer) AverageVisitorsPerDay(filter *Filter, excludeEmptyDays bool) (float64, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT sum(visitors) visitors, count(*) days
+		FROM (
+			SELECT count(DISTINCT fingerprint) visitors
+			FROM %s
+			WHERE %s
+			GROUP BY toDate(time, '%s')
+		)`, filter.table(), filterQuery, timezone)
+	stats := new(struct {
+		Visitors int
+		Days     int
+	})
+
+	if err := analyzer.store.Get(stats, query, args...); err != nil {
+		return 0, err
 	}
 
-	var previousTimeSpent int
+	days := stats.Days
 
-	if filter.Path == "" {
-		previousTimeSpent, err = analyzer.TotalSessionDuration(filter)
-	} else {
-		previousTimeSpent, err = analyzer.TotalTimeOnPage(filter)
+	if !excludeEmptyDays {
+		if !filter.Day.IsZero() {
+			days = 1
+		} else if period := filter.days(); len(period) > 0 {
+			days = len(period)
+		}
+
+		for _, day := range filter.days() {
+			if filter.excludedDay(day) {
+				days--
+			}
+		}
+
+		if !filter.Day.IsZero() && filter.excludedDay(filter.Day) {
+			days = 0
+		}
 	}
 
-	if err != nil {
-		return nil, err
+	if days == 0 {
+		return 0, nil
 	}
 
-	return &Growth{
-		VisitorsGrowth:  analyzer.calculateGrowth(current.Visitors, previous.Visitors),
-		ViewsGrowth:     analyzer.calculateGrowth(current.Views, previous.Views),
-		SessionsGrowth:  analyzer.calculateGrowth(current.Sessions, previous.Sessions),
-		BouncesGrowth:   analyzer.calculateGrowth(current.Bounces, previous.Bounces),
-		TimeSpentGrowth: analyzer.calculateGrowth(currentTimeSpent, previousTimeSpent),
-	}, nil
+	return float64(stats.Visitors) / float64(days), nil
 }
 
-// VisitorHours returns the visitor count grouped by time of day.
-func (analyzer *Analyzer) VisitorHours(filter *Filter) ([]VisitorHourStats, error) {
+// HitsPerDay returns the total number of hits grouped by day, including repeated page views of the same visitor.
+// Use this to estimate the storage and ingestion load. Days without hits are filled in if the period is set.
+func (analyzer *Analyzer) HitsPerDay(filter *Filter) ([]HitsPerDayStats, error) {
 	filter = analyzer.getFilter(filter)
 	args, filterQuery := filter.query()
-	query := fmt.Sprintf(`SELECT toHour(time, '%s') hour, count(DISTINCT fingerprint) visitors
+	withFillArgs, withFillQuery := filter.withFill()
+	args = append(args, withFillArgs...)
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day, count(*) hits
 		FROM %s
 		WHERE %s
-		GROUP BY hour
-		ORDER BY hour WITH FILL FROM 0 TO 24`, filter.Timezone.String(), filter.table(), filterQuery)
-	var stats []VisitorHourStats
+		GROUP BY day
+		ORDER BY day ASC %s`, filter.Timezone.String(), filter.table(), filterQuery, withFillQuery)
+	stats := make([]HitsPerDayStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
@@ -201,93 +530,892 @@ func (analyzer *Analyzer) VisitorHours(filter *Filter) ([]VisitorHourStats, erro
 	return stats, nil
 }
 
-// Pages returns the visitor count, session count, bounce rate, views, and average time on page grouped by path.
-func (analyzer *Analyzer) Pages(filter *Filter) ([]PageStats, error) {
+// EngagementRate returns the share of engaged sessions, for the whole period and grouped by day.
+// A session is engaged if more than one distinct path was viewed, making it the inverse of the session bounce rate.
+// The time spent on a page is only known once the next page is viewed, so there is no separate time threshold.
+// Sessions are counted on the day they started. Days without sessions have an engagement rate of zero.
+func (analyzer *Analyzer) EngagementRate(filter *Filter) (*EngagementStats, error) {
 	filter = analyzer.getFilter(filter)
-	filterArgs, filterQuery := filter.query()
 	filter.EventName = ""
-	relativeFilterArgs, relativeFilterQuery := filter.query()
-	table := filter.table()
-	query := fmt.Sprintf(`SELECT path,
-		sum(visitors) visitors,
-		visitors / greatest((
-			SELECT count(DISTINCT fingerprint)
-			FROM %s
-			WHERE %s
-		), 1) relative_visitors,
-		sum(sessions) sessions,
-		sum(views) views,
-		views / greatest((
-			SELECT count(*)
-			FROM %s
-			WHERE %s
-		), 1) relative_views,
-		countIf(bounce = 1) bounces,
-		bounces / IF(visitors = 0, 1, visitors) bounce_rate
+	args, filterQuery := filter.query()
+	withFillArgs, withFillQuery := filter.withFill()
+	args = append(args, withFillArgs...)
+	query := fmt.Sprintf(`SELECT toDate(start, '%s') day,
+		count(*) sessions,
+		countIf(bounce = 0) engaged_sessions,
+		engaged_sessions / greatest(sessions, 1) engagement_rate
 		FROM (
-			SELECT path,
-			count(DISTINCT fingerprint) visitors,
-			count(DISTINCT(fingerprint, session)) sessions,
-			count(*) views,
-			length(groupArray(path)) = 1 bounce
+			SELECT min(time) start,
+			%s bounce
 			FROM %s
 			WHERE %s
-			GROUP BY path, fingerprint
+			GROUP BY fingerprint, session
 		)
-		GROUP BY path
-		ORDER BY visitors DESC, path ASC
-		%s`, table, relativeFilterQuery, table, relativeFilterQuery, table, filterQuery, filter.withLimit())
-	args := make([]interface{}, 0, len(filterArgs)*3)
-	args = append(args, relativeFilterArgs...)
-	args = append(args, relativeFilterArgs...)
-	args = append(args, filterArgs...)
-	var stats []PageStats
+		GROUP BY day
+		ORDER BY day ASC %s`, filter.Timezone.String(), sessionBounceQuery, filter.table(), filterQuery, withFillQuery)
+	days := make([]EngagementDayStats, 0)
 
-	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+	if err := analyzer.store.Select(&days, query, args...); err != nil {
 		return nil, err
 	}
 
-	if filter.IncludeAvgTimeOnPage {
-		timeOnPage, err := analyzer.AvgTimeOnPages(filter)
+	stats := &EngagementStats{
+		Days: days,
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	for _, day := range days {
+		stats.Sessions += day.Sessions
+		stats.EngagedSessions += day.EngagedSessions
+	}
 
-		for i := range stats {
-			for j := range timeOnPage {
-				if stats[i].Path == timeOnPage[j].Path {
-					stats[i].AverageTimeSpentSeconds = timeOnPage[j].AverageTimeSpentSeconds
-					break
-				}
-			}
-		}
+	if stats.Sessions > 0 {
+		stats.EngagementRate = float64(stats.EngagedSessions) / float64(stats.Sessions)
 	}
 
 	return stats, nil
 }
 
-// EntryPages returns the visitor count and time on page grouped by path for the first page visited.
-func (analyzer *Analyzer) EntryPages(filter *Filter) ([]EntryStats, error) {
+// Anomalies returns the days on which the visitor count deviates significantly from the previous days.
+// Each day is compared to the mean and standard deviation of the window of days before it,
+// and is returned if its z-score is at least sigma (or -sigma for unusually low traffic).
+// The window defaults to 7 days and sigma to 3 if they are zero or less.
+// Days without visitors count as zero, so if no period is set, the days between the first and the last day with visitors are filled.
+// Days for which the previous days have a standard deviation of zero are never flagged.
+func (analyzer *Analyzer) Anomalies(filter *Filter, window int, sigma float64) ([]AnomalyStats, error) {
+	if window <= 0 {
+		window = defaultAnomalyWindow
+	}
+
+	if sigma <= 0 {
+		sigma = defaultAnomalySigma
+	}
+
 	filter = analyzer.getFilter(filter)
-	var path, pathFilter string
+	from := filter.From
+	seriesFilter := *filter
 
-	if filter.Path != "" {
-		path = filter.Path
-		pathFilter = "AND path = ?"
-		filter.Path = ""
+	if !from.IsZero() {
+		seriesFilter.From = from.AddDate(0, 0, -window)
 	}
 
-	filterArgs, filterQuery := filter.query()
+	stats, err := analyzer.Visitors(&seriesFilter)
 
-	if path != "" {
-		filterArgs = append(filterArgs, path)
+	if err != nil {
+		return nil, err
 	}
 
-	query := fmt.Sprintf(`SELECT *
-		FROM (
-			SELECT "path",
-			count(DISTINCT fingerprint) visitors,
+	if seriesFilter.From.IsZero() {
+		stats = analyzer.fillDays(&seriesFilter, stats)
+	}
+
+	anomalies := analyzer.findAnomalies(stats, window, sigma)
+	result := make([]AnomalyStats, 0, len(anomalies))
+
+	for _, anomaly := range anomalies {
+		if !anomaly.Day.Before(from) {
+			result = append(result, anomaly)
+		}
+	}
+
+	return result, nil
+}
+
+// VisitorsByInterval returns the visitor count grouped by given interval, like every 15 minutes.
+// The period or day for the filter must be set, else an error is returned.
+// The interval must be at least one second, and the period must not be split into more than 10,000 intervals.
+func (analyzer *Analyzer) VisitorsByInterval(filter *Filter, interval time.Duration) ([]VisitorIntervalStats, error) {
+	filter = analyzer.getFilter(filter)
+	from, to := filter.From, filter.To
+
+	if !filter.Day.IsZero() {
+		from, to = filter.Day, filter.Day
+	} else if from.IsZero() || to.IsZero() {
+		return nil, ErrNoPeriodOrDay
+	}
+
+	seconds := int64(interval / time.Second)
+
+	if seconds < 1 {
+		return nil, ErrInvalidInterval
+	}
+
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, filter.Timezone)
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, filter.Timezone)
+
+	if int64(end.Sub(start)/time.Second)/seconds > maxIntervals {
+		return nil, ErrTooManyIntervals
+	}
+
+	args, filterQuery := filter.query()
+	args = append(args, start.UTC(), end.UTC())
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT toStartOfInterval(time, INTERVAL %d SECOND, '%s') start,
+		count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY start
+		ORDER BY start ASC WITH FILL FROM toStartOfInterval(toDateTime(?, '%s'), INTERVAL %d SECOND, '%s') TO toDateTime(?, '%s') STEP %d`,
+		seconds, timezone, filter.table(), filterQuery, timezone, seconds, timezone, timezone, seconds)
+	stats := make([]VisitorIntervalStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// DaysWithData returns the days within the filter range that have at least one hit (or event if the event name is set).
+// This can be used to distinguish days without visitors from days on which no data was collected.
+func (analyzer *Analyzer) DaysWithData(filter *Filter) ([]time.Time, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT DISTINCT toDate(time, '%s') day
+		FROM %s
+		WHERE %s
+		ORDER BY day ASC`, filter.Timezone.String(), filter.table(), filterQuery)
+	days := make([]time.Time, 0)
+
+	if err := analyzer.store.Select(&days, query, args...); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// NewReturningTrend returns the visitor count grouped by day, split into new and returning visitors.
+// A visitor is new on the day of the first hit ever recorded for the client, and returning on all following days.
+func (analyzer *Analyzer) NewReturningTrend(filter *Filter) ([]NewReturningStats, error) {
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.query()
+	withFillArgs, withFillQuery := filter.withFill()
+	args := make([]interface{}, 0, len(filterArgs)+len(withFillArgs)+1)
+	args = append(args, filter.ClientID)
+	args = append(args, filterArgs...)
+	args = append(args, withFillArgs...)
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day,
+		count(DISTINCT fingerprint) visitors,
+		uniqExactIf(fingerprint, day = first_day) new_visitors,
+		visitors - new_visitors returning_visitors
+		FROM %s
+		INNER JOIN (
+			SELECT fingerprint, toDate(min(time), '%s') first_day
+			FROM hit
+			WHERE client_id = ?
+			GROUP BY fingerprint
+		) first_seen USING fingerprint
+		WHERE %s
+		GROUP BY day
+		ORDER BY day ASC %s`, timezone, filter.table(), timezone, filterQuery, withFillQuery)
+	stats := make([]NewReturningStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Growth returns the growth rate for visitor count, session count, bounces, views, and average session duration or average time on page (if path is set).
+// The growth rate is relative to the previous time range or day.
+// If no period or day is set, the growth is calculated for the default period (see DefaultPeriodDays).
+// ErrNoPeriodOrDay is returned if Filter.AllTime is set without a period or day.
+func (analyzer *Analyzer) Growth(filter *Filter) (*Growth, error) {
+	filter = analyzer.getFilter(filter)
+
+	if filter.Day.IsZero() && (filter.From.IsZero() || filter.To.IsZero()) {
+		return nil, ErrNoPeriodOrDay
+	}
+
+	current, err := analyzer.totalVisitors(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Day.IsZero() {
+		days := filter.To.Sub(filter.From)
+		filter.To = filter.From.Add(-time.Hour * 24)
+		filter.From = filter.To.Add(-days)
+	} else {
+		filter.Day = filter.Day.Add(-time.Hour * 24)
+	}
+
+	previous, err := analyzer.totalVisitors(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return analyzer.growth(current, previous), nil
+}
+
+// TotalVisitors returns the total visitor count, session count, bounces, views, and time spent for the selected period.
+// If Filter.Compare is set, the statistics for the previous period and the growth are attached to the result.
+// The period or day for the filter must be set to compare periods, else an error is returned.
+func (analyzer *Analyzer) TotalVisitors(filter *Filter) (*TotalVisitorStats, error) {
+	filter = analyzer.getFilter(filter)
+
+	if filter.Compare && filter.Day.IsZero() && (filter.From.IsZero() || filter.To.IsZero()) {
+		return nil, ErrNoPeriodOrDay
+	}
+
+	stats, err := analyzer.totalVisitors(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Compare {
+		previous, err := analyzer.totalVisitors(filter.previousPeriod())
+
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Previous = previous
+		stats.Growth = analyzer.growth(stats, previous)
+	}
+
+	return stats, nil
+}
+
+// SessionsPerVisitor returns the average number of sessions per visitor and the distribution of visitors by their session count.
+// The distribution is grouped into visitors with 1, 2, and 3 or more sessions. The last group has its session count set to 3.
+func (analyzer *Analyzer) SessionsPerVisitor(filter *Filter) (*SessionsPerVisitorStats, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT sessions, count(*) visitors
+		FROM (
+			SELECT fingerprint, count(DISTINCT session) sessions
+			FROM hit
+			WHERE %s
+			GROUP BY fingerprint
+		)
+		GROUP BY sessions
+		ORDER BY sessions`, filterQuery)
+	counts := make([]SessionCountStats, 0)
+
+	if err := analyzer.store.Select(&counts, query, args...); err != nil {
+		return nil, err
+	}
+
+	stats := &SessionsPerVisitorStats{
+		Distribution: make([]SessionCountStats, sessionsPerVisitorBuckets),
+	}
+
+	for i := range stats.Distribution {
+		stats.Distribution[i].Sessions = i + 1
+	}
+
+	for _, count := range counts {
+		stats.Visitors += count.Visitors
+		stats.Sessions += count.Sessions * count.Visitors
+		bucket := count.Sessions
+
+		if bucket > sessionsPerVisitorBuckets {
+			bucket = sessionsPerVisitorBuckets
+		}
+
+		if bucket > 0 {
+			stats.Distribution[bucket-1].Visitors += count.Visitors
+		}
+	}
+
+	if stats.Visitors > 0 {
+		stats.Average = float64(stats.Sessions) / float64(stats.Visitors)
+	}
+
+	return stats, nil
+}
+
+// VisitDepth returns the number of sessions and visitors grouped by the number of page views per session.
+// Sessions with 10 or more page views are grouped together and have their depth set to 10.
+func (analyzer *Analyzer) VisitDepth(filter *Filter) ([]VisitDepthStats, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT least(views, %d) depth,
+		count(*) sessions,
+		count(DISTINCT fingerprint) visitors
+		FROM (
+			SELECT fingerprint, session, count(*) views
+			FROM hit
+			WHERE %s
+			GROUP BY fingerprint, session
+		)
+		GROUP BY depth
+		ORDER BY depth`, maxVisitDepth, filterQuery)
+	stats := make([]VisitDepthStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// VisitorHours returns the visitor count grouped by time of day.
+func (analyzer *Analyzer) VisitorHours(filter *Filter) ([]VisitorHourStats, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT toHour(time, '%s') hour, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY hour
+		ORDER BY hour WITH FILL FROM 0 TO 24`, filter.Timezone.String(), filter.table(), filterQuery)
+	stats := make([]VisitorHourStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// LocalVisitorHours returns the visitor count grouped by the hour of the day in the local time of the visitors.
+// Other than VisitorHours, the local time is derived from the country of each hit instead of the Filter.Timezone.
+// Countries spanning multiple timezones use the timezone of most of their population (see countryTimezones).
+// Hits without a (known) country code are not counted.
+func (analyzer *Analyzer) LocalVisitorHours(filter *Filter) ([]VisitorHourStats, error) {
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.query()
+	var countries []string
+
+	if err := analyzer.store.Select(&countries, fmt.Sprintf(`SELECT DISTINCT country_code FROM %s WHERE %s`, filter.table(), filterQuery), filterArgs...); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(countries)
+	args := make([]interface{}, 0, len(countries)+len(filterArgs))
+	var hourQuery strings.Builder
+
+	for _, country := range countries {
+		if timezone, found := countryTimezones[country]; found {
+			args = append(args, country)
+			hourQuery.WriteString(fmt.Sprintf("country_code = ?, toHour(time, '%s'), ", timezone))
+		}
+	}
+
+	stats := make([]VisitorHourStats, 24)
+
+	for i := range stats {
+		stats[i].Hour = i
+	}
+
+	if len(args) == 0 {
+		return stats, nil
+	}
+
+	args = append(args, filterArgs...)
+	query := fmt.Sprintf(`SELECT hour, count(DISTINCT fingerprint) visitors
+		FROM (
+			SELECT multiIf(%s-1) hour, fingerprint
+			FROM %s
+			WHERE %s
+		)
+		WHERE hour >= 0
+		GROUP BY hour`, hourQuery.String(), filter.table(), filterQuery)
+	var hours []VisitorHourStats
+
+	if err := analyzer.store.Select(&hours, query, args...); err != nil {
+		return nil, err
+	}
+
+	for _, hour := range hours {
+		if hour.Hour >= 0 && hour.Hour < 24 {
+			stats[hour.Hour].Visitors = hour.Visitors
+		}
+	}
+
+	return stats, nil
+}
+
+// Peak returns the hour and the day with the most visitors.
+// In case multiple hours or days have the same number of visitors, the earliest one is returned.
+// The times are zero if there are no visitors.
+func (analyzer *Analyzer) Peak(filter *Filter) (*PeakStats, error) {
+	filter = analyzer.getFilter(filter)
+	hour, err := analyzer.peak(filter, "toStartOfHour")
+
+	if err != nil {
+		return nil, err
+	}
+
+	day, err := analyzer.peak(filter, "toDate")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PeakStats{
+		Hour:         hour.Period,
+		HourVisitors: hour.Visitors,
+		Day:          day.Period,
+		DayVisitors:  day.Visitors,
+	}, nil
+}
+
+// TimeOfDay returns the visitor count grouped by day and hour of day.
+// Each day contains the visitor count for all 24 hours, days without visitors are filled in if the period is set.
+func (analyzer *Analyzer) TimeOfDay(filter *Filter) ([]TimeOfDayVisitors, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day, toHour(time, '%s') hour, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY day, hour
+		ORDER BY day, hour`, timezone, timezone, filter.table(), filterQuery)
+	var stats []dayHourStats
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	days := filter.days()
+
+	if days == nil {
+		days = make([]time.Time, 0)
+
+		for i, s := range stats {
+			if i == 0 || !s.Day.Equal(stats[i-1].Day) {
+				days = append(days, s.Day)
+			}
+		}
+	}
+
+	visitors := make(map[int64][]VisitorHourStats)
+
+	for _, day := range days {
+		hours := make([]VisitorHourStats, 24)
+
+		for i := range hours {
+			hours[i].Hour = i
+		}
+
+		visitors[day.Unix()] = hours
+	}
+
+	for _, s := range stats {
+		if hours, found := visitors[s.Day.Unix()]; found && s.Hour >= 0 && s.Hour < 24 {
+			hours[s.Hour].Visitors = s.Visitors
+		}
+	}
+
+	result := make([]TimeOfDayVisitors, 0, len(days))
+
+	for _, day := range days {
+		result = append(result, TimeOfDayVisitors{
+			Day:   day,
+			Stats: visitors[day.Unix()],
+		})
+	}
+
+	return result, nil
+}
+
+// TopSessions returns the sessions with the most page views and the longest duration, including their entry and exit page.
+// The result is limited to the top 10 sessions, unless Filter.Limit is set.
+// Sessions are identified by an anonymized session ID instead of the fingerprint, so that the result can be shown to users.
+// The session ID is a salted one-way hash of the fingerprint and session start.
+// The salt is generated randomly for each call, so IDs are stable within a result, but cannot be linked across calls.
+func (analyzer *Analyzer) TopSessions(filter *Filter) ([]SessionStats, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	filterArgs, filterQuery := filter.query()
+	limit := filter.Limit
+
+	if limit <= 0 {
+		limit = topSessionsLimit
+	}
+
+	salt, err := analyzer.sessionIDSalt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(filterArgs)+1)
+	args = append(args, salt)
+	args = append(args, filterArgs...)
+	query := fmt.Sprintf(`SELECT lower(hex(SHA256(concat(?, fingerprint, toString(toUnixTimestamp(session)))))) session_id,
+		session,
+		count(*) views,
+		dateDiff('second', min(time), max(time)) duration_seconds,
+		argMin(path, time) entry_path,
+		argMax(path, time) exit_path
+		FROM hit
+		WHERE %s
+		GROUP BY fingerprint, session
+		ORDER BY views DESC, duration_seconds DESC, fingerprint ASC, session ASC
+		LIMIT %d`, filterQuery, limit)
+	stats := make([]SessionStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// TopItems returns the entry with the most visitors for the pages, referrers, countries, and browsers.
+// This is a lightweight alternative to selecting the full breakdowns. Dimensions without data are left nil.
+func (analyzer *Analyzer) TopItems(filter *Filter) (*TopItemStats, error) {
+	stats := new(TopItemStats)
+	pages, err := analyzer.Pages(analyzer.topItemFilter(filter))
+
+	if err != nil {
+		return nil, err
+	}
+
+	referrer, err := analyzer.Referrer(analyzer.topItemFilter(filter))
+
+	if err != nil {
+		return nil, err
+	}
+
+	countries, err := analyzer.Countries(analyzer.topItemFilter(filter))
+
+	if err != nil {
+		return nil, err
+	}
+
+	browser, err := analyzer.Browser(analyzer.topItemFilter(filter))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pages) > 0 {
+		stats.Page = &pages[0]
+	}
+
+	if len(referrer) > 0 {
+		stats.Referrer = &referrer[0]
+	}
+
+	if len(countries) > 0 {
+		stats.Country = &countries[0]
+	}
+
+	if len(browser) > 0 {
+		stats.Browser = &browser[0]
+	}
+
+	return stats, nil
+}
+
+// Pages returns the visitor count, session count, bounce rate, views, and average time on page grouped by path.
+func (analyzer *Analyzer) Pages(filter *Filter) ([]PageStats, error) {
+	return analyzer.pages(filter, 0)
+}
+
+// PageVisitors returns the visitor count and page views per day for each path using a single query.
+// The paths are sorted by the sum of visitors over all days and can be limited using Filter.Limit.
+// Missing days are filled with zeros for the selected period, or for all days with data if no period is set.
+func (analyzer *Analyzer) PageVisitors(filter *Filter) ([]PageVisitorStats, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day,
+		path,
+		count(DISTINCT fingerprint) visitors,
+		count(*) views
+		FROM %s
+		WHERE %s
+		GROUP BY day, path
+		ORDER BY day ASC, path ASC`, timezone, filter.table(), filterQuery)
+	var stats []pathDayStats
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	days := filter.days()
+
+	if days == nil {
+		days = make([]time.Time, 0)
+
+		for i, s := range stats {
+			if i == 0 || !s.Day.Equal(stats[i-1].Day) {
+				days = append(days, s.Day)
+			}
+		}
+	}
+
+	totals := make(map[string]int)
+	paths := make(map[string]map[int64]pathDayStats)
+
+	for _, s := range stats {
+		if _, found := paths[s.Path]; !found {
+			paths[s.Path] = make(map[int64]pathDayStats)
+		}
+
+		totals[s.Path] += s.Visitors
+		paths[s.Path][s.Day.Unix()] = s
+	}
+
+	names := make([]string, 0, len(totals))
+
+	for name := range totals {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] == totals[names[j]] {
+			return names[i] < names[j]
+		}
+
+		return totals[names[i]] > totals[names[j]]
+	})
+
+	if filter.Limit > 0 && len(names) > filter.Limit {
+		names = names[:filter.Limit]
+	}
+
+	result := make([]PageVisitorStats, 0, len(names))
+
+	for _, name := range names {
+		page := PageVisitorStats{
+			Path: name,
+			Days: make([]PageDayStats, 0, len(days)),
+		}
+
+		for _, day := range days {
+			s := paths[name][day.Unix()]
+			page.Days = append(page.Days, PageDayStats{
+				Day:      day,
+				Visitors: s.Visitors,
+				Views:    s.Views,
+			})
+		}
+
+		result = append(result, page)
+	}
+
+	return result, nil
+}
+
+// PageDayMatrix returns the visitors for each path and day as a matrix, which can be used for a heatmap of page activity.
+// The matrix is based on PageVisitors, so paths are sorted and limited the same way, and missing days are filled with zeros.
+func (analyzer *Analyzer) PageDayMatrix(filter *Filter) (*PageDayMatrix, error) {
+	filter = analyzer.getFilter(filter)
+	pages, err := analyzer.PageVisitors(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &PageDayMatrix{
+		Paths:    make([]string, 0, len(pages)),
+		Days:     filter.days(),
+		Visitors: make([][]int, 0, len(pages)),
+	}
+
+	if len(pages) > 0 {
+		matrix.Days = make([]time.Time, 0, len(pages[0].Days))
+
+		for _, day := range pages[0].Days {
+			matrix.Days = append(matrix.Days, day.Day)
+		}
+	} else if matrix.Days == nil {
+		matrix.Days = make([]time.Time, 0)
+	}
+
+	for _, page := range pages {
+		visitors := make([]int, 0, len(page.Days))
+
+		for _, day := range page.Days {
+			visitors = append(visitors, day.Visitors)
+		}
+
+		matrix.Paths = append(matrix.Paths, page.Path)
+		matrix.Visitors = append(matrix.Visitors, visitors)
+	}
+
+	return matrix, nil
+}
+
+// PagesStream calls fn for each page returned by Pages, without loading all pages into memory at once.
+// The pages are passed to fn in the same order as returned by Pages.
+// Iteration stops at the first error returned by fn, which is then returned.
+// Filter.Limit limits the total number of pages.
+// If Filter.IncludeAvgTimeOnPage is set, the average time on page is selected once for all paths up front.
+// If the Store implements RowStore, the pages are selected using a single query.
+// Otherwise, they are selected in batches, each running the query again, so the pages are not consistent if new data arrives while streaming.
+func (analyzer *Analyzer) PagesStream(filter *Filter, fn func(PageStats) error) error {
+	streamFilter := NewFilter(NullClient)
+
+	if filter != nil {
+		*streamFilter = *filter
+	}
+
+	streamFilter = analyzer.getFilter(streamFilter)
+	limit := streamFilter.Limit
+	var timeOnPage map[string]int
+
+	if streamFilter.IncludeAvgTimeOnPage {
+		timeOnPageFilter := *streamFilter
+		timeOnPageFilter.Limit = 0
+		stats, err := analyzer.AvgTimeOnPages(&timeOnPageFilter)
+
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return err
+		}
+
+		timeOnPage = make(map[string]int, len(stats))
+
+		for _, s := range stats {
+			timeOnPage[s.Path] = s.AverageTimeSpentSeconds
+		}
+
+		streamFilter.IncludeAvgTimeOnPage = false
+	}
+
+	if store, ok := analyzer.store.(RowStore); ok {
+		args, query := analyzer.pagesQuery(streamFilter, 0)
+		return store.SelectRows(func(scan func(interface{}) error) error {
+			var page PageStats
+
+			if err := scan(&page); err != nil {
+				return err
+			}
+
+			page.AverageTimeSpentSeconds = timeOnPage[page.Path]
+			return fn(page)
+		}, query, args...)
+	}
+
+	for offset := 0; limit <= 0 || offset < limit; offset += pagesStreamBatchSize {
+		batchFilter := *streamFilter
+		batchFilter.Limit = pagesStreamBatchSize
+
+		if limit > 0 && limit-offset < pagesStreamBatchSize {
+			batchFilter.Limit = limit - offset
+		}
+
+		stats, err := analyzer.pages(&batchFilter, offset)
+
+		if err != nil {
+			return err
+		}
+
+		for _, page := range stats {
+			page.AverageTimeSpentSeconds = timeOnPage[page.Path]
+
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		if len(stats) < batchFilter.Limit {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (analyzer *Analyzer) pages(filter *Filter, offset int) ([]PageStats, error) {
+	filter = analyzer.getFilter(filter)
+	args, query := analyzer.pagesQuery(filter, offset)
+	stats := make([]PageStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	if filter.IncludeAvgTimeOnPage {
+		// the time on page is loaded for all paths, as they might be ordered differently
+		timeOnPageFilter := *filter
+		timeOnPageFilter.Limit = 0
+		timeOnPage, err := analyzer.AvgTimeOnPages(&timeOnPageFilter)
+
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return nil, err
+		}
+
+		for i := range stats {
+			for j := range timeOnPage {
+				if stats[i].Path == timeOnPage[j].Path {
+					stats[i].AverageTimeSpentSeconds = timeOnPage[j].AverageTimeSpentSeconds
+					break
+				}
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// pagesQuery returns the query used by Analyzer.Pages, ordered by visitors and limited by Filter.Limit, starting at given offset.
+// The filter must have been validated.
+func (analyzer *Analyzer) pagesQuery(filter *Filter, offset int) ([]interface{}, string) {
+	filterArgs, filterQuery := filter.query()
+	eventFilter := *filter
+	eventFilter.EventName = ""
+	relativeFilterArgs, relativeFilterQuery := eventFilter.query()
+	table := eventFilter.table()
+	query := fmt.Sprintf(`SELECT path,
+		sum(visitors) visitors,
+		visitors / greatest((
+			SELECT count(DISTINCT fingerprint)
+			FROM %s
+			WHERE %s
+		), 1) relative_visitors,
+		sum(sessions) sessions,
+		sum(views) views,
+		views / greatest((
+			SELECT count(*)
+			FROM %s
+			WHERE %s
+		), 1) relative_views,
+		countIf(bounce = 1) bounces,
+		bounces / IF(visitors = 0, 1, visitors) bounce_rate
+		FROM (
+			SELECT path,
+			count(DISTINCT fingerprint) visitors,
+			count(DISTINCT(fingerprint, session)) sessions,
+			count(*) views,
+			length(groupArray(path)) = 1 bounce
+			FROM %s
+			WHERE %s
+			GROUP BY path, fingerprint
+		)
+		GROUP BY path
+		ORDER BY visitors DESC, path ASC
+		%s %s`, table, relativeFilterQuery, table, relativeFilterQuery, table, filterQuery, filter.withLimit(), analyzer.withOffset(offset))
+	args := make([]interface{}, 0, len(filterArgs)*3)
+	args = append(args, relativeFilterArgs...)
+	args = append(args, relativeFilterArgs...)
+	args = append(args, filterArgs...)
+	return args, query
+}
+
+// EntryPages returns the visitor count and time on page grouped by path for the first page visited.
+func (analyzer *Analyzer) EntryPages(filter *Filter) ([]EntryStats, error) {
+	filter = analyzer.getFilter(filter)
+	var path, pathFilter string
+
+	if filter.Path != "" {
+		path = filter.Path
+		pathFilter = "AND path = ?"
+		filter.Path = ""
+	}
+
+	filterArgs, filterQuery := filter.query()
+
+	if path != "" {
+		filterArgs = append(filterArgs, path)
+	}
+
+	query := fmt.Sprintf(`SELECT *
+		FROM (
+			SELECT "path",
+			count(DISTINCT fingerprint) visitors,
 			countIf(prev_fingerprint != fingerprint) entries
 			FROM (
 				SELECT fingerprint,
@@ -303,201 +1431,823 @@ func (analyzer *Analyzer) EntryPages(filter *Filter) ([]EntryStats, error) {
 			)
 			GROUP BY "path"
 		)
-		WHERE entries > 0 %s
-		ORDER BY entries DESC, "path" ASC
-		%s`, filter.table(), filterQuery, pathFilter, filter.withLimit())
-	var stats []EntryStats
+		WHERE entries > 0 %s
+		ORDER BY entries DESC, "path" ASC
+		%s`, filter.table(), filterQuery, pathFilter, filter.withLimit())
+	stats := make([]EntryStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, filterArgs...); err != nil {
+		return nil, err
+	}
+
+	if filter.IncludeAvgTimeOnPage {
+		// the time on page is loaded for all paths, as they might be ordered differently
+		timeOnPageFilter := *filter
+		timeOnPageFilter.Limit = 0
+		timeOnPage, err := analyzer.AvgTimeOnPages(&timeOnPageFilter)
+
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return nil, err
+		}
+
+		for i := range stats {
+			for j := range timeOnPage {
+				if stats[i].Path == timeOnPage[j].Path {
+					stats[i].AverageTimeSpentSeconds = timeOnPage[j].AverageTimeSpentSeconds
+					break
+				}
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// LandingConversion returns the number of sessions per entry page and how many of them triggered given event within the same session.
+// Filter.Path optionally limits the result to a single entry page. All other filter fields are applied to the hits and events alike,
+// except for Filter.EventMeta, which is used to match the event. The eventName must be set, or otherwise the result set will be empty.
+func (analyzer *Analyzer) LandingConversion(filter *Filter, eventName string) ([]LandingConversionStats, error) {
+	if strings.TrimSpace(eventName) == "" {
+		return []LandingConversionStats{}, nil
+	}
+
+	filter = analyzer.getFilter(filter)
+	entryPath := filter.Path
+	filter.Path = ""
+	filter.PathPattern = ""
+	filter.EventName = ""
+	eventFilter := *filter
+	eventFilter.EventName = strings.TrimSpace(eventName)
+	args, filterQuery := filter.query()
+	eventArgs, eventFilterQuery := eventFilter.query()
+	args = append(args, eventArgs...)
+	var pathFilter string
+
+	if entryPath != "" {
+		args = append(args, entryPath)
+		pathFilter = "WHERE entry_path = ?"
+	}
+
+	query := fmt.Sprintf(`SELECT entry_path path,
+		count(*) sessions,
+		countIf(converted = 1) conversions,
+		conversions / greatest(sessions, 1) cr
+		FROM (
+			SELECT fingerprint,
+			session,
+			argMin("path", time) entry_path
+			FROM %s
+			WHERE %s
+			GROUP BY fingerprint, session
+		) entries
+		LEFT JOIN (
+			SELECT DISTINCT fingerprint, session, toUInt8(1) converted
+			FROM %s
+			WHERE %s
+		) converted_sessions USING (fingerprint, session)
+		%s
+		GROUP BY entry_path
+		ORDER BY sessions DESC, conversions DESC, path ASC
+		%s`, filter.table(), filterQuery, eventFilter.table(), eventFilterQuery, pathFilter, filter.withLimit())
+	stats := make([]LandingConversionStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ExitPages returns the visitor count and time on page grouped by path for the last page visited.
+func (analyzer *Analyzer) ExitPages(filter *Filter) ([]ExitStats, error) {
+	filter = analyzer.getFilter(filter)
+	var path, pathFilter string
+
+	if filter.Path != "" {
+		path = filter.Path
+		pathFilter = "AND path = ?"
+		filter.Path = ""
+	}
+
+	filterArgs, filterQuery := filter.query()
+
+	if path != "" {
+		filterArgs = append(filterArgs, path)
+	}
+
+	query := fmt.Sprintf(`SELECT *
+		FROM (
+			SELECT "path",
+			count(DISTINCT fingerprint) visitors,
+			countIf(next_fingerprint != fingerprint) exits,
+			exits/visitors exit_rate
+			FROM (
+				SELECT fingerprint,
+				"session",
+				"path",
+				neighbor("fingerprint", 1) next_fingerprint
+				FROM (
+					SELECT fingerprint, "session", "path"
+					FROM %s
+					WHERE %s
+					ORDER BY fingerprint, "time"
+				)
+			)
+			GROUP BY "path"
+		)
+		WHERE exits > 0 %s
+		ORDER BY exits DESC, "path" ASC
+		%s`, filter.table(), filterQuery, pathFilter, filter.withLimit())
+	stats := make([]ExitStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, filterArgs...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// VisitorsWithAllPaths returns the number of unique visitors who viewed all of the given paths within the period, in any order.
+// Filter.Path and Filter.PathPattern are ignored. Zero is returned if no path is passed.
+func (analyzer *Analyzer) VisitorsWithAllPaths(filter *Filter, paths []string) (int, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	filter.Path = ""
+	filter.PathPattern = ""
+	args, filterQuery := filter.query()
+	pathList := make([]string, 0, len(paths))
+
+	for _, path := range paths {
+		if path != "" && !containsString(pathList, path) {
+			pathList = append(pathList, path)
+			args = append(args, path)
+		}
+	}
+
+	if len(pathList) == 0 {
+		return 0, nil
+	}
+
+	query := fmt.Sprintf(`SELECT count(*) FROM (
+			SELECT fingerprint
+			FROM hit
+			WHERE %s
+			AND "path" IN (%s)
+			GROUP BY fingerprint
+			HAVING count(DISTINCT "path") = %d
+		)`, filterQuery, strings.TrimSuffix(strings.Repeat("?,", len(pathList)), ","), len(pathList))
+	return analyzer.store.Count(query, args...)
+}
+
+// PageEngagementScore returns the paths ranked by a score combining the visitors, bounce rate, and average time on page.
+// The score is calculated as visitors^v * (1 - bounce rate)^b * time on page^t, where the visitors and time on page are
+// normalized to the highest value among all paths, and v, b, and t are the weights. A weight of zero ignores the metric.
+// All weights are set to 1 if nil is passed. ErrInvalidWeights is returned if a weight is negative or not a number.
+// The result is ordered by score and limited by Filter.Limit.
+func (analyzer *Analyzer) PageEngagementScore(filter *Filter, weights *EngagementScoreWeights) ([]PageEngagementStats, error) {
+	if weights == nil {
+		weights = &EngagementScoreWeights{Visitors: 1, Bounce: 1, TimeOnPage: 1}
+	} else if !weights.valid() {
+		return nil, ErrInvalidWeights
+	}
+
+	filter = analyzer.getFilter(filter)
+	pagesFilter := *filter
+	pagesFilter.Limit = 0
+	pagesFilter.IncludeAvgTimeOnPage = true
+	pages, err := analyzer.pages(&pagesFilter, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	maxVisitors, maxTimeSpent := 1, 1
+
+	for _, page := range pages {
+		if page.Visitors > maxVisitors {
+			maxVisitors = page.Visitors
+		}
+
+		if page.AverageTimeSpentSeconds > maxTimeSpent {
+			maxTimeSpent = page.AverageTimeSpentSeconds
+		}
+	}
+
+	stats := make([]PageEngagementStats, 0, len(pages))
+
+	for _, page := range pages {
+		score := math.Pow(float64(page.Visitors)/float64(maxVisitors), weights.Visitors) *
+			math.Pow(1-page.BounceRate, weights.Bounce) *
+			math.Pow(float64(page.AverageTimeSpentSeconds)/float64(maxTimeSpent), weights.TimeOnPage)
+		stats = append(stats, PageEngagementStats{
+			Path:                    page.Path,
+			Visitors:                page.Visitors,
+			BounceRate:              page.BounceRate,
+			AverageTimeSpentSeconds: page.AverageTimeSpentSeconds,
+			Score:                   score,
+		})
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Score > stats[j].Score
+	})
+
+	if filter.Limit > 0 && len(stats) > filter.Limit {
+		stats = stats[:filter.Limit]
+	}
+
+	return stats, nil
+}
+
+// PageMovers returns the paths that gained or lost the most visitors compared to the previous period (see Growth).
+// The paths are sorted by the absolute change in visitors, so that the biggest gains and losses come first.
+// Paths without visitors in the previous period are marked as new, paths without visitors in the current period as removed.
+// The relative change is zero for new paths.
+func (analyzer *Analyzer) PageMovers(filter *Filter) ([]PageMoverStats, error) {
+	filter = analyzer.getFilter(filter)
+	comparison, err := analyzer.comparisonByAttribute(filter, "path")
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]PageMoverStats, 0, len(comparison))
+
+	for _, entry := range comparison {
+		stats = append(stats, PageMoverStats{
+			Path:             entry.Key,
+			Visitors:         entry.Visitors,
+			PreviousVisitors: entry.PreviousVisitors,
+			New:              entry.PreviousVisitors == 0,
+			Removed:          entry.Visitors == 0,
+		})
+	}
+
+	for i := range stats {
+		stats[i].Change = stats[i].Visitors - stats[i].PreviousVisitors
+
+		if stats[i].PreviousVisitors > 0 {
+			stats[i].RelativeChange = float64(stats[i].Change) / float64(stats[i].PreviousVisitors)
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i].Change, stats[j].Change
+
+		if a < 0 {
+			a = -a
+		}
+
+		if b < 0 {
+			b = -b
+		}
+
+		if a != b {
+			return a > b
+		}
+
+		if stats[i].Change != stats[j].Change {
+			return stats[i].Change > stats[j].Change
+		}
+
+		return stats[i].Path < stats[j].Path
+	})
+
+	if filter.Limit > 0 && len(stats) > filter.Limit {
+		stats = stats[:filter.Limit]
+	}
+
+	return stats, nil
+}
+
+// PageConversions returns the visitor count, views, and conversion rate for conversion goals.
+// This function is supposed to be used with the Filter.PathPattern, to list page conversions.
+func (analyzer *Analyzer) PageConversions(filter *Filter) (*PageConversionsStats, error) {
+	filter = analyzer.getFilter(filter)
+	filterArgsPath, filterQueryPath := filter.query()
+	filter.PathPattern = ""
+	filter.EventName = ""
+	filterArgs, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT sum(visitors) visitors,
+		sum(views) views,
+		visitors / greatest((
+			SELECT count(DISTINCT fingerprint)
+			FROM hit
+			WHERE %s
+		), 1) cr
+		FROM (
+			SELECT count(DISTINCT fingerprint) visitors,
+			count(*) views
+			FROM hit
+			WHERE %s
+		)
+		ORDER BY visitors DESC`, filterQuery, filterQueryPath)
+	args := make([]interface{}, 0, len(filterArgs)+len(filterArgsPath))
+	args = append(args, filterArgs...)
+	args = append(args, filterArgsPath...)
+	stats := new(PageConversionsStats)
+
+	if err := analyzer.store.Get(stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Events returns the visitor count, views, and conversion rate for custom events.
+// Heartbeats (see Tracker.Heartbeat) are not included, unless they are selected by the Filter.EventName.
+func (analyzer *Analyzer) Events(filter *Filter) ([]EventStats, error) {
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.queryTable("event")
+	filter.EventName = ""
+	crFilterArgs, crFilterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT event_name,
+		sum(visitors) visitors,
+		sum(views) views,
+		visitors / greatest((
+			SELECT count(DISTINCT fingerprint)
+			FROM hit
+			WHERE %s
+		), 1) cr,
+		toUInt64(avg(avg_duration)) average_duration_seconds,
+		groupUniqArrayArray(meta_keys) meta_keys
+		FROM (
+			SELECT event_name,
+			groupUniqArrayArray(event_meta_keys) meta_keys,
+			count(DISTINCT fingerprint) visitors,
+			count(*) views,
+			avg(event_duration_seconds) avg_duration
+			FROM event
+			WHERE %s
+			GROUP BY event_name
+		)
+		GROUP BY event_name
+		ORDER BY visitors DESC, event_name ASC
+		%s`, crFilterQuery, filterQuery, filter.withLimit())
+	args := make([]interface{}, 0, len(filterArgs)*2)
+	args = append(args, crFilterArgs...)
+	args = append(args, filterArgs...)
+	stats := make([]EventStats, 0)
 
-	if err := analyzer.store.Select(&stats, query, filterArgs...); err != nil {
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
 	}
 
-	if filter.IncludeAvgTimeOnPage {
-		timeOnPage, err := analyzer.AvgTimeOnPages(filter)
+	return stats, nil
+}
 
-		if err != nil {
-			return nil, err
-		}
+// EventValue returns the number of events having a value, and the total and average value for given event name.
+// Events without a value are ignored.
+func (analyzer *Analyzer) EventValue(filter *Filter, eventName string) (*EventValueStats, error) {
+	eventFilter := NewFilter(NullClient)
 
-		for i := range stats {
-			for j := range timeOnPage {
-				if stats[i].Path == timeOnPage[j].Path {
-					stats[i].AverageTimeSpentSeconds = timeOnPage[j].AverageTimeSpentSeconds
-					break
-				}
-			}
-		}
+	if filter != nil {
+		*eventFilter = *filter
+	}
+
+	eventFilter.EventName = eventName
+	eventFilter = analyzer.getFilter(eventFilter)
+	args, filterQuery := eventFilter.query()
+	query := fmt.Sprintf(`SELECT count(*) "count",
+		sum(event_value) total,
+		total / greatest("count", 1) average
+		FROM event
+		WHERE %s
+		AND event_has_value = 1`, filterQuery)
+	stats := new(EventValueStats)
+
+	if err := analyzer.store.Get(stats, query, args...); err != nil {
+		return nil, err
 	}
 
 	return stats, nil
 }
 
-// ExitPages returns the visitor count and time on page grouped by path for the last page visited.
-func (analyzer *Analyzer) ExitPages(filter *Filter) ([]ExitStats, error) {
+// EventBreakdown returns the visitor count, views, and conversion rate for a custom event grouping them by a meta value for given key.
+// The Filter.EventName and Filter.EventMetaKey must be set, or otherwise the result set will be empty.
+func (analyzer *Analyzer) EventBreakdown(filter *Filter) ([]EventStats, error) {
 	filter = analyzer.getFilter(filter)
-	var path, pathFilter string
 
-	if filter.Path != "" {
-		path = filter.Path
-		pathFilter = "AND path = ?"
-		filter.Path = ""
+	if filter.EventName == "" || filter.EventMetaKey == "" {
+		return []EventStats{}, nil
 	}
 
 	filterArgs, filterQuery := filter.query()
+	filter.EventName = ""
+	crFilterArgs, crFilterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT event_name,
+		sum(visitors) visitors,
+		sum(views) views,
+		visitors / greatest((
+			SELECT count(DISTINCT fingerprint)
+			FROM hit
+			WHERE %s
+		), 1) cr,
+		toUInt64(avg(avg_duration)) average_duration_seconds,
+		meta_value
+		FROM (
+			SELECT event_name,
+			count(DISTINCT fingerprint) visitors,
+			count(*) views,
+			avg(event_duration_seconds) avg_duration,
+			event_meta_values[indexOf(event_meta_keys, ?)] meta_value
+			FROM event
+			WHERE %s
+			GROUP BY event_name, meta_value
+		)
+		GROUP BY event_name, meta_value
+		ORDER BY visitors DESC, meta_value
+		%s`, crFilterQuery, filterQuery, filter.withLimit())
+	args := make([]interface{}, 0, len(filterArgs)*2)
+	args = append(args, crFilterArgs...)
+	args = append(args, filter.EventMetaKey)
+	args = append(args, filterArgs...)
+	stats := make([]EventStats, 0)
 
-	if path != "" {
-		filterArgs = append(filterArgs, path)
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
 	}
 
-	query := fmt.Sprintf(`SELECT *
+	return stats, nil
+}
+
+// AverageEngagedTime returns the average time visitors engaged with the site and each page, as reported by Tracker.Heartbeat.
+// The seconds are summed up per session (and page), so sessions without a heartbeat are not included.
+// The pages are ordered by the number of sessions and limited by Filter.Limit.
+func (analyzer *Analyzer) AverageEngagedTime(filter *Filter) (*EngagedTimeStats, error) {
+	heartbeatFilter := NewFilter(NullClient)
+
+	if filter != nil {
+		*heartbeatFilter = *filter
+	}
+
+	heartbeatFilter.EventName = EventHeartbeat
+	heartbeatFilter = analyzer.getFilter(heartbeatFilter)
+	args, filterQuery := heartbeatFilter.query()
+	query := fmt.Sprintf(`SELECT count(*) sessions,
+		toUInt64(sum(seconds) / greatest(sessions, 1)) average_engaged_seconds
 		FROM (
-			SELECT "path",
-			count(DISTINCT fingerprint) visitors,
-			countIf(next_fingerprint != fingerprint) exits,
-			exits/visitors exit_rate
-			FROM (
-				SELECT fingerprint,
-				"session",
-				"path",
-				neighbor("fingerprint", 1) next_fingerprint
-				FROM (
-					SELECT fingerprint, "session", "path"
-					FROM %s
-					WHERE %s
-					ORDER BY fingerprint, "time"
-				)
-			)
-			GROUP BY "path"
+			SELECT sum(event_duration_seconds) seconds
+			FROM event
+			WHERE %s
+			GROUP BY fingerprint, session
+		)`, filterQuery)
+	stats := new(EngagedTimeStats)
+
+	if err := analyzer.store.Get(stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf(`SELECT "path",
+		count(*) sessions,
+		toUInt64(avg(seconds)) average_engaged_seconds
+		FROM (
+			SELECT "path", sum(event_duration_seconds) seconds
+			FROM event
+			WHERE %s
+			GROUP BY "path", fingerprint, session
 		)
-		WHERE exits > 0 %s
-		ORDER BY exits DESC, "path" ASC
-		%s`, filter.table(), filterQuery, pathFilter, filter.withLimit())
-	var stats []ExitStats
+		GROUP BY "path"
+		ORDER BY sessions DESC, "path" ASC
+		%s`, filterQuery, heartbeatFilter.withLimit())
+	stats.Pages = make([]PageEngagedTimeStats, 0)
 
-	if err := analyzer.store.Select(&stats, query, filterArgs...); err != nil {
+	if err := analyzer.store.Select(&stats.Pages, query, args...); err != nil {
 		return nil, err
 	}
 
 	return stats, nil
 }
 
-// PageConversions returns the visitor count, views, and conversion rate for conversion goals.
-// This function is supposed to be used with the Filter.PathPattern, to list page conversions.
-func (analyzer *Analyzer) PageConversions(filter *Filter) (*PageConversionsStats, error) {
+// Outbound returns the visitor count, views, and conversion rate for outbound link clicks grouped by URL.
+// See Tracker.TrackOutbound on how to track them. The event name and meta key of the filter are ignored.
+func (analyzer *Analyzer) Outbound(filter *Filter) ([]EventStats, error) {
+	return analyzer.eventBreakdown(filter, EventOutbound, EventMetaURL)
+}
+
+// Downloads returns the visitor count, views, and conversion rate for file downloads grouped by file.
+// See Tracker.TrackDownload on how to track them. The event name and meta key of the filter are ignored.
+func (analyzer *Analyzer) Downloads(filter *Filter) ([]EventStats, error) {
+	return analyzer.eventBreakdown(filter, EventDownload, EventMetaFile)
+}
+
+// Referrer returns the visitor count and bounce rate grouped by referrer.
+func (analyzer *Analyzer) Referrer(filter *Filter) ([]ReferrerStats, error) {
 	filter = analyzer.getFilter(filter)
-	filterArgsPath, filterQueryPath := filter.query()
-	filter.PathPattern = ""
+	args, filterQuery := filter.query()
 	filter.EventName = ""
-	filterArgs, filterQuery := filter.query()
-	query := fmt.Sprintf(`SELECT sum(visitors) visitors,
-		sum(views) views,
+	relativeFilterArgs, relativeFilterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT referrer,
+		referrer_name,
+		referrer_icon,
+		sum(visitors) visitors,
 		visitors / greatest((
 			SELECT count(DISTINCT fingerprint)
 			FROM hit
 			WHERE %s
-		), 1) cr
+		), 1) relative_visitors,
+		countIf(bounce = 1) bounces,
+		bounces / IF(visitors = 0, 1, visitors) bounce_rate
 		FROM (
 			SELECT count(DISTINCT fingerprint) visitors,
-			count(*) views
+			referrer,
+			referrer_name,
+			referrer_icon,
+			length(groupArray(path)) = 1 bounce
+			FROM %s
+			WHERE %s
+			GROUP BY fingerprint, referrer, referrer_name, referrer_icon
+		)
+		GROUP BY referrer, referrer_name, referrer_icon
+		ORDER BY visitors DESC, referrer ASC, referrer_name ASC
+		%s`, relativeFilterQuery, filter.table(), filterQuery, filter.withLimit())
+	relativeFilterArgs = append(relativeFilterArgs, args...)
+	stats := make([]ReferrerStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, relativeFilterArgs...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// PathReferrers returns the visitor count and bounce rate grouped by referrer for the visitors of Filter.Path.
+// The relative visitor count is relative to the visitors of the path, not the whole site.
+// Other than for Referrer, a visitor bounced if the path was the only page viewed on the site coming from the referrer.
+// The Filter.Path must be set, or otherwise the result set will be empty.
+func (analyzer *Analyzer) PathReferrers(filter *Filter) ([]ReferrerStats, error) {
+	if filter == nil || filter.Path == "" {
+		return []ReferrerStats{}, nil
+	}
+
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	pathArgs, pathFilterQuery := filter.query()
+	path := filter.Path
+	filter.Path = ""
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT referrer,
+		referrer_name,
+		referrer_icon,
+		count(DISTINCT fingerprint) visitors,
+		visitors / greatest((
+			SELECT count(DISTINCT fingerprint)
+			FROM hit
+			WHERE %s
+		), 1) relative_visitors,
+		countIf(bounce = 1) bounces,
+		bounces / IF(visitors = 0, 1, visitors) bounce_rate
+		FROM (
+			SELECT fingerprint,
+			referrer,
+			referrer_name,
+			referrer_icon,
+			countIf(path = ?) > 0 path_visited,
+			length(groupArray(path)) = 1 bounce
 			FROM hit
 			WHERE %s
+			GROUP BY fingerprint, referrer, referrer_name, referrer_icon
 		)
-		ORDER BY visitors DESC`, filterQuery, filterQueryPath)
-	args := make([]interface{}, 0, len(filterArgs)+len(filterArgsPath))
+		WHERE path_visited = 1
+		GROUP BY referrer, referrer_name, referrer_icon
+		ORDER BY visitors DESC, referrer ASC, referrer_name ASC
+		%s`, pathFilterQuery, filterQuery, filter.withLimit())
+	pathArgs = append(pathArgs, path)
+	pathArgs = append(pathArgs, args...)
+	stats := make([]ReferrerStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, pathArgs...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ReferrerLandingPages returns the entry pages for the visitors of a referrer or UTM campaign.
+// The referrer or campaign is set using the Filter.Referrer, Filter.Referrers, or Filter.UTMCampaign field.
+// Entries are the number of sessions that started on a page. An empty list is returned if none of them is set.
+func (analyzer *Analyzer) ReferrerLandingPages(filter *Filter) ([]EntryStats, error) {
+	if filter == nil || (filter.Referrer == "" && len(filter.Referrers) == 0 && filter.UTMCampaign == "") {
+		return []EntryStats{}, nil
+	}
+
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT "path",
+		count(DISTINCT fingerprint) visitors,
+		count(*) entries
+		FROM (
+			SELECT fingerprint, argMin("path", time) "path"
+			FROM %s
+			WHERE %s
+			GROUP BY fingerprint, session
+		)
+		GROUP BY "path"
+		ORDER BY visitors DESC, entries DESC, "path" ASC
+		%s`, filter.table(), filterQuery, filter.withLimit())
+	stats := make([]EntryStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// EntryReferrers returns the referrers of the sessions that started on Filter.Path.
+// Other than PathReferrers, it only counts sessions for which the path was the entry page,
+// and uses the referrer of the first hit of each session.
+// The Filter.Path must be set, or otherwise the result set will be empty.
+func (analyzer *Analyzer) EntryReferrers(filter *Filter) ([]EntryReferrerStats, error) {
+	if filter == nil || filter.Path == "" {
+		return []EntryReferrerStats{}, nil
+	}
+
+	filter = analyzer.getFilter(filter)
+	entryPath := filter.Path
+	filter.Path = ""
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	args = append(args, entryPath)
+	query := fmt.Sprintf(`SELECT entry_referrer referrer,
+		entry_referrer_name referrer_name,
+		entry_referrer_icon referrer_icon,
+		count(DISTINCT fingerprint) visitors,
+		count(*) entries
+		FROM (
+			SELECT fingerprint,
+			argMin("path", time) entry_path,
+			argMin(referrer, time) entry_referrer,
+			argMin(referrer_name, time) entry_referrer_name,
+			argMin(referrer_icon, time) entry_referrer_icon
+			FROM %s
+			WHERE %s
+			GROUP BY fingerprint, session
+		)
+		WHERE entry_path = ?
+		GROUP BY entry_referrer, entry_referrer_name, entry_referrer_icon
+		ORDER BY visitors DESC, entries DESC, referrer ASC
+		%s`, filter.table(), filterQuery, filter.withLimit())
+	stats := make([]EntryReferrerStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ReferrerBounceRate returns the visitor count, sessions, and bounce rate grouped by the referrer a session started with.
+// A session bounced if a single distinct path was viewed. Referrers with less than minVisitors are not included.
+func (analyzer *Analyzer) ReferrerBounceRate(filter *Filter, minVisitors int) ([]ReferrerBounceStats, error) {
+	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	args = append(args, minVisitors)
+	query := fmt.Sprintf(`SELECT entry_referrer referrer,
+		entry_referrer_name referrer_name,
+		entry_referrer_icon referrer_icon,
+		count(DISTINCT fingerprint) visitors,
+		count(*) sessions,
+		countIf(bounce = 1) bounces,
+		bounces / greatest(sessions, 1) bounce_rate
+		FROM (
+			SELECT fingerprint,
+			argMin(referrer, time) entry_referrer,
+			argMin(referrer_name, time) entry_referrer_name,
+			argMin(referrer_icon, time) entry_referrer_icon,
+			%s bounce
+			FROM %s
+			WHERE %s
+			GROUP BY fingerprint, session
+		)
+		GROUP BY entry_referrer, entry_referrer_name, entry_referrer_icon
+		HAVING visitors >= ?
+		ORDER BY visitors DESC, referrer ASC
+		%s`, sessionBounceQuery, filter.table(), filterQuery, filter.withLimit())
+	stats := make([]ReferrerBounceStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ReferrerByVisitorType returns the visitor count grouped by referrer, split into new and returning visitors.
+// A visitor is new on the day of the first hit ever recorded for the client (see NewReturningTrend), and returning on all following days.
+func (analyzer *Analyzer) ReferrerByVisitorType(filter *Filter) ([]ReferrerVisitorTypeStats, error) {
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.query()
+	args := make([]interface{}, 0, len(filterArgs)+1)
+	args = append(args, filter.ClientID)
 	args = append(args, filterArgs...)
-	args = append(args, filterArgsPath...)
-	stats := new(PageConversionsStats)
+	timezone := filter.Timezone.String()
+	query := fmt.Sprintf(`SELECT referrer,
+		referrer_name,
+		referrer_icon,
+		count(DISTINCT fingerprint) visitors,
+		uniqExactIf(fingerprint, toDate(time, '%s') = first_day) new_visitors,
+		visitors - new_visitors returning_visitors
+		FROM %s
+		INNER JOIN (
+			SELECT fingerprint, toDate(min(time), '%s') first_day
+			FROM hit
+			WHERE client_id = ?
+			GROUP BY fingerprint
+		) first_seen USING fingerprint
+		WHERE %s
+		GROUP BY referrer, referrer_name, referrer_icon
+		ORDER BY visitors DESC, referrer ASC
+		%s`, timezone, filter.table(), timezone, filterQuery, filter.withLimit())
+	stats := make([]ReferrerVisitorTypeStats, 0)
 
-	if err := analyzer.store.Get(stats, query, args...); err != nil {
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
 	}
 
 	return stats, nil
 }
 
-// Events returns the visitor count, views, and conversion rate for custom events.
-func (analyzer *Analyzer) Events(filter *Filter) ([]EventStats, error) {
+// Channels returns the visitor count grouped by channel.
+// The channel is determined by the referrer of the first page view of a session (see Analyzer.SetReferrerChannels).
+// Sessions without a referrer are grouped as ChannelDirect, unmatched referrers are grouped as ChannelOther.
+func (analyzer *Analyzer) Channels(filter *Filter) ([]ChannelStats, error) {
 	filter = analyzer.getFilter(filter)
-	filterArgs, filterQuery := filter.query()
+	args, filterQuery := filter.query()
 	filter.EventName = ""
-	crFilterArgs, crFilterQuery := filter.query()
-	query := fmt.Sprintf(`SELECT event_name,
-		sum(visitors) visitors,
-		sum(views) views,
+	relativeFilterArgs, relativeFilterQuery := filter.query()
+	channelArgs, channelQuery := analyzer.channelQuery()
+	query := fmt.Sprintf(`SELECT channel,
+		count(DISTINCT fingerprint) visitors,
 		visitors / greatest((
 			SELECT count(DISTINCT fingerprint)
 			FROM hit
 			WHERE %s
-		), 1) cr,
-		toUInt64(avg(avg_duration)) average_duration_seconds,
-		groupUniqArrayArray(meta_keys) meta_keys
+		), 1) relative_visitors
 		FROM (
-			SELECT event_name,
-			groupUniqArrayArray(event_meta_keys) meta_keys,
-			count(DISTINCT fingerprint) visitors,
-			count(*) views,
-			avg(event_duration_seconds) avg_duration
-			FROM event
-			WHERE %s
-			GROUP BY event_name
+			SELECT fingerprint, %s channel
+			FROM (
+				SELECT fingerprint, argMin(referrer, time) referrer
+				FROM %s
+				WHERE %s
+				GROUP BY fingerprint, session
+			)
 		)
-		GROUP BY event_name
-		ORDER BY visitors DESC, event_name
-		%s`, crFilterQuery, filterQuery, filter.withLimit())
-	args := make([]interface{}, 0, len(filterArgs)*2)
-	args = append(args, crFilterArgs...)
-	args = append(args, filterArgs...)
-	var stats []EventStats
+		GROUP BY channel
+		ORDER BY visitors DESC, channel ASC
+		%s`, relativeFilterQuery, channelQuery, filter.table(), filterQuery, filter.withLimit())
+	relativeFilterArgs = append(relativeFilterArgs, channelArgs...)
+	relativeFilterArgs = append(relativeFilterArgs, args...)
+	stats := make([]ChannelStats, 0)
 
-	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+	if err := analyzer.store.Select(&stats, query, relativeFilterArgs...); err != nil {
 		return nil, err
 	}
 
 	return stats, nil
 }
 
-// EventBreakdown returns the visitor count, views, and conversion rate for a custom event grouping them by a meta value for given key.
-// The Filter.EventName and Filter.EventMetaKey must be set, or otherwise the result set will be empty.
-func (analyzer *Analyzer) EventBreakdown(filter *Filter) ([]EventStats, error) {
+// StatusCodes returns the visitor count and page views grouped by HTTP status code.
+// Hits without a status code are not included. Use Filter.StatusCode together with Pages to find the paths for a status code.
+func (analyzer *Analyzer) StatusCodes(filter *Filter) ([]StatusCodeStats, error) {
 	filter = analyzer.getFilter(filter)
+	filter.EventName = ""
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT status_code,
+		count(DISTINCT fingerprint) visitors,
+		count(*) views
+		FROM hit
+		WHERE %s
+		AND status_code != 0
+		GROUP BY status_code
+		ORDER BY views DESC, status_code ASC
+		%s`, filterQuery, filter.withLimit())
+	stats := make([]StatusCodeStats, 0)
 
-	if filter.EventName == "" || filter.EventMetaKey == "" {
-		return []EventStats{}, nil
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
 	}
 
-	filterArgs, filterQuery := filter.query()
+	return stats, nil
+}
+
+// Methods returns the visitor count and page views grouped by HTTP method.
+// Hits without a method are not included.
+func (analyzer *Analyzer) Methods(filter *Filter) ([]MethodStats, error) {
+	filter = analyzer.getFilter(filter)
 	filter.EventName = ""
-	crFilterArgs, crFilterQuery := filter.query()
-	query := fmt.Sprintf(`SELECT event_name,
-		sum(visitors) visitors,
-		sum(views) views,
-		visitors / greatest((
-			SELECT count(DISTINCT fingerprint)
-			FROM hit
-			WHERE %s
-		), 1) cr,
-		toUInt64(avg(avg_duration)) average_duration_seconds,
-		meta_value
-		FROM (
-			SELECT event_name,
-			count(DISTINCT fingerprint) visitors,
-			count(*) views,
-			avg(event_duration_seconds) avg_duration,
-			event_meta_values[indexOf(event_meta_keys, ?)] meta_value
-			FROM event
-			WHERE %s
-			AND has(event_meta_keys, ?)
-			GROUP BY event_name, meta_value
-		)
-		GROUP BY event_name, meta_value
-		ORDER BY visitors DESC, meta_value
-		%s`, crFilterQuery, filterQuery, filter.withLimit())
-	args := make([]interface{}, 0, len(filterArgs)*2)
-	args = append(args, crFilterArgs...)
-	args = append(args, filter.EventMetaKey)
-	args = append(args, filterArgs...)
-	args = append(args, filter.EventMetaKey)
-	var stats []EventStats
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT method,
+		count(DISTINCT fingerprint) visitors,
+		count(*) views
+		FROM hit
+		WHERE %s
+		AND method != ''
+		GROUP BY method
+		ORDER BY views DESC, method ASC
+		%s`, filterQuery, filter.withLimit())
+	stats := make([]MethodStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
@@ -506,40 +2256,21 @@ func (analyzer *Analyzer) EventBreakdown(filter *Filter) ([]EventStats, error) {
 	return stats, nil
 }
 
-// Referrer returns the visitor count and bounce rate grouped by referrer.
-func (analyzer *Analyzer) Referrer(filter *Filter) ([]ReferrerStats, error) {
+// SearchTerms returns the number of searches and visitors grouped by search query.
+// Hits without a search query are ignored. See HitOptions.SearchQueryParameter on how to store search queries.
+func (analyzer *Analyzer) SearchTerms(filter *Filter) ([]SearchTermStats, error) {
 	filter = analyzer.getFilter(filter)
 	args, filterQuery := filter.query()
-	filter.EventName = ""
-	relativeFilterArgs, relativeFilterQuery := filter.query()
-	query := fmt.Sprintf(`SELECT referrer,
-		referrer_name,
-		referrer_icon,
-		sum(visitors) visitors,
-		visitors / greatest((
-			SELECT count(DISTINCT fingerprint)
-			FROM hit
-			WHERE %s
-		), 1) relative_visitors,
-		countIf(bounce = 1) bounces,
-		bounces / IF(visitors = 0, 1, visitors) bounce_rate
-		FROM (
-			SELECT count(DISTINCT fingerprint) visitors,
-			referrer,
-			referrer_name,
-			referrer_icon,
-			length(groupArray(path)) = 1 bounce
-			FROM %s
-			WHERE %s
-			GROUP BY fingerprint, referrer, referrer_name, referrer_icon
-		)
-		GROUP BY referrer, referrer_name, referrer_icon
-		ORDER BY visitors DESC
-		%s`, relativeFilterQuery, filter.table(), filterQuery, filter.withLimit())
-	relativeFilterArgs = append(relativeFilterArgs, args...)
-	var stats []ReferrerStats
+	query := fmt.Sprintf(`SELECT search_query, count(*) searches, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		AND search_query != ''
+		GROUP BY search_query
+		ORDER BY searches DESC, visitors DESC, search_query ASC
+		%s`, filter.table(), filterQuery, filter.withLimit())
+	stats := make([]SearchTermStats, 0)
 
-	if err := analyzer.store.Select(&stats, query, relativeFilterArgs...); err != nil {
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
 	}
 
@@ -547,8 +2278,10 @@ func (analyzer *Analyzer) Referrer(filter *Filter) ([]ReferrerStats, error) {
 }
 
 // Platform returns the visitor count grouped by platform.
+// Tablets are counted separately and are not included in the mobile platform.
 func (analyzer *Analyzer) Platform(filter *Filter) (*PlatformStats, error) {
-	filterArgs, filterQuery := analyzer.getFilter(filter).query()
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.query()
 	table := filter.table()
 	query := fmt.Sprintf(`SELECT (
 			SELECT count(DISTINCT fingerprint)
@@ -563,19 +2296,26 @@ func (analyzer *Analyzer) Platform(filter *Filter) (*PlatformStats, error) {
 			WHERE %s
 			AND desktop = 0
 			AND mobile = 1
+			AND tablet = 0
 		) AS "platform_mobile",
+		(
+			SELECT count(DISTINCT fingerprint)
+			FROM %s
+			WHERE %s
+			AND tablet = 1
+		) AS "platform_tablet",
 		(
 			SELECT count(DISTINCT fingerprint)
 			FROM %s
 			WHERE %s
 			AND desktop = 0
 			AND mobile = 0
+			AND tablet = 0
 		) AS "platform_unknown",
-		"platform_desktop" / IF("platform_desktop" + "platform_mobile" + "platform_unknown" = 0, 1, "platform_desktop" + "platform_mobile" + "platform_unknown") AS relative_platform_desktop,
-		"platform_mobile" / IF("platform_desktop" + "platform_mobile" + "platform_unknown" = 0, 1, "platform_desktop" + "platform_mobile" + "platform_unknown") AS relative_platform_mobile,
-		"platform_unknown" / IF("platform_desktop" + "platform_mobile" + "platform_unknown" = 0, 1, "platform_desktop" + "platform_mobile" + "platform_unknown") AS relative_platform_unknown`,
-		table, filterQuery, table, filterQuery, table, filterQuery)
-	args := make([]interface{}, 0, len(filterArgs)*3)
+		%s`,
+		table, filterQuery, table, filterQuery, table, filterQuery, table, filterQuery, relativePlatformQuery)
+	args := make([]interface{}, 0, len(filterArgs)*4)
+	args = append(args, filterArgs...)
 	args = append(args, filterArgs...)
 	args = append(args, filterArgs...)
 	args = append(args, filterArgs...)
@@ -588,9 +2328,34 @@ func (analyzer *Analyzer) Platform(filter *Filter) (*PlatformStats, error) {
 	return stats, nil
 }
 
+// PlatformTrend returns the visitor count grouped by platform and day.
+func (analyzer *Analyzer) PlatformTrend(filter *Filter) ([]PlatformTrendStats, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	withFillArgs, withFillQuery := filter.withFill()
+	args = append(args, withFillArgs...)
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day,
+		uniqExactIf(fingerprint, desktop = 1 AND mobile = 0) "platform_desktop",
+		uniqExactIf(fingerprint, desktop = 0 AND mobile = 1 AND tablet = 0) "platform_mobile",
+		uniqExactIf(fingerprint, tablet = 1) "platform_tablet",
+		uniqExactIf(fingerprint, desktop = 0 AND mobile = 0 AND tablet = 0) "platform_unknown",
+		%s
+		FROM %s
+		WHERE %s
+		GROUP BY day
+		ORDER BY day ASC %s`, filter.Timezone.String(), relativePlatformQuery, filter.table(), filterQuery, withFillQuery)
+	stats := make([]PlatformTrendStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
 // Languages returns the visitor count grouped by language.
 func (analyzer *Analyzer) Languages(filter *Filter) ([]LanguageStats, error) {
-	var stats []LanguageStats
+	stats := make([]LanguageStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "language"); err != nil {
 		return nil, err
@@ -599,9 +2364,21 @@ func (analyzer *Analyzer) Languages(filter *Filter) ([]LanguageStats, error) {
 	return stats, nil
 }
 
+// Locales returns the visitor count grouped by locale (like en-US).
+// The locale is only stored if HitOptions.StoreLocale is set.
+func (analyzer *Analyzer) Locales(filter *Filter) ([]LocaleStats, error) {
+	stats := make([]LocaleStats, 0)
+
+	if err := analyzer.selectByAttribute(&stats, filter, "locale"); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
 // Countries returns the visitor count grouped by country.
 func (analyzer *Analyzer) Countries(filter *Filter) ([]CountryStats, error) {
-	var stats []CountryStats
+	stats := make([]CountryStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "country_code"); err != nil {
 		return nil, err
@@ -612,7 +2389,7 @@ func (analyzer *Analyzer) Countries(filter *Filter) ([]CountryStats, error) {
 
 // Browser returns the visitor count grouped by browser.
 func (analyzer *Analyzer) Browser(filter *Filter) ([]BrowserStats, error) {
-	var stats []BrowserStats
+	stats := make([]BrowserStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "browser"); err != nil {
 		return nil, err
@@ -623,7 +2400,7 @@ func (analyzer *Analyzer) Browser(filter *Filter) ([]BrowserStats, error) {
 
 // OS returns the visitor count grouped by operating system.
 func (analyzer *Analyzer) OS(filter *Filter) ([]OSStats, error) {
-	var stats []OSStats
+	stats := make([]OSStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "os"); err != nil {
 		return nil, err
@@ -634,7 +2411,7 @@ func (analyzer *Analyzer) OS(filter *Filter) ([]OSStats, error) {
 
 // ScreenClass returns the visitor count grouped by screen class.
 func (analyzer *Analyzer) ScreenClass(filter *Filter) ([]ScreenClassStats, error) {
-	var stats []ScreenClassStats
+	stats := make([]ScreenClassStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "screen_class"); err != nil {
 		return nil, err
@@ -643,9 +2420,41 @@ func (analyzer *Analyzer) ScreenClass(filter *Filter) ([]ScreenClassStats, error
 	return stats, nil
 }
 
+// ScreenPercentiles returns the 50th, 75th, 90th, and 95th percentile of the screen width and height across visitors.
+// Each visitor is counted once using the largest screen, hits without a screen size are ignored.
+func (analyzer *Analyzer) ScreenPercentiles(filter *Filter) (*ScreenPercentileStats, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT count(*) visitors,
+		toUInt64(quantileExact(0.5)(width)) width_p50,
+		toUInt64(quantileExact(0.75)(width)) width_p75,
+		toUInt64(quantileExact(0.9)(width)) width_p90,
+		toUInt64(quantileExact(0.95)(width)) width_p95,
+		toUInt64(quantileExact(0.5)(height)) height_p50,
+		toUInt64(quantileExact(0.75)(height)) height_p75,
+		toUInt64(quantileExact(0.9)(height)) height_p90,
+		toUInt64(quantileExact(0.95)(height)) height_p95
+		FROM (
+			SELECT fingerprint,
+			max(screen_width) width,
+			max(screen_height) height
+			FROM %s
+			WHERE %s
+			AND screen_width > 0
+			GROUP BY fingerprint
+		)`, filter.table(), filterQuery)
+	stats := new(ScreenPercentileStats)
+
+	if err := analyzer.store.Get(stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
 // UTMSource returns the visitor count grouped by utm source.
 func (analyzer *Analyzer) UTMSource(filter *Filter) ([]UTMSourceStats, error) {
-	var stats []UTMSourceStats
+	stats := make([]UTMSourceStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "utm_source"); err != nil {
 		return nil, err
@@ -654,9 +2463,43 @@ func (analyzer *Analyzer) UTMSource(filter *Filter) ([]UTMSourceStats, error) {
 	return stats, nil
 }
 
+// UTMSourceMedium returns the visitor count grouped by the pair of utm source and medium (like "google / cpc").
+// Visitors without a utm source and medium are not included. If only one of them is set, the other one is set to UTMNotSet.
+func (analyzer *Analyzer) UTMSourceMedium(filter *Filter) ([]UTMSourceMediumStats, error) {
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.query()
+	filter.EventName = ""
+	relativeFilterArgs, relativeFilterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT if(utm_source = '', ?, utm_source) source,
+		if(utm_medium = '', ?, utm_medium) medium,
+		count(DISTINCT fingerprint) visitors,
+		visitors / greatest((
+			SELECT count(DISTINCT fingerprint)
+			FROM hit
+			WHERE %s
+		), 1) relative_visitors
+		FROM %s
+		WHERE %s
+		AND (utm_source != '' OR utm_medium != '')
+		GROUP BY source, medium
+		ORDER BY visitors DESC, source ASC, medium ASC
+		%s`, relativeFilterQuery, filter.table(), filterQuery, filter.withLimit())
+	args := make([]interface{}, 0, len(relativeFilterArgs)+len(filterArgs)+2)
+	args = append(args, UTMNotSet, UTMNotSet)
+	args = append(args, relativeFilterArgs...)
+	args = append(args, filterArgs...)
+	stats := make([]UTMSourceMediumStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
 // UTMMedium returns the visitor count grouped by utm medium.
 func (analyzer *Analyzer) UTMMedium(filter *Filter) ([]UTMMediumStats, error) {
-	var stats []UTMMediumStats
+	stats := make([]UTMMediumStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "utm_medium"); err != nil {
 		return nil, err
@@ -667,7 +2510,7 @@ func (analyzer *Analyzer) UTMMedium(filter *Filter) ([]UTMMediumStats, error) {
 
 // UTMCampaign returns the visitor count grouped by utm source.
 func (analyzer *Analyzer) UTMCampaign(filter *Filter) ([]UTMCampaignStats, error) {
-	var stats []UTMCampaignStats
+	stats := make([]UTMCampaignStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "utm_campaign"); err != nil {
 		return nil, err
@@ -678,7 +2521,7 @@ func (analyzer *Analyzer) UTMCampaign(filter *Filter) ([]UTMCampaignStats, error
 
 // UTMContent returns the visitor count grouped by utm source.
 func (analyzer *Analyzer) UTMContent(filter *Filter) ([]UTMContentStats, error) {
-	var stats []UTMContentStats
+	stats := make([]UTMContentStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "utm_content"); err != nil {
 		return nil, err
@@ -689,7 +2532,7 @@ func (analyzer *Analyzer) UTMContent(filter *Filter) ([]UTMContentStats, error)
 
 // UTMTerm returns the visitor count grouped by utm source.
 func (analyzer *Analyzer) UTMTerm(filter *Filter) ([]UTMTermStats, error) {
-	var stats []UTMTermStats
+	stats := make([]UTMTermStats, 0)
 
 	if err := analyzer.selectByAttribute(&stats, filter, "utm_term"); err != nil {
 		return nil, err
@@ -698,6 +2541,64 @@ func (analyzer *Analyzer) UTMTerm(filter *Filter) ([]UTMTermStats, error) {
 	return stats, nil
 }
 
+// PagesWithTotal returns the same result as Analyzer.Pages, together with the total number of unique visitors for the filter.
+// The total is less or equal to the sum of the visitors in the breakdown, as visitors can show up in multiple rows (by visiting multiple pages for example).
+// Use it instead of the sum to show the number of unique visitors. This applies to all ...WithTotal methods.
+// Stats is of type []PageStats.
+func (analyzer *Analyzer) PagesWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.Pages(filter)
+	})
+}
+
+// ReferrerWithTotal returns the same result as Analyzer.Referrer, together with the total number of unique visitors for the filter.
+// Stats is of type []ReferrerStats.
+func (analyzer *Analyzer) ReferrerWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.Referrer(filter)
+	})
+}
+
+// LanguagesWithTotal returns the same result as Analyzer.Languages, together with the total number of unique visitors for the filter.
+// Stats is of type []LanguageStats.
+func (analyzer *Analyzer) LanguagesWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.Languages(filter)
+	})
+}
+
+// CountriesWithTotal returns the same result as Analyzer.Countries, together with the total number of unique visitors for the filter.
+// Stats is of type []CountryStats.
+func (analyzer *Analyzer) CountriesWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.Countries(filter)
+	})
+}
+
+// BrowserWithTotal returns the same result as Analyzer.Browser, together with the total number of unique visitors for the filter.
+// Stats is of type []BrowserStats.
+func (analyzer *Analyzer) BrowserWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.Browser(filter)
+	})
+}
+
+// OSWithTotal returns the same result as Analyzer.OS, together with the total number of unique visitors for the filter.
+// Stats is of type []OSStats.
+func (analyzer *Analyzer) OSWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.OS(filter)
+	})
+}
+
+// ScreenClassWithTotal returns the same result as Analyzer.ScreenClass, together with the total number of unique visitors for the filter.
+// Stats is of type []ScreenClassStats.
+func (analyzer *Analyzer) ScreenClassWithTotal(filter *Filter) (*StatsWithTotal, error) {
+	return analyzer.withTotal(filter, func(filter *Filter) (interface{}, error) {
+		return analyzer.ScreenClass(filter)
+	})
+}
+
 // OSVersion returns the visitor count grouped by operating systems and version.
 func (analyzer *Analyzer) OSVersion(filter *Filter) ([]OSVersionStats, error) {
 	filter = analyzer.getFilter(filter)
@@ -715,7 +2616,7 @@ func (analyzer *Analyzer) OSVersion(filter *Filter) ([]OSVersionStats, error) {
 		ORDER BY visitors DESC, os, os_version
 		%s`, relativeFilterQuery, filter.table(), filterQuery, filter.withLimit())
 	relativeFilterArgs = append(relativeFilterArgs, args...)
-	var stats []OSVersionStats
+	stats := make([]OSVersionStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, relativeFilterArgs...); err != nil {
 		return nil, err
@@ -741,13 +2642,125 @@ func (analyzer *Analyzer) BrowserVersion(filter *Filter) ([]BrowserVersionStats,
 		ORDER BY visitors DESC, browser, browser_version
 		%s`, relativeFilterQuery, filter.table(), filterQuery, filter.withLimit())
 	relativeFilterArgs = append(relativeFilterArgs, args...)
-	var stats []BrowserVersionStats
+	stats := make([]BrowserVersionStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, relativeFilterArgs...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// BrowserTrend returns the share of visitors grouped by browser and day.
+// The result contains the top browsers (see Filter.Limit, 5 by default), all other browsers are summarized as TrendOther.
+func (analyzer *Analyzer) BrowserTrend(filter *Filter) ([]TrendStats, error) {
+	return analyzer.selectTrend(filter, "browser")
+}
+
+// OSTrend returns the share of visitors grouped by operating system and day.
+// The result contains the top operating systems (see Filter.Limit, 5 by default), all other operating systems are summarized as TrendOther.
+func (analyzer *Analyzer) OSTrend(filter *Filter) ([]TrendStats, error) {
+	return analyzer.selectTrend(filter, "os")
+}
+
+// BrowserComparison returns the visitor count grouped by browser for the selected and the previous period.
+// See ReferrerComparison for details.
+func (analyzer *Analyzer) BrowserComparison(filter *Filter) ([]ComparisonStats, error) {
+	return analyzer.compareByAttribute(filter, "browser")
+}
+
+// OSComparison returns the visitor count grouped by operating system for the selected and the previous period.
+// See ReferrerComparison for details.
+func (analyzer *Analyzer) OSComparison(filter *Filter) ([]ComparisonStats, error) {
+	return analyzer.compareByAttribute(filter, "os")
+}
+
+// CountryComparison returns the visitor count grouped by country code for the selected and the previous period.
+// See ReferrerComparison for details.
+func (analyzer *Analyzer) CountryComparison(filter *Filter) ([]ComparisonStats, error) {
+	return analyzer.compareByAttribute(filter, "country_code")
+}
+
+// LanguageComparison returns the visitor count grouped by language for the selected and the previous period.
+// See ReferrerComparison for details.
+func (analyzer *Analyzer) LanguageComparison(filter *Filter) ([]ComparisonStats, error) {
+	return analyzer.compareByAttribute(filter, "language")
+}
+
+// ReferrerComparison returns the visitor count grouped by referrer for the selected and the previous period.
+// The previous period is calculated the same way as for Filter.Compare.
+// Keys that only appear in one of the periods have their visitor count set to zero for the other.
+// The result is sorted by visitors and previous visitors and limited using Filter.Limit.
+// The period or day for the filter must be set, else an error is returned.
+func (analyzer *Analyzer) ReferrerComparison(filter *Filter) ([]ComparisonStats, error) {
+	return analyzer.compareByAttribute(filter, "referrer")
+}
+
+// CountryTrend returns the share of visitors grouped by day for given ISO country codes.
+// The result contains one series per country in the requested order, missing days are filled with zeros.
+// The relative visitor count is relative to all visitors on that day.
+// The country codes are compared case-insensitive and duplicates are ignored.
+// A maximum of 10 distinct countries can be requested, else an error is returned.
+func (analyzer *Analyzer) CountryTrend(filter *Filter, countries []string) ([]TrendStats, error) {
+	codes := make([]string, 0, len(countries))
+	args := make([]interface{}, 0, len(countries))
+
+	for _, country := range countries {
+		country = strings.ToLower(strings.TrimSpace(country))
+
+		if country != "" && !containsString(codes, country) {
+			codes = append(codes, country)
+			args = append(args, country)
+		}
+	}
+
+	if len(codes) > maxTrendCountries {
+		return nil, ErrTooManyCountries
+	}
+
+	if len(codes) == 0 {
+		return []TrendStats{}, nil
+	}
+
+	filter = analyzer.getFilter(filter)
+	filterArgs, filterQuery := filter.query()
+	args = append(args, filterArgs...)
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day, if(lower(country_code) IN (%s), lower(country_code), '') name, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY day, name
+		ORDER BY day ASC, visitors DESC, name ASC`, filter.Timezone.String(), strings.TrimSuffix(strings.Repeat("?,", len(codes)), ","), filter.table(), filterQuery)
+	var stats []trendDayStats
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	visitors := make(map[string]map[int64]int)
+	dayTotals := make(map[int64]int)
+
+	for _, s := range stats {
+		if _, found := visitors[s.Name]; !found {
+			visitors[s.Name] = make(map[int64]int)
+		}
 
-	if err := analyzer.store.Select(&stats, query, relativeFilterArgs...); err != nil {
-		return nil, err
+		visitors[s.Name][s.Day.Unix()] += s.Visitors
+		dayTotals[s.Day.Unix()] += s.Visitors
 	}
 
-	return stats, nil
+	days := filter.days()
+
+	if days == nil {
+		days = analyzer.daysFromTrend(stats)
+	}
+
+	trend := make([]TrendStats, 0, len(codes))
+
+	for _, code := range codes {
+		trend = append(trend, analyzer.trendSeries(code, days, dayTotals, visitors[code]))
+	}
+
+	return trend, nil
 }
 
 // AvgSessionDuration returns the average session duration grouped by day.
@@ -767,7 +2780,7 @@ func (analyzer *Analyzer) AvgSessionDuration(filter *Filter) ([]TimeSpentStats,
 		WHERE duration != 0
 		GROUP BY day
 		ORDER BY day %s`, filter.Timezone.String(), filterQuery, withFillQuery)
-	var stats []TimeSpentStats
+	stats := make([]TimeSpentStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, args...); err != nil {
 		return nil, err
@@ -800,6 +2813,7 @@ func (analyzer *Analyzer) TotalSessionDuration(filter *Filter) (int, error) {
 }
 
 // AvgTimeOnPages returns the average time on page grouped by path.
+// The result is ordered by the number of visitors and path and limited by Filter.Limit.
 func (analyzer *Analyzer) AvgTimeOnPages(filter *Filter) ([]TimeSpentStats, error) {
 	filter = analyzer.getFilter(filter)
 	timeArgs, timeQuery := filter.queryTime()
@@ -811,7 +2825,7 @@ func (analyzer *Analyzer) AvgTimeOnPages(filter *Filter) ([]TimeSpentStats, erro
 
 	query := fmt.Sprintf(`SELECT path, toUInt64(avg(time_on_page)) average_time_spent_seconds
 		FROM (
-			SELECT path, %s time_on_page
+			SELECT path, fingerprint, %s time_on_page
 			FROM (
 				SELECT *
 				FROM hit
@@ -822,9 +2836,10 @@ func (analyzer *Analyzer) AvgTimeOnPages(filter *Filter) ([]TimeSpentStats, erro
 			%s
 		)
 		GROUP BY path
-		ORDER BY path`, analyzer.timeOnPageQuery(filter), timeQuery, fieldQuery)
+		ORDER BY count(DISTINCT fingerprint) DESC, path ASC
+		%s`, analyzer.timeOnPageQuery(filter), timeQuery, fieldQuery, filter.withLimit())
 	timeArgs = append(timeArgs, fieldArgs...)
-	var stats []TimeSpentStats
+	stats := make([]TimeSpentStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, timeArgs...); err != nil {
 		return nil, err
@@ -860,7 +2875,7 @@ func (analyzer *Analyzer) AvgTimeOnPage(filter *Filter) ([]TimeSpentStats, error
 		ORDER BY day %s`, filter.Timezone.String(), analyzer.timeOnPageQuery(filter), timeQuery, fieldQuery, withFillQuery)
 	timeArgs = append(timeArgs, fieldArgs...)
 	timeArgs = append(timeArgs, withFillArgs...)
-	var stats []TimeSpentStats
+	stats := make([]TimeSpentStats, 0)
 
 	if err := analyzer.store.Select(&stats, query, timeArgs...); err != nil {
 		return nil, err
@@ -902,6 +2917,59 @@ func (analyzer *Analyzer) TotalTimeOnPage(filter *Filter) (int, error) {
 	return stats.AverageTimeSpentSeconds, nil
 }
 
+func (analyzer *Analyzer) totalVisitors(filter *Filter) (*TotalVisitorStats, error) {
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT sum(visitors) visitors,
+		sum(sessions) sessions,
+		sum(views) views,
+		sum(bounce) bounces
+		FROM (
+			SELECT count(DISTINCT fingerprint) visitors,
+			count(DISTINCT(fingerprint, session)) sessions,
+			count(*) views,
+			%s bounce
+			FROM %s
+			WHERE %s
+			GROUP BY toDate(time, '%s'), fingerprint
+		)`, analyzer.bounceQuery(filter), filter.table(), filterQuery, filter.Timezone.String())
+	stats := new(growthStats)
+
+	if err := analyzer.store.Get(stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	var timeSpent int
+	var err error
+
+	if filter.Path == "" {
+		timeSpent, err = analyzer.TotalSessionDuration(filter)
+	} else {
+		timeSpent, err = analyzer.TotalTimeOnPage(filter)
+	}
+
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		return nil, err
+	}
+
+	return &TotalVisitorStats{
+		Visitors:         stats.Visitors,
+		Views:            stats.Views,
+		Sessions:         stats.Sessions,
+		Bounces:          stats.Bounces,
+		TimeSpentSeconds: timeSpent,
+	}, nil
+}
+
+func (analyzer *Analyzer) growth(current, previous *TotalVisitorStats) *Growth {
+	return &Growth{
+		VisitorsGrowth:  analyzer.calculateGrowth(current.Visitors, previous.Visitors),
+		ViewsGrowth:     analyzer.calculateGrowth(current.Views, previous.Views),
+		SessionsGrowth:  analyzer.calculateGrowth(current.Sessions, previous.Sessions),
+		BouncesGrowth:   analyzer.calculateGrowth(current.Bounces, previous.Bounces),
+		TimeSpentGrowth: analyzer.calculateGrowth(current.TimeSpentSeconds, previous.TimeSpentSeconds),
+	}
+}
+
 func (analyzer *Analyzer) calculateGrowth(current, previous int) float64 {
 	if current == 0 && previous == 0 {
 		return 0
@@ -914,6 +2982,103 @@ func (analyzer *Analyzer) calculateGrowth(current, previous int) float64 {
 	return (c - p) / p
 }
 
+func (analyzer *Analyzer) calculateDayTypeStats(stats *DayTypeStats, sessionBounces bool) {
+	if stats.Days > 0 {
+		stats.AvgVisitors = float64(stats.Visitors) / float64(stats.Days)
+		stats.AvgSessions = float64(stats.Sessions) / float64(stats.Days)
+		stats.AvgBounces = float64(stats.Bounces) / float64(stats.Days)
+	}
+
+	base := stats.Visitors
+
+	if sessionBounces {
+		base = stats.Sessions
+	}
+
+	if base > 0 {
+		stats.BounceRate = float64(stats.Bounces) / float64(base)
+	}
+}
+
+// fillDays adds the days missing between the first and the last day of the statistics with zeros.
+// Days excluded by the filter are left out, as they are by Analyzer.Visitors.
+func (analyzer *Analyzer) fillDays(filter *Filter, stats []VisitorStats) []VisitorStats {
+	if len(stats) == 0 {
+		return stats
+	}
+
+	last := stats[len(stats)-1].Day
+	result := make([]VisitorStats, 0, len(stats))
+	i := 0
+
+	for day := stats[0].Day; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if i < len(stats) && stats[i].Day.Equal(day) {
+			result = append(result, stats[i])
+			i++
+		} else if !filter.excludedDay(day) {
+			result = append(result, VisitorStats{Day: day})
+		}
+	}
+
+	return result
+}
+
+func (analyzer *Analyzer) findAnomalies(stats []VisitorStats, window int, sigma float64) []AnomalyStats {
+	anomalies := make([]AnomalyStats, 0)
+
+	for i := window; i < len(stats); i++ {
+		mean := 0.0
+
+		for _, s := range stats[i-window : i] {
+			mean += float64(s.Visitors)
+		}
+
+		mean /= float64(window)
+		variance := 0.0
+
+		for _, s := range stats[i-window : i] {
+			variance += math.Pow(float64(s.Visitors)-mean, 2)
+		}
+
+		stdDev := math.Sqrt(variance / float64(window))
+
+		if stdDev == 0 {
+			continue
+		}
+
+		zScore := (float64(stats[i].Visitors) - mean) / stdDev
+
+		if math.Abs(zScore) >= sigma {
+			anomalies = append(anomalies, AnomalyStats{
+				Day:      stats[i].Day,
+				Visitors: stats[i].Visitors,
+				Mean:     mean,
+				StdDev:   stdDev,
+				ZScore:   zScore,
+			})
+		}
+	}
+
+	return anomalies
+}
+
+func (analyzer *Analyzer) bounceQuery(filter *Filter) string {
+	if filter.SessionBounces {
+		// the number of sessions with a single distinct path (see sessionBounceQuery)
+		return "arrayCount(s -> countEqual(arrayMap(p -> p.1, groupUniqArray((session, path))), s) = 1, arrayDistinct(groupArray(session)))"
+	}
+
+	return "length(groupArray(path)) = 1"
+}
+
+func (analyzer *Analyzer) bounceRateBase(filter *Filter) string {
+	if filter.SessionBounces {
+		return "sessions"
+	}
+
+	return "visitors"
+}
+
 func (analyzer *Analyzer) timeOnPageQuery(filter *Filter) string {
 	timeOnPage := "neighbor(previous_time_on_page_seconds, 1, 0)"
 
@@ -924,6 +3089,124 @@ func (analyzer *Analyzer) timeOnPageQuery(filter *Filter) string {
 	return timeOnPage
 }
 
+func (analyzer *Analyzer) channelQuery() ([]interface{}, string) {
+	// the map is replaced instead of being modified, so it can be read without holding the lock
+	channels := analyzer.getReferrerChannels()
+	hostnames := make([]string, 0, len(channels))
+
+	for hostname := range channels {
+		hostnames = append(hostnames, hostname)
+	}
+
+	sort.Slice(hostnames, func(i, j int) bool {
+		if len(hostnames[i]) == len(hostnames[j]) {
+			return hostnames[i] < hostnames[j]
+		}
+
+		return len(hostnames[i]) > len(hostnames[j])
+	})
+	host := "lower(if(domain(referrer) = '', referrer, domain(referrer)))"
+	args := make([]interface{}, 0, len(hostnames)*3+2)
+	args = append(args, ChannelDirect)
+	var query strings.Builder
+	query.WriteString("multiIf(referrer = '', ?, ")
+
+	for _, hostname := range hostnames {
+		args = append(args, hostname, "."+hostname, channels[hostname])
+		query.WriteString(fmt.Sprintf("%s = ? OR endsWith(%s, ?), ?, ", host, host))
+	}
+
+	args = append(args, ChannelOther)
+	query.WriteString("?)")
+	return args, query.String()
+}
+
+func (analyzer *Analyzer) eventBreakdown(filter *Filter, name, metaKey string) ([]EventStats, error) {
+	eventFilter := NewFilter(NullClient)
+
+	if filter != nil {
+		*eventFilter = *filter
+	}
+
+	eventFilter.EventName = name
+	eventFilter.EventMetaKey = metaKey
+	return analyzer.EventBreakdown(eventFilter)
+}
+
+func (analyzer *Analyzer) peak(filter *Filter, fn string) (*periodStats, error) {
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT %s(time, '%s') period, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY period
+		ORDER BY visitors DESC, period ASC
+		LIMIT 1`, fn, filter.Timezone.String(), filter.table(), filterQuery)
+	var stats []periodStats
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return new(periodStats), nil
+	}
+
+	return &stats[0], nil
+}
+
+func (analyzer *Analyzer) withTotal(filter *Filter, breakdown func(*Filter) (interface{}, error)) (*StatsWithTotal, error) {
+	filter = analyzer.getFilter(filter)
+	total, err := analyzer.breakdownTotal(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := breakdown(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsWithTotal{Stats: stats, Visitors: total}, nil
+}
+
+func (analyzer *Analyzer) breakdownTotal(filter *Filter) (int, error) {
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT count(DISTINCT fingerprint) FROM %s WHERE %s`, filter.table(), filterQuery)
+	return analyzer.store.Count(query, args...)
+}
+
+func (analyzer *Analyzer) sessionIDSalt() (string, error) {
+	salt := make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(salt), nil
+}
+
+func (analyzer *Analyzer) withOffset(offset int) string {
+	if offset > 0 {
+		return fmt.Sprintf("OFFSET %d ", offset)
+	}
+
+	return ""
+}
+
+func (analyzer *Analyzer) topItemFilter(filter *Filter) *Filter {
+	topFilter := NewFilter(NullClient)
+
+	if filter != nil {
+		*topFilter = *filter
+	}
+
+	topFilter.Limit = 1
+	topFilter.IncludeAvgTimeOnPage = false
+	return topFilter
+}
+
 func (analyzer *Analyzer) selectByAttribute(results interface{}, filter *Filter, attr string) error {
 	filter = analyzer.getFilter(filter)
 	args, filterQuery := filter.query()
@@ -932,6 +3215,219 @@ func (analyzer *Analyzer) selectByAttribute(results interface{}, filter *Filter,
 	return analyzer.store.Select(results, query, args...)
 }
 
+func (analyzer *Analyzer) compareByAttribute(filter *Filter, attr string) ([]ComparisonStats, error) {
+	filter = analyzer.getFilter(filter)
+	stats, err := analyzer.comparisonByAttribute(filter, attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Visitors != stats[j].Visitors {
+			return stats[i].Visitors > stats[j].Visitors
+		}
+
+		if stats[i].PreviousVisitors != stats[j].PreviousVisitors {
+			return stats[i].PreviousVisitors > stats[j].PreviousVisitors
+		}
+
+		return stats[i].Key < stats[j].Key
+	})
+
+	if filter.Limit > 0 && len(stats) > filter.Limit {
+		stats = stats[:filter.Limit]
+	}
+
+	return stats, nil
+}
+
+// comparisonByAttribute returns the visitors for the filter and the previous period merged by given attribute, in no particular order.
+// The filter must have been validated.
+func (analyzer *Analyzer) comparisonByAttribute(filter *Filter, attr string) ([]ComparisonStats, error) {
+	if filter.Day.IsZero() && (filter.From.IsZero() || filter.To.IsZero()) {
+		return nil, ErrNoPeriodOrDay
+	}
+
+	current, err := analyzer.visitorsByAttribute(filter, attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := analyzer.visitorsByAttribute(filter.previousPeriod(), attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]ComparisonStats, 0, len(current)+len(previous))
+	index := make(map[string]int, len(current)+len(previous))
+
+	for _, entry := range current {
+		index[entry.Key] = len(stats)
+		stats = append(stats, ComparisonStats{Key: entry.Key, Visitors: entry.Visitors})
+	}
+
+	for _, entry := range previous {
+		if i, found := index[entry.Key]; found {
+			stats[i].PreviousVisitors = entry.Visitors
+		} else {
+			stats = append(stats, ComparisonStats{Key: entry.Key, PreviousVisitors: entry.Visitors})
+		}
+	}
+
+	return stats, nil
+}
+
+func (analyzer *Analyzer) visitorsByAttribute(filter *Filter, attr string) ([]ComparisonStats, error) {
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT "%s" key, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY key`, attr, filter.table(), filterQuery)
+	stats := make([]ComparisonStats, 0)
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (analyzer *Analyzer) selectTrend(filter *Filter, attr string) ([]TrendStats, error) {
+	filter = analyzer.getFilter(filter)
+	args, filterQuery := filter.query()
+	query := fmt.Sprintf(`SELECT toDate(time, '%s') day, "%s" name, count(DISTINCT fingerprint) visitors
+		FROM %s
+		WHERE %s
+		GROUP BY day, name
+		ORDER BY day ASC, visitors DESC, name ASC`, filter.Timezone.String(), attr, filter.table(), filterQuery)
+	var stats []trendDayStats
+
+	if err := analyzer.store.Select(&stats, query, args...); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+
+	if limit <= 0 {
+		limit = defaultTrendLimit
+	}
+
+	return analyzer.groupTrend(filter, stats, limit), nil
+}
+
+func (analyzer *Analyzer) groupTrend(filter *Filter, stats []trendDayStats, limit int) []TrendStats {
+	totals := make(map[string]int)
+	visitors := make(map[string]map[int64]int)
+	dayTotals := make(map[int64]int)
+
+	for _, s := range stats {
+		if _, found := visitors[s.Name]; !found {
+			visitors[s.Name] = make(map[int64]int)
+		}
+
+		totals[s.Name] += s.Visitors
+		visitors[s.Name][s.Day.Unix()] += s.Visitors
+		dayTotals[s.Day.Unix()] += s.Visitors
+	}
+
+	names := make([]string, 0, len(totals))
+
+	for name := range totals {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] == totals[names[j]] {
+			return names[i] < names[j]
+		}
+
+		return totals[names[i]] > totals[names[j]]
+	})
+	days := filter.days()
+
+	if days == nil {
+		days = analyzer.daysFromTrend(stats)
+	}
+
+	n := len(names)
+
+	if n > limit {
+		n = limit
+	}
+
+	trend := make([]TrendStats, 0, n+1)
+
+	for _, name := range names[:n] {
+		trend = append(trend, analyzer.trendSeries(name, days, dayTotals, visitors[name]))
+	}
+
+	if len(names) > limit {
+		other := make(map[int64]int)
+
+		for _, name := range names[limit:] {
+			for day, v := range visitors[name] {
+				other[day] += v
+			}
+		}
+
+		trend = append(trend, analyzer.trendSeries(TrendOther, days, dayTotals, other))
+	}
+
+	return trend
+}
+
+func (analyzer *Analyzer) trendSeries(name string, days []time.Time, dayTotals, visitors map[int64]int) TrendStats {
+	series := TrendStats{
+		Name: name,
+		Days: make([]TrendDayStats, 0, len(days)),
+	}
+
+	for _, day := range days {
+		v := visitors[day.Unix()]
+		relative := 0.0
+
+		if total := dayTotals[day.Unix()]; total > 0 {
+			relative = float64(v) / float64(total)
+		}
+
+		series.Days = append(series.Days, TrendDayStats{
+			Day:              day,
+			Visitors:         v,
+			RelativeVisitors: relative,
+		})
+	}
+
+	return series
+}
+
+func (analyzer *Analyzer) daysFromTrend(stats []trendDayStats) []time.Time {
+	days := make([]time.Time, 0)
+
+	for i, s := range stats {
+		if i == 0 || !s.Day.Equal(stats[i-1].Day) {
+			days = append(days, s.Day)
+		}
+	}
+
+	return days
+}
+
+// getActiveFilter returns a copy of the filter selecting the past duration.
+// Start is set before the filter is validated, so that the default period doesn't apply.
+func (analyzer *Analyzer) getActiveFilter(filter *Filter, duration time.Duration) *Filter {
+	activeFilter := NewFilter(NullClient)
+
+	if filter != nil {
+		*activeFilter = *filter
+	}
+
+	activeFilter.Start = time.Now().UTC().Add(-duration)
+	return analyzer.getFilter(activeFilter)
+}
+
 func (analyzer *Analyzer) getFilter(filter *Filter) *Filter {
 	if filter == nil {
 		filter = NewFilter(NullClient)
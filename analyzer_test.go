@@ -1,6 +1,8 @@
 package pirsch
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -36,10 +38,74 @@ func TestAnalyzer_ActiveVisitors(t *testing.T) {
 	assert.Len(t, visitors, 1)
 	assert.Equal(t, "/bar", visitors[0].Path)
 	assert.Equal(t, 2, visitors[0].Visitors)
+	timezone, err := time.LoadLocation("Pacific/Kiritimati")
+	assert.NoError(t, err)
+	_, count, err = analyzer.ActiveVisitors(&Filter{Timezone: timezone}, time.Minute*10)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
 	_, _, err = analyzer.ActiveVisitors(getMaxFilter(), time.Minute*10)
 	assert.NoError(t, err)
 }
 
+func TestAnalyzer_ActiveVisitorsByCountry(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now().Add(-time.Minute * 30), Path: "/", CountryCode: "fr"},
+		{Fingerprint: "fp2", Time: time.Now().Add(-time.Minute * 4), Path: "/", CountryCode: "de"},
+		{Fingerprint: "fp2", Time: time.Now().Add(-time.Minute * 3), Path: "/foo", CountryCode: "de"},
+		{Fingerprint: "fp3", Time: time.Now().Add(-time.Minute * 3), Path: "/", CountryCode: "gb"},
+		{Fingerprint: "fp4", Time: time.Now().Add(-time.Minute), Path: "/", CountryCode: "de"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, count, err := analyzer.ActiveVisitorsByCountry(nil, time.Minute*10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Len(t, visitors, 2)
+	assert.Equal(t, "de", visitors[0].CountryCode)
+	assert.Equal(t, "gb", visitors[1].CountryCode)
+	assert.Equal(t, 2, visitors[0].Visitors)
+	assert.Equal(t, 1, visitors[1].Visitors)
+	assert.InDelta(t, 0.6666, visitors[0].RelativeVisitors, 0.01)
+	timezone, err := time.LoadLocation("Pacific/Kiritimati")
+	assert.NoError(t, err)
+	_, count, err = analyzer.ActiveVisitorsByCountry(&Filter{Timezone: timezone}, time.Minute*10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	_, _, err = analyzer.ActiveVisitorsByCountry(getMaxFilter(), time.Minute*10)
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Hits(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Path: "/", Desktop: true, ScreenWidth: 1920},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	hits, err := analyzer.Hits(nil, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, hits, 4)
+	assert.Equal(t, "fp1", hits[0].Fingerprint)
+	assert.Equal(t, "/", hits[0].Path)
+	assert.True(t, hits[0].Desktop)
+	assert.Equal(t, 1920, hits[0].ScreenWidth)
+	assert.Equal(t, "/bar", hits[3].Path)
+	hits, err = analyzer.Hits(nil, 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, hits, 2)
+	assert.Equal(t, "/foo", hits[0].Path)
+	assert.Equal(t, "fp2", hits[1].Fingerprint)
+	hits, err = analyzer.Hits(&Filter{Path: "/"}, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, hits, 2)
+	_, err = analyzer.Hits(getMaxFilter(), 0, 10)
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_VisitorsAndAvgSessionDuration(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -95,11 +161,16 @@ func TestAnalyzer_VisitorsAndAvgSessionDuration(t *testing.T) {
 	assert.InDelta(t, 1, visitors[4].BounceRate, 0.01)
 	asd, err := analyzer.AvgSessionDuration(nil)
 	assert.NoError(t, err)
+	assert.Len(t, asd, DefaultPeriodDays)
+	assert.Equal(t, pastDay(4), asd[DefaultPeriodDays-5].Day)
+	assert.Equal(t, pastDay(2), asd[DefaultPeriodDays-3].Day)
+	assert.Equal(t, 300, asd[DefaultPeriodDays-5].AverageTimeSpentSeconds)
+	assert.Equal(t, 450, asd[DefaultPeriodDays-3].AverageTimeSpentSeconds)
+	asd, err = analyzer.AvgSessionDuration(&Filter{AllTime: true})
+	assert.NoError(t, err)
 	assert.Len(t, asd, 2)
 	assert.Equal(t, pastDay(4), asd[0].Day)
 	assert.Equal(t, pastDay(2), asd[1].Day)
-	assert.Equal(t, 300, asd[0].AverageTimeSpentSeconds)
-	assert.Equal(t, 450, asd[1].AverageTimeSpentSeconds)
 	tsd, err := analyzer.TotalSessionDuration(nil)
 	assert.NoError(t, err)
 	assert.Equal(t, 1200, tsd)
@@ -122,6 +193,495 @@ func TestAnalyzer_VisitorsAndAvgSessionDuration(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAnalyzer_VisitorsCrossFiltered(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Path: "/pricing", CountryCode: "de", Browser: BrowserChrome, UTMSource: "newsletter"},
+		{Fingerprint: "fp2", Time: pastDay(2), Path: "/pricing", CountryCode: "de", Browser: BrowserFirefox},
+		{Fingerprint: "fp3", Time: pastDay(2), Path: "/pricing", CountryCode: "gb", Browser: BrowserChrome},
+		{Fingerprint: "fp4", Time: pastDay(2), Path: "/", CountryCode: "de", Browser: BrowserChrome},
+		{Fingerprint: "fp5", Time: Today(), Path: "/pricing", CountryCode: "de", Browser: BrowserChrome, UTMSource: "newsletter"},
+		{Fingerprint: "fp6", Time: Today(), Path: "/pricing", CountryCode: "de", Browser: BrowserChrome},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.Visitors(&Filter{From: pastDay(2), To: Today(), Path: "/pricing", Country: "de"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 0, stats[1].Visitors)
+	assert.Equal(t, 2, stats[2].Visitors)
+	stats, err = analyzer.Visitors(&Filter{From: pastDay(2), To: Today(), Path: "/pricing", Country: "de", Browser: BrowserChrome, UTMSource: "newsletter"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, 1, stats[0].Visitors)
+	assert.Equal(t, 0, stats[1].Visitors)
+	assert.Equal(t, 1, stats[2].Visitors)
+}
+
+func TestAnalyzer_VisitorsByInterval(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute * 5), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Minute * 10), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Minute * 20), Path: "/foo"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Hour*23 + time.Minute*50), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.VisitorsByInterval(&Filter{Day: pastDay(1)}, time.Minute*15)
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 96)
+	assert.Equal(t, pastDay(1), visitors[0].Start.UTC())
+	assert.Equal(t, pastDay(1).Add(time.Minute*15), visitors[1].Start.UTC())
+	assert.Equal(t, 2, visitors[0].Visitors)
+	assert.Equal(t, 1, visitors[1].Visitors)
+	assert.Equal(t, 0, visitors[2].Visitors)
+	assert.Equal(t, 1, visitors[95].Visitors)
+	_, err = analyzer.VisitorsByInterval(nil, time.Minute*15)
+	assert.NoError(t, err)
+	_, err = analyzer.VisitorsByInterval(&Filter{AllTime: true}, time.Minute*15)
+	assert.ErrorIs(t, err, ErrNoPeriodOrDay)
+	_, err = analyzer.VisitorsByInterval(&Filter{Day: pastDay(1)}, time.Millisecond)
+	assert.ErrorIs(t, err, ErrInvalidInterval)
+	_, err = analyzer.VisitorsByInterval(&Filter{From: pastDay(365), To: Today()}, time.Second)
+	assert.ErrorIs(t, err, ErrTooManyIntervals)
+	_, err = analyzer.VisitorsByInterval(getMaxFilter(), time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_DaysWithData(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(5), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(5).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(3), Path: "/foo"},
+		{Fingerprint: "fp4", Time: pastDay(1), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	days, err := analyzer.DaysWithData(&Filter{From: pastDay(4), To: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, days, 2)
+	assert.Equal(t, pastDay(3), days[0].UTC())
+	assert.Equal(t, pastDay(1), days[1].UTC())
+	days, err = analyzer.DaysWithData(&Filter{From: pastDay(10), To: Today(), Path: "/"})
+	assert.NoError(t, err)
+	assert.Len(t, days, 2)
+	assert.Equal(t, pastDay(5), days[0].UTC())
+	assert.Equal(t, pastDay(1), days[1].UTC())
+	_, err = analyzer.DaysWithData(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_NewReturningTrend(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(5), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(2).Add(time.Minute), Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/foo"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.NewReturningTrend(&Filter{From: pastDay(2), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, pastDay(2), stats[0].Day.UTC())
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 1, stats[0].NewVisitors)
+	assert.Equal(t, 1, stats[0].ReturningVisitors)
+	assert.Equal(t, pastDay(1), stats[1].Day.UTC())
+	assert.Equal(t, 3, stats[1].Visitors)
+	assert.Equal(t, 1, stats[1].NewVisitors)
+	assert.Equal(t, 2, stats[1].ReturningVisitors)
+	stats, err = analyzer.NewReturningTrend(&Filter{From: pastDay(3), To: pastDay(1), Path: "/foo"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, 0, stats[0].Visitors)
+	assert.Equal(t, 1, stats[1].Visitors)
+	assert.Equal(t, 1, stats[1].NewVisitors)
+	assert.Equal(t, 1, stats[2].Visitors)
+	assert.Equal(t, 0, stats[2].NewVisitors)
+	assert.Equal(t, 1, stats[2].ReturningVisitors)
+	_, err = analyzer.NewReturningTrend(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_AverageVisitorsPerDay(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(3).Add(time.Minute), Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(1), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	avg, err := analyzer.AverageVisitorsPerDay(&Filter{From: pastDay(3), To: Today()}, false)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.5, avg, 0.001)
+	avg, err = analyzer.AverageVisitorsPerDay(&Filter{From: pastDay(3), To: Today()}, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, 3, avg, 0.001)
+	avg, err = analyzer.AverageVisitorsPerDay(&Filter{AllTime: true}, false)
+	assert.NoError(t, err)
+	assert.InDelta(t, 3, avg, 0.001)
+	avg, err = analyzer.AverageVisitorsPerDay(&Filter{Day: pastDay(2)}, false)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0, avg, 0.001)
+	_, err = analyzer.AverageVisitorsPerDay(getMaxFilter(), false)
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_HitsPerDay(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(2), Path: "/foo"},
+		{Fingerprint: "fp1", Time: Today(), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.HitsPerDay(&Filter{From: pastDay(3), To: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 4)
+	assert.Equal(t, pastDay(3), stats[0].Day.UTC())
+	assert.Equal(t, 0, stats[0].Hits)
+	assert.Equal(t, 3, stats[1].Hits)
+	assert.Equal(t, 0, stats[2].Hits)
+	assert.Equal(t, 1, stats[3].Hits)
+	stats, err = analyzer.HitsPerDay(&Filter{Path: "/"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, DefaultPeriodDays)
+	assert.Equal(t, 2, stats[DefaultPeriodDays-3].Hits)
+	assert.Equal(t, 1, stats[DefaultPeriodDays-1].Hits)
+	stats, err = analyzer.HitsPerDay(&Filter{Path: "/", AllTime: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, 2, stats[0].Hits)
+	_, err = analyzer.HitsPerDay(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_VisitorsForClients(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{ClientID: 1, Fingerprint: "fp1", Time: pastDay(2), Path: "/"},
+		{ClientID: 1, Fingerprint: "fp1", Time: pastDay(2), Path: "/foo"},
+		{ClientID: 1, Fingerprint: "fp2", Time: pastDay(2), Path: "/"},
+		{ClientID: 1, Fingerprint: "fp3", Time: pastDay(1), Path: "/"},
+		{ClientID: 2, Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{ClientID: 3, Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.VisitorsForClients(nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 0)
+	stats, err = analyzer.VisitorsForClients([]int64{1, 2, 4}, &Filter{From: pastDay(2), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Len(t, stats[1], 2)
+	assert.Equal(t, pastDay(2), stats[1][0].Day.UTC())
+	assert.Equal(t, 2, stats[1][0].Visitors)
+	assert.Equal(t, 3, stats[1][0].Views)
+	assert.Equal(t, 1, stats[1][0].Bounces)
+	assert.Equal(t, 1, stats[1][1].Visitors)
+	assert.Len(t, stats[2], 2)
+	assert.Equal(t, 0, stats[2][0].Visitors)
+	assert.Equal(t, pastDay(2), stats[2][0].Day.UTC())
+	assert.Equal(t, 1, stats[2][1].Visitors)
+	assert.Len(t, stats[4], 2)
+	assert.Equal(t, 0, stats[4][1].Visitors)
+	stats, err = analyzer.VisitorsForClients([]int64{1, 2}, &Filter{From: pastDay(2), To: pastDay(1), DistinctPerDay: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats[1][0].Visitors)
+	assert.Equal(t, 2, stats[1][0].Views)
+	assert.Equal(t, 1, stats[2][1].Visitors)
+	assert.Equal(t, 1, stats[2][1].Views)
+	stats, err = analyzer.VisitorsForClients([]int64{1, 4}, &Filter{From: pastDay(2), To: pastDay(1), ExcludedWindows: []TimeWindow{{From: pastDay(2), To: pastDay(1)}}})
+	assert.NoError(t, err)
+	assert.Len(t, stats[1], 1)
+	assert.Equal(t, pastDay(1), stats[1][0].Day.UTC())
+	assert.Equal(t, 1, stats[1][0].Visitors)
+	assert.Len(t, stats[4], 1)
+	assert.Equal(t, pastDay(1), stats[4][0].Day.UTC())
+	stats, err = analyzer.VisitorsForClients([]int64{1, 3}, &Filter{AllTime: true, Path: "/foo"})
+	assert.NoError(t, err)
+	assert.Len(t, stats[1], 1)
+	assert.Equal(t, 1, stats[1][0].Visitors)
+	assert.Len(t, stats[3], 0)
+	_, err = analyzer.VisitorsForClients([]int64{1, 2}, getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_ExcludedWindows(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Hour * 2), Path: "/"},
+	}))
+	assert.NoError(t, dbClient.SaveExclusionWindow(NullClient, TimeWindow{From: pastDay(2), To: pastDay(1)}))
+	assert.NoError(t, dbClient.SaveExclusionWindow(NullClient, TimeWindow{From: pastDay(1), To: pastDay(1).Add(time.Hour)}))
+	assert.NoError(t, dbClient.SaveExclusionWindow(42, TimeWindow{From: pastDay(3), To: pastDay(2)}))
+	time.Sleep(time.Millisecond * 20)
+	windows, err := dbClient.ExclusionWindows(NullClient)
+	assert.NoError(t, err)
+	assert.Len(t, windows, 2)
+	analyzer := NewAnalyzer(dbClient)
+	input := &Filter{From: pastDay(3), To: pastDay(1)}
+	filter, err := analyzer.WithExcludedWindows(input)
+	assert.NoError(t, err)
+	assert.Len(t, filter.ExcludedWindows, 2)
+	assert.Nil(t, input.ExcludedWindows)
+	visitors, err := analyzer.Visitors(filter)
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 2)
+	assert.Equal(t, pastDay(3), visitors[0].Day.UTC())
+	assert.Equal(t, 2, visitors[0].Visitors)
+	assert.Equal(t, pastDay(1), visitors[1].Day.UTC())
+	assert.Equal(t, 1, visitors[1].Visitors)
+	avg, err := analyzer.AverageVisitorsPerDay(filter, false)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.5, avg, 0.001)
+	total, err := analyzer.TotalVisitors(filter)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total.Visitors)
+	visitors, err = analyzer.Visitors(input)
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 3)
+	assert.Equal(t, 4, visitors[1].Visitors)
+	filter, err = analyzer.WithExcludedWindows(&Filter{ClientID: 42, From: pastDay(3), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, filter.ExcludedWindows, 1)
+	_, err = NewAnalyzer(NewMockClient()).WithExcludedWindows(nil)
+	assert.True(t, errors.Is(err, ErrNotSupported))
+	assert.NoError(t, dbClient.DeleteExclusionWindows(NullClient))
+	time.Sleep(time.Millisecond * 20)
+	windows, err = dbClient.ExclusionWindows(NullClient)
+	assert.NoError(t, err)
+	assert.Len(t, windows, 0)
+	windows, err = dbClient.ExclusionWindows(42)
+	assert.NoError(t, err)
+	assert.Len(t, windows, 1)
+}
+
+func TestAnalyzer_WeekdayWeekendSplit(t *testing.T) {
+	cleanupDB()
+	monday := time.Date(2021, 6, 7, 10, 0, 0, 0, time.UTC)
+	saturday := time.Date(2021, 6, 12, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: monday, Session: monday, Path: "/"},
+		{Fingerprint: "fp1", Time: monday.Add(time.Minute), Session: monday, Path: "/foo"},
+		{Fingerprint: "fp2", Time: monday, Session: monday, Path: "/"},
+		{Fingerprint: "fp3", Time: monday.AddDate(0, 0, 1), Session: monday.AddDate(0, 0, 1), Path: "/"},
+		{Fingerprint: "fp4", Time: saturday, Session: saturday, Path: "/"},
+		{Fingerprint: "fp5", Time: saturday.AddDate(0, 0, 1), Session: saturday.AddDate(0, 0, 1), Path: "/"},
+		{Fingerprint: "fp5", Time: saturday.AddDate(0, 0, 1).Add(time.Minute), Session: saturday.AddDate(0, 0, 1), Path: "/foo"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.WeekdayWeekendSplit(&Filter{From: monday, To: saturday.AddDate(0, 0, 1)})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, stats.Weekday.Days)
+	assert.Equal(t, 3, stats.Weekday.Visitors)
+	assert.Equal(t, 3, stats.Weekday.Sessions)
+	assert.Equal(t, 2, stats.Weekday.Bounces)
+	assert.InDelta(t, 0.6, stats.Weekday.AvgVisitors, 0.001)
+	assert.InDelta(t, 0.4, stats.Weekday.AvgBounces, 0.001)
+	assert.InDelta(t, 0.6666, stats.Weekday.BounceRate, 0.001)
+	assert.Equal(t, 2, stats.Weekend.Days)
+	assert.Equal(t, 2, stats.Weekend.Visitors)
+	assert.Equal(t, 1, stats.Weekend.Bounces)
+	assert.InDelta(t, 1, stats.Weekend.AvgVisitors, 0.001)
+	assert.InDelta(t, 0.5, stats.Weekend.BounceRate, 0.001)
+	stats, err = analyzer.WeekdayWeekendSplit(&Filter{AllTime: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Weekday.Days)
+	assert.InDelta(t, 1.5, stats.Weekday.AvgVisitors, 0.001)
+	assert.Equal(t, 2, stats.Weekend.Days)
+	_, err = analyzer.WeekdayWeekendSplit(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_EngagementRate(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Session: pastDay(2), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Hour), Session: pastDay(2).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp3", Time: Today(), Session: Today(), Path: "/"},
+		{Fingerprint: "fp3", Time: Today().Add(time.Minute), Session: Today(), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.EngagementRate(&Filter{From: pastDay(2), To: Today()})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, stats.Sessions)
+	assert.Equal(t, 2, stats.EngagedSessions)
+	assert.InDelta(t, 0.5, stats.EngagementRate, 0.01)
+	assert.Len(t, stats.Days, 3)
+	assert.Equal(t, 3, stats.Days[0].Sessions)
+	assert.Equal(t, 1, stats.Days[0].EngagedSessions)
+	assert.InDelta(t, 0.3333, stats.Days[0].EngagementRate, 0.01)
+	assert.Equal(t, 0, stats.Days[1].Sessions)
+	assert.InDelta(t, 0, stats.Days[1].EngagementRate, 0.01)
+	assert.InDelta(t, 1, stats.Days[2].EngagementRate, 0.01)
+	stats, err = analyzer.EngagementRate(&Filter{Day: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Sessions)
+	assert.InDelta(t, 0, stats.EngagementRate, 0.01)
+	_, err = analyzer.EngagementRate(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Anomalies(t *testing.T) {
+	cleanupDB()
+	hits := make([]Hit, 0)
+
+	for day := 20; day > 0; day-- {
+		visitors := 10 + day%2
+
+		if day == 3 {
+			visitors = 50
+		}
+
+		for i := 0; i < visitors; i++ {
+			hits = append(hits, Hit{Fingerprint: fmt.Sprintf("fp%d", i), Time: pastDay(day), Path: "/"})
+		}
+	}
+
+	assert.NoError(t, dbClient.SaveHits(hits))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	anomalies, err := analyzer.Anomalies(&Filter{From: pastDay(10), To: pastDay(1)}, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, pastDay(3), anomalies[0].Day)
+	assert.Equal(t, 50, anomalies[0].Visitors)
+	assert.InDelta(t, 10.43, anomalies[0].Mean, 0.01)
+	assert.True(t, anomalies[0].ZScore > 3)
+	anomalies, err = analyzer.Anomalies(&Filter{From: pastDay(20), To: pastDay(4)}, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, anomalies)
+	anomalies, err = analyzer.Anomalies(&Filter{From: pastDay(10), To: pastDay(1)}, 7, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, anomalies)
+	anomalies, err = analyzer.Anomalies(&Filter{AllTime: true}, 3, 0)
+	assert.NoError(t, err)
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, pastDay(3), anomalies[0].Day)
+	_, err = analyzer.Anomalies(getMaxFilter(), 0, 0)
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_SessionsPerVisitor(t *testing.T) {
+	cleanupDB()
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.SessionsPerVisitor(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Visitors)
+	assert.InDelta(t, 0, stats.Average, 0.001)
+	assert.Len(t, stats.Distribution, 3)
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Hour * 2), Session: pastDay(1).Add(time.Hour * 2), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	stats, err = analyzer.SessionsPerVisitor(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, stats.Visitors)
+	assert.Equal(t, 8, stats.Sessions)
+	assert.InDelta(t, 2, stats.Average, 0.001)
+	assert.Equal(t, SessionCountStats{Sessions: 1, Visitors: 2}, stats.Distribution[0])
+	assert.Equal(t, SessionCountStats{Sessions: 2, Visitors: 1}, stats.Distribution[1])
+	assert.Equal(t, SessionCountStats{Sessions: 3, Visitors: 1}, stats.Distribution[2])
+	_, err = analyzer.SessionsPerVisitor(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_VisitDepth(t *testing.T) {
+	cleanupDB()
+	hits := []Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/bar"},
+	}
+
+	for i := 0; i < 12; i++ {
+		hits = append(hits, Hit{Fingerprint: "fp4", Time: pastDay(1).Add(time.Minute * time.Duration(i)), Session: pastDay(1), Path: "/"})
+	}
+
+	assert.NoError(t, dbClient.SaveHits(hits))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.VisitDepth(nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, VisitDepthStats{Depth: 1, Sessions: 2, Visitors: 2}, stats[0])
+	assert.Equal(t, VisitDepthStats{Depth: 2, Sessions: 2, Visitors: 2}, stats[1])
+	assert.Equal(t, VisitDepthStats{Depth: 10, Sessions: 1, Visitors: 1}, stats[2])
+	_, err = analyzer.VisitDepth(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_SessionBounces(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Hour + time.Minute), Session: pastDay(1).Add(time.Hour), Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.Visitors(&Filter{From: pastDay(1), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 1)
+	assert.Equal(t, 1, visitors[0].Bounces)
+	assert.InDelta(t, 0.5, visitors[0].BounceRate, 0.01)
+	visitors, err = analyzer.Visitors(&Filter{From: pastDay(1), To: pastDay(1), SessionBounces: true})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 1)
+	assert.Equal(t, 3, visitors[0].Sessions)
+	assert.Equal(t, 2, visitors[0].Bounces)
+	assert.InDelta(t, 0.6666, visitors[0].BounceRate, 0.01)
+	total, err := analyzer.TotalVisitors(&Filter{From: pastDay(1), To: pastDay(1), SessionBounces: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total.Bounces)
+	filter := getMaxFilter()
+	filter.SessionBounces = true
+	_, err = analyzer.Visitors(filter)
+	assert.NoError(t, err)
+	_, err = analyzer.Growth(filter)
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_Growth(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -145,6 +705,9 @@ func TestAnalyzer_Growth(t *testing.T) {
 	time.Sleep(time.Millisecond * 20)
 	analyzer := NewAnalyzer(dbClient)
 	growth, err := analyzer.Growth(nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, growth)
+	growth, err = analyzer.Growth(&Filter{AllTime: true})
 	assert.ErrorIs(t, err, ErrNoPeriodOrDay)
 	assert.Nil(t, growth)
 	growth, err = analyzer.Growth(&Filter{Day: pastDay(2)})
@@ -167,6 +730,50 @@ func TestAnalyzer_Growth(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAnalyzer_TotalVisitors(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Session: pastDay(2), Path: "/foo"},
+		{Fingerprint: "fp3", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(2), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.TotalVisitors(&Filter{Day: pastDay(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Visitors)
+	assert.Equal(t, 4, stats.Views)
+	assert.Equal(t, 3, stats.Sessions)
+	assert.Equal(t, 2, stats.Bounces)
+	assert.Nil(t, stats.Previous)
+	assert.Nil(t, stats.Growth)
+	stats, err = analyzer.TotalVisitors(&Filter{Day: pastDay(2), Compare: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Visitors)
+	assert.NotNil(t, stats.Previous)
+	assert.Equal(t, 2, stats.Previous.Visitors)
+	assert.Equal(t, 2, stats.Previous.Views)
+	assert.Equal(t, 2, stats.Previous.Sessions)
+	assert.Equal(t, 2, stats.Previous.Bounces)
+	assert.Nil(t, stats.Previous.Previous)
+	assert.NotNil(t, stats.Growth)
+	assert.InDelta(t, 0.5, stats.Growth.VisitorsGrowth, 0.001)
+	assert.InDelta(t, 1, stats.Growth.ViewsGrowth, 0.001)
+	assert.InDelta(t, 0.5, stats.Growth.SessionsGrowth, 0.001)
+	assert.InDelta(t, 0, stats.Growth.BouncesGrowth, 0.001)
+	_, err = analyzer.TotalVisitors(&Filter{Compare: true})
+	assert.NoError(t, err)
+	_, err = analyzer.TotalVisitors(&Filter{Compare: true, AllTime: true})
+	assert.ErrorIs(t, err, ErrNoPeriodOrDay)
+	filter := getMaxFilter()
+	filter.Compare = true
+	_, err = analyzer.TotalVisitors(filter)
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_VisitorHours(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -216,37 +823,422 @@ func TestAnalyzer_VisitorHours(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestAnalyzer_PagesAndAvgTimeOnPage(t *testing.T) {
+func TestAnalyzer_LocalVisitorHours(t *testing.T) {
 	cleanupDB()
+	day := pastDay(2)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
 	assert.NoError(t, dbClient.SaveHits([]Hit{
-		{Fingerprint: "fp1", Time: pastDay(4), Session: pastDay(4), Path: "/"},
-		{Fingerprint: "fp1", Time: pastDay(4).Add(time.Minute * 3), Session: pastDay(4), PreviousTimeOnPageSeconds: 180, Path: "/foo"},
-		{Fingerprint: "fp1", Time: pastDay(4), Path: "/"},
-		{Fingerprint: "fp2", Time: pastDay(4), Path: "/"},
-		{Fingerprint: "fp2", Time: pastDay(4), Path: "/bar"},
-		{Fingerprint: "fp3", Time: pastDay(4), Path: "/"},
-		{Fingerprint: "fp4", Time: pastDay(4), Path: "/"},
-		{Fingerprint: "fp5", Time: pastDay(2), Session: pastDay(2), Path: "/"},
-		{Fingerprint: "fp5", Time: pastDay(2).Add(time.Minute * 5), Session: pastDay(2).Add(time.Minute * 30), Path: "/bar"},
-		{Fingerprint: "fp6", Time: pastDay(2), Session: pastDay(2), Path: "/"},
-		{Fingerprint: "fp6", Time: pastDay(2).Add(time.Minute * 10), Session: pastDay(2), PreviousTimeOnPageSeconds: 600, Path: "/bar"},
-		{Fingerprint: "fp6", Time: pastDay(2).Add(time.Minute * 11), Session: pastDay(2).Add(time.Hour), Path: "/bar"},
-		{Fingerprint: "fp6", Time: pastDay(2).Add(time.Minute * 21), Session: pastDay(2).Add(time.Hour), PreviousTimeOnPageSeconds: 600, Path: "/foo"},
-		{Fingerprint: "fp7", Time: pastDay(2), Path: "/"},
-		{Fingerprint: "fp8", Time: pastDay(2), Path: "/"},
-		{Fingerprint: "fp9", Time: Today(), Path: "/"},
+		{Fingerprint: "fp1", Time: day.Add(time.Hour * 8), CountryCode: "de"},
+		{Fingerprint: "fp1", Time: day.Add(time.Hour*8 + time.Minute), CountryCode: "de"},
+		{Fingerprint: "fp2", Time: day.Add(time.Hour * 16), CountryCode: "us"},
+		{Fingerprint: "fp3", Time: day.Add(time.Hour * 10)},
 	}))
 	time.Sleep(time.Millisecond * 20)
+	deHour := day.Add(time.Hour * 8).In(berlin).Hour()
+	usHour := day.Add(time.Hour * 16).In(newYork).Hour()
 	analyzer := NewAnalyzer(dbClient)
-	visitors, err := analyzer.Pages(nil)
+	stats, err := analyzer.LocalVisitorHours(&Filter{Day: day})
 	assert.NoError(t, err)
-	assert.Len(t, visitors, 3)
-	assert.Equal(t, "/", visitors[0].Path)
-	assert.Equal(t, "/bar", visitors[1].Path)
-	assert.Equal(t, "/foo", visitors[2].Path)
-	assert.Equal(t, 9, visitors[0].Visitors)
-	assert.Equal(t, 3, visitors[1].Visitors)
-	assert.Equal(t, 2, visitors[2].Visitors)
+	assert.Len(t, stats, 24)
+	assert.Equal(t, deHour, stats[deHour].Hour)
+	assert.Equal(t, 1, stats[deHour].Visitors)
+	assert.Equal(t, 1, stats[usHour].Visitors)
+	sum := 0
+
+	for _, hour := range stats {
+		sum += hour.Visitors
+	}
+
+	assert.Equal(t, 2, sum)
+	stats, err = analyzer.LocalVisitorHours(&Filter{Day: day, Country: "us"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 24)
+	assert.Equal(t, 0, stats[deHour].Visitors)
+	assert.Equal(t, 1, stats[usHour].Visitors)
+	stats, err = analyzer.LocalVisitorHours(&Filter{Day: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 24)
+	assert.Equal(t, 0, stats[deHour].Visitors)
+	_, err = analyzer.LocalVisitorHours(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Peak(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3).Add(time.Hour * 5)},
+		{Fingerprint: "fp2", Time: pastDay(3).Add(time.Hour * 5)},
+		{Fingerprint: "fp3", Time: pastDay(2).Add(time.Hour * 2)},
+		{Fingerprint: "fp4", Time: pastDay(2).Add(time.Hour * 3)},
+		{Fingerprint: "fp5", Time: pastDay(2).Add(time.Hour * 4)},
+		{Fingerprint: "fp6", Time: pastDay(1).Add(time.Hour * 7)},
+		{Fingerprint: "fp7", Time: pastDay(1).Add(time.Hour * 7)},
+		{Fingerprint: "fp8", Time: pastDay(1).Add(time.Hour * 8)},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	peak, err := analyzer.Peak(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, pastDay(3).Add(time.Hour*5), peak.Hour.UTC())
+	assert.Equal(t, 2, peak.HourVisitors)
+	assert.Equal(t, pastDay(2), peak.Day.UTC())
+	assert.Equal(t, 3, peak.DayVisitors)
+	peak, err = analyzer.Peak(&Filter{From: Today(), To: Today()})
+	assert.NoError(t, err)
+	assert.True(t, peak.Hour.IsZero())
+	assert.Equal(t, 0, peak.DayVisitors)
+	_, err = analyzer.Peak(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_TimeOfDay(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(3).Add(time.Minute), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(3).Add(time.Hour * 5), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(3).Add(time.Hour * 5), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(1).Add(time.Hour * 23), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	days, err := analyzer.TimeOfDay(&Filter{From: pastDay(3), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, days, 3)
+	assert.Equal(t, pastDay(3), days[0].Day)
+	assert.Equal(t, pastDay(2), days[1].Day)
+	assert.Equal(t, pastDay(1), days[2].Day)
+
+	for _, day := range days {
+		assert.Len(t, day.Stats, 24)
+		assert.Equal(t, 23, day.Stats[23].Hour)
+	}
+
+	assert.Equal(t, 1, days[0].Stats[0].Visitors)
+	assert.Equal(t, 2, days[0].Stats[5].Visitors)
+	assert.Equal(t, 0, days[0].Stats[23].Visitors)
+	assert.Equal(t, 0, days[1].Stats[5].Visitors)
+	assert.Equal(t, 1, days[2].Stats[23].Visitors)
+	days, err = analyzer.TimeOfDay(nil)
+	assert.NoError(t, err)
+	assert.Len(t, days, DefaultPeriodDays)
+	assert.Equal(t, pastDay(3), days[DefaultPeriodDays-4].Day.UTC())
+	assert.Equal(t, pastDay(1), days[DefaultPeriodDays-2].Day.UTC())
+	days, err = analyzer.TimeOfDay(&Filter{AllTime: true})
+	assert.NoError(t, err)
+	assert.Len(t, days, 2)
+	assert.Equal(t, pastDay(3), days[0].Day.UTC())
+	assert.Equal(t, pastDay(1), days[1].Day.UTC())
+	_, err = analyzer.TimeOfDay(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_TopSessions(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute * 3), Session: pastDay(1), Path: "/bar"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Minute * 10), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/bar"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Minute * 2), Session: pastDay(1), Path: "/foo"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	sessions, err := analyzer.TopSessions(nil)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 4)
+	assert.Len(t, sessions[0].SessionID, 64)
+	assert.NotContains(t, sessions[0].SessionID, "fp1")
+	assert.Equal(t, pastDay(1), sessions[0].Session.UTC())
+	assert.Equal(t, 3, sessions[0].Views)
+	assert.Equal(t, 180, sessions[0].DurationSeconds)
+	assert.Equal(t, "/", sessions[0].EntryPath)
+	assert.Equal(t, "/bar", sessions[0].ExitPath)
+	assert.Equal(t, 600, sessions[1].DurationSeconds)
+	assert.Equal(t, "/foo", sessions[1].EntryPath)
+	assert.Equal(t, "/", sessions[1].ExitPath)
+	assert.Equal(t, 1, sessions[3].Views)
+	assert.Equal(t, 0, sessions[3].DurationSeconds)
+	ids := make(map[string]bool)
+
+	for _, session := range sessions {
+		ids[session.SessionID] = true
+	}
+
+	assert.Len(t, ids, 4)
+	top := sessions[0].SessionID
+	sessions, err = analyzer.TopSessions(&Filter{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.NotEqual(t, top, sessions[0].SessionID)
+	_, err = analyzer.TopSessions(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Search(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: Today(), Path: "/Blog/first-post"},
+		{Fingerprint: "fp2", Time: Today(), Path: "/", Referrer: "https://blog.example.com/"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/pricing"},
+		{Fingerprint: "fp4", Time: Today(), Path: "/50%_off"},
+		{Fingerprint: "fp5", Time: Today(), Path: "/50x-off"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	pages, err := analyzer.Pages(&Filter{Search: "blog"})
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "/", pages[0].Path)
+	assert.Equal(t, "/Blog/first-post", pages[1].Path)
+	pages, err = analyzer.Pages(&Filter{Search: "%_off"})
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+	assert.Equal(t, "/50%_off", pages[0].Path)
+}
+
+func TestAnalyzer_WithTotal(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/", Browser: BrowserChrome, OS: OSWindows, Language: "en", CountryCode: "de", ScreenClass: "XL", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Path: "/foo", Browser: BrowserFirefox, OS: OSLinux, Language: "de", CountryCode: "gb", ScreenClass: "L", Referrer: "ref2"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/foo", Browser: BrowserChrome, OS: OSWindows, Language: "en", CountryCode: "de", ScreenClass: "XL", Referrer: "ref1"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	filter := &Filter{From: pastDay(1), To: Today()}
+	pages, err := analyzer.PagesWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, pages.Stats, 2)
+	assert.Equal(t, 2, pages.Visitors)
+	referrer, err := analyzer.ReferrerWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, referrer.Stats, 2)
+	assert.Equal(t, 2, referrer.Visitors)
+	languages, err := analyzer.LanguagesWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, languages.Stats, 2)
+	assert.Equal(t, 2, languages.Visitors)
+	countries, err := analyzer.CountriesWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, countries.Stats, 2)
+	assert.Equal(t, 2, countries.Visitors)
+	browser, err := analyzer.BrowserWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, browser.Stats, 2)
+	assert.Equal(t, 2, browser.Visitors)
+	os, err := analyzer.OSWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, os.Stats, 2)
+	assert.Equal(t, 2, os.Visitors)
+	screen, err := analyzer.ScreenClassWithTotal(filter)
+	assert.NoError(t, err)
+	assert.Len(t, screen.Stats, 2)
+	assert.Equal(t, 2, screen.Visitors)
+	pages, err = analyzer.PagesWithTotal(&Filter{From: pastDay(1), To: Today(), Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, pages.Stats, 1)
+	assert.Equal(t, "/foo", pages.Stats.([]PageStats)[0].Path)
+	assert.Equal(t, 2, pages.Visitors)
+	_, err = analyzer.PagesWithTotal(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_PagesDistinctPerDay(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Hour), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp1", Time: Today(), Path: "/foo"},
+	}))
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: "event", Hit: Hit{Fingerprint: "fp1", Time: pastDay(1), Path: "/"}},
+		{Name: "event", Hit: Hit{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.Pages(&Filter{From: pastDay(1), To: Today(), DistinctPerDay: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "/foo", stats[0].Path)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 2, stats[0].Views)
+	assert.Equal(t, "/", stats[1].Path)
+	assert.Equal(t, 1, stats[1].Views)
+	events, err := analyzer.Events(&Filter{From: pastDay(1), To: Today(), EventName: "event", DistinctPerDay: true})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, 1, events[0].Views)
+	events, err = analyzer.Events(&Filter{From: pastDay(1), To: Today(), DistinctPerDay: true})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, 1, events[0].Views)
+	stats, err = analyzer.Pages(&Filter{From: pastDay(1), To: Today(), Path: "/foo", DistinctPerDay: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 3, stats[0].Views)
+	stats, err = analyzer.Pages(&Filter{From: pastDay(1), To: Today(), ExcludedWindows: []TimeWindow{{From: pastDay(1), To: pastDay(1).Add(time.Second)}}, DistinctPerDay: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "/foo", stats[0].Path)
+	assert.Equal(t, 1, stats[0].Visitors)
+	assert.Equal(t, 2, stats[0].Views)
+	_, err = analyzer.Pages(&Filter{ClientID: 42, Day: pastDay(1), Start: pastDay(1), DistinctPerDay: true})
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_PageVisitors(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(3), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.PageVisitors(nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Len(t, stats[0].Days, DefaultPeriodDays)
+	assert.Equal(t, pastDay(3), stats[0].Days[DefaultPeriodDays-4].Day.UTC())
+	assert.Equal(t, 1, stats[0].Days[DefaultPeriodDays-4].Visitors)
+	stats, err = analyzer.PageVisitors(&Filter{AllTime: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, "/", stats[0].Path)
+	assert.Equal(t, "/bar", stats[1].Path)
+	assert.Equal(t, "/foo", stats[2].Path)
+	assert.Len(t, stats[0].Days, 2)
+	assert.Equal(t, pastDay(3), stats[0].Days[0].Day.UTC())
+	assert.Equal(t, 1, stats[0].Days[0].Visitors)
+	assert.Equal(t, 2, stats[0].Days[0].Views)
+	assert.Equal(t, 2, stats[0].Days[1].Visitors)
+	assert.Equal(t, 0, stats[1].Days[0].Visitors)
+	assert.Equal(t, 1, stats[1].Days[1].Visitors)
+	stats, err = analyzer.PageVisitors(&Filter{From: pastDay(3), To: pastDay(1), Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "/", stats[0].Path)
+	assert.Len(t, stats[0].Days, 3)
+	assert.Equal(t, 0, stats[0].Days[1].Visitors)
+	_, err = analyzer.PageVisitors(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_PageDayMatrix(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(3), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	matrix, err := analyzer.PageDayMatrix(&Filter{From: pastDay(3), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/", "/bar", "/foo"}, matrix.Paths)
+	assert.Len(t, matrix.Days, 3)
+	assert.Len(t, matrix.Visitors, 3)
+	assert.Equal(t, []int{1, 0, 2}, matrix.Visitors[0])
+	assert.Equal(t, []int{0, 0, 1}, matrix.Visitors[1])
+	assert.Equal(t, []int{1, 0, 0}, matrix.Visitors[2])
+	assert.Equal(t, 2, matrix.Get("/", pastDay(1)))
+	assert.Equal(t, 1, matrix.Get("/foo", pastDay(3)))
+	assert.Equal(t, 0, matrix.Get("/foo", pastDay(2)))
+	assert.Equal(t, 0, matrix.Get("/missing", pastDay(1)))
+	cleanupDB()
+	matrix, err = analyzer.PageDayMatrix(&Filter{From: pastDay(3), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Empty(t, matrix.Paths)
+	assert.Len(t, matrix.Days, 3)
+	_, err = analyzer.PageDayMatrix(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_PagesStream(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), PreviousTimeOnPageSeconds: 60, Path: "/foo"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	pages, err := analyzer.Pages(&Filter{IncludeAvgTimeOnPage: true})
+	assert.NoError(t, err)
+	var streamed []PageStats
+	assert.NoError(t, analyzer.PagesStream(&Filter{IncludeAvgTimeOnPage: true}, func(stats PageStats) error {
+		streamed = append(streamed, stats)
+		return nil
+	}))
+	assert.Equal(t, pages, streamed)
+	streamed = streamed[:0]
+	assert.NoError(t, analyzer.PagesStream(&Filter{Limit: 2}, func(stats PageStats) error {
+		streamed = append(streamed, stats)
+		return nil
+	}))
+	assert.Len(t, streamed, 2)
+	assert.Equal(t, "/", streamed[0].Path)
+	errStop := errors.New("stop")
+	calls := 0
+	assert.Equal(t, errStop, analyzer.PagesStream(nil, func(stats PageStats) error {
+		calls++
+		return errStop
+	}))
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, analyzer.PagesStream(getMaxFilter(), func(stats PageStats) error {
+		return nil
+	}))
+}
+
+func TestAnalyzer_PagesAndAvgTimeOnPage(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(4), Session: pastDay(4), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(4).Add(time.Minute * 3), Session: pastDay(4), PreviousTimeOnPageSeconds: 180, Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(4), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(4), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(4), Path: "/bar"},
+		{Fingerprint: "fp3", Time: pastDay(4), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(4), Path: "/"},
+		{Fingerprint: "fp5", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp5", Time: pastDay(2).Add(time.Minute * 5), Session: pastDay(2).Add(time.Minute * 30), Path: "/bar"},
+		{Fingerprint: "fp6", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp6", Time: pastDay(2).Add(time.Minute * 10), Session: pastDay(2), PreviousTimeOnPageSeconds: 600, Path: "/bar"},
+		{Fingerprint: "fp6", Time: pastDay(2).Add(time.Minute * 11), Session: pastDay(2).Add(time.Hour), Path: "/bar"},
+		{Fingerprint: "fp6", Time: pastDay(2).Add(time.Minute * 21), Session: pastDay(2).Add(time.Hour), PreviousTimeOnPageSeconds: 600, Path: "/foo"},
+		{Fingerprint: "fp7", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp8", Time: pastDay(2), Path: "/"},
+		{Fingerprint: "fp9", Time: Today(), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.Pages(nil)
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 3)
+	assert.Equal(t, "/", visitors[0].Path)
+	assert.Equal(t, "/bar", visitors[1].Path)
+	assert.Equal(t, "/foo", visitors[2].Path)
+	assert.Equal(t, 9, visitors[0].Visitors)
+	assert.Equal(t, 3, visitors[1].Visitors)
+	assert.Equal(t, 2, visitors[2].Visitors)
 	assert.InDelta(t, 1, visitors[0].RelativeVisitors, 0.01)
 	assert.InDelta(t, 0.3333, visitors[1].RelativeVisitors, 0.01)
 	assert.InDelta(t, 0.2222, visitors[2].RelativeVisitors, 0.01)
@@ -275,8 +1267,18 @@ func TestAnalyzer_PagesAndAvgTimeOnPage(t *testing.T) {
 	assert.Equal(t, "/bar", atop[1].Path)
 	assert.Equal(t, 390, atop[0].AverageTimeSpentSeconds)
 	assert.Equal(t, 600, atop[1].AverageTimeSpentSeconds)
+	atop, err = analyzer.AvgTimeOnPages(&Filter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, atop, 1)
+	assert.Equal(t, "/", atop[0].Path)
+	assert.Equal(t, 390, atop[0].AverageTimeSpentSeconds)
 	top, err := analyzer.AvgTimeOnPage(nil)
 	assert.NoError(t, err)
+	assert.Len(t, top, DefaultPeriodDays)
+	assert.Equal(t, 180, top[DefaultPeriodDays-5].AverageTimeSpentSeconds)
+	assert.Equal(t, 600, top[DefaultPeriodDays-3].AverageTimeSpentSeconds)
+	top, err = analyzer.AvgTimeOnPage(&Filter{AllTime: true})
+	assert.NoError(t, err)
 	assert.Len(t, top, 2)
 	assert.Equal(t, pastDay(4), top[0].Day)
 	assert.Equal(t, pastDay(2), top[1].Day)
@@ -408,6 +1410,80 @@ func TestAnalyzer_EntryExitPages(t *testing.T) {
 	assert.InDelta(t, 0.33, exits[0].ExitRate, 0.01)
 }
 
+func TestAnalyzer_VisitorsWithAllPaths(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Path: "/pricing"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/docs"},
+		{Fingerprint: "fp1", Time: Today(), Path: "/blog"},
+		{Fingerprint: "fp2", Time: Today(), Path: "/blog"},
+		{Fingerprint: "fp2", Time: Today().Add(time.Minute), Path: "/docs"},
+		{Fingerprint: "fp2", Time: Today().Add(time.Minute * 2), Path: "/pricing"},
+		{Fingerprint: "fp2", Time: Today().Add(time.Minute * 3), Path: "/pricing"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/pricing"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/docs"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.VisitorsWithAllPaths(nil, []string{"/pricing", "/docs", "/blog"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, visitors)
+	visitors, err = analyzer.VisitorsWithAllPaths(&Filter{Day: Today(), Path: "/blog"}, []string{"/pricing", "/docs", "/blog", "/docs"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, visitors)
+	visitors, err = analyzer.VisitorsWithAllPaths(nil, []string{"/pricing", "/docs"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, visitors)
+	visitors, err = analyzer.VisitorsWithAllPaths(nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, visitors)
+	_, err = analyzer.VisitorsWithAllPaths(getMaxFilter(), []string{"/pricing"})
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_LandingConversion(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/campaign"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/signup"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/campaign"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/campaign"},
+		{Fingerprint: "fp4", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp4", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/campaign"},
+	}))
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: "signup", Hit: Hit{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/signup"}},
+		{Name: "signup", Hit: Hit{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute * 2), Session: pastDay(1), Path: "/signup"}},
+		{Name: "signup", Hit: Hit{Fingerprint: "fp3", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/campaign"}},
+		{Name: "other", Hit: Hit{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/campaign"}},
+		{Name: "signup", Hit: Hit{Fingerprint: "fp4", Time: pastDay(1), Session: pastDay(1), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.LandingConversion(nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, stats, 0)
+	stats, err = analyzer.LandingConversion(nil, "signup")
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "/campaign", stats[0].Path)
+	assert.Equal(t, 3, stats[0].Sessions)
+	assert.Equal(t, 1, stats[0].Conversions)
+	assert.InDelta(t, 0.33, stats[0].CR, 0.01)
+	assert.Equal(t, "/", stats[1].Path)
+	assert.Equal(t, 2, stats[1].Sessions)
+	assert.Equal(t, 2, stats[1].Conversions)
+	assert.InDelta(t, 1, stats[1].CR, 0.01)
+	stats, err = analyzer.LandingConversion(&Filter{Path: "/campaign"}, "signup")
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "/campaign", stats[0].Path)
+	assert.Equal(t, 1, stats[0].Conversions)
+	_, err = analyzer.LandingConversion(getMaxFilter(), "signup")
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_PageConversions(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -432,6 +1508,180 @@ func TestAnalyzer_PageConversions(t *testing.T) {
 	assert.InDelta(t, 0.5, stats.CR, 0.01)
 }
 
+func TestAnalyzer_CountSessions(t *testing.T) {
+	cleanupDB()
+	session := pastDay(2).Add(-time.Minute)
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: session, Session: session, Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Session: session, Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Hour * 5), Session: pastDay(2).Add(time.Hour * 5), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/foo"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	count, err := analyzer.CountSessions(&Filter{From: pastDay(3), To: Today()})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = analyzer.CountSessions(&Filter{From: pastDay(2), To: Today()})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = analyzer.CountSessions(&Filter{From: pastDay(3), To: Today(), Path: "/foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = analyzer.CountSessions(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_CountBounces(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Session: pastDay(2), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Session: pastDay(2), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	count, err := analyzer.CountBounces(&Filter{From: pastDay(2), To: Today()})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	visitors, err := analyzer.Visitors(&Filter{From: pastDay(2), To: Today(), SessionBounces: true})
+	assert.NoError(t, err)
+	bounces := 0
+
+	for _, v := range visitors {
+		bounces += v.Bounces
+	}
+
+	assert.Equal(t, count, bounces)
+	count, err = analyzer.CountBounces(&Filter{Day: pastDay(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	_, err = analyzer.CountBounces(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_CountEvents(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: "event1", MetaKeys: []string{"status"}, MetaValues: []string{"in"}, Hit: Hit{Fingerprint: "fp1", Time: Today(), Path: "/", CountryCode: "de"}},
+		{Name: "event1", Hit: Hit{Fingerprint: "fp1", Time: Today(), Path: "/foo", CountryCode: "de"}},
+		{Name: "event1", Hit: Hit{Fingerprint: "fp2", Time: pastDay(2), Path: "/", CountryCode: "gb"}},
+		{Name: "event2", MetaKeys: []string{"status"}, MetaValues: []string{"out"}, Hit: Hit{Fingerprint: "fp3", Time: Today(), Path: "/"}},
+		{Name: EventHeartbeat, DurationSeconds: 15, Hit: Hit{Fingerprint: "fp3", Time: Today(), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	count, err := analyzer.CountEvents(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+	count, err = analyzer.CountEvents(&Filter{EventName: EventHeartbeat})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = analyzer.CountEvents(&Filter{EventName: "event1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = analyzer.CountEvents(&Filter{EventName: "event1", Country: "de", Path: "/"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = analyzer.CountEvents(&Filter{EventName: "event1", From: pastDay(1), To: Today()})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = analyzer.CountEvents(&Filter{EventMetaKey: "status"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = analyzer.CountEvents(&Filter{EventMetaKey: "status", PathPattern: "^/$"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = analyzer.CountEvents(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_EventValue(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: "order", Value: 20, HasValue: true, Hit: Hit{Fingerprint: "fp1", Time: Today(), Path: "/"}},
+		{Name: "order", Value: 0, HasValue: true, Hit: Hit{Fingerprint: "fp2", Time: Today(), Path: "/"}},
+		{Name: "order", Value: 40.5, HasValue: true, Hit: Hit{Fingerprint: "fp3", Time: pastDay(1), Path: "/"}},
+		{Name: "order", Hit: Hit{Fingerprint: "fp4", Time: Today(), Path: "/"}},
+		{Name: "signup", Value: 100, HasValue: true, Hit: Hit{Fingerprint: "fp1", Time: Today(), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.EventValue(nil, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Count)
+	assert.InDelta(t, 60.5, stats.Total, 0.001)
+	assert.InDelta(t, 20.1666, stats.Average, 0.001)
+	stats, err = analyzer.EventValue(&Filter{From: Today(), To: Today()}, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Count)
+	assert.InDelta(t, 10, stats.Average, 0.001)
+	stats, err = analyzer.EventValue(nil, "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Count)
+	assert.InDelta(t, 0, stats.Average, 0.001)
+	_, err = analyzer.EventValue(getMaxFilter(), "order")
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_AverageEngagedTime(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: EventHeartbeat, DurationSeconds: 15, Hit: Hit{Fingerprint: "fp1", Time: Today(), Session: Today(), Path: "/"}},
+		{Name: EventHeartbeat, DurationSeconds: 15, Hit: Hit{Fingerprint: "fp1", Time: Today().Add(time.Second * 15), Session: Today(), Path: "/"}},
+		{Name: EventHeartbeat, DurationSeconds: 10, Hit: Hit{Fingerprint: "fp1", Time: Today().Add(time.Minute), Session: Today(), Path: "/foo"}},
+		{Name: EventHeartbeat, DurationSeconds: 20, Hit: Hit{Fingerprint: "fp2", Time: Today(), Session: Today(), Path: "/"}},
+		{Name: "event", DurationSeconds: 100, Hit: Hit{Fingerprint: "fp3", Time: Today(), Session: Today(), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.AverageEngagedTime(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Sessions)
+	assert.Equal(t, 30, stats.AverageEngagedSeconds)
+	assert.Len(t, stats.Pages, 2)
+	assert.Equal(t, PageEngagedTimeStats{Path: "/", Sessions: 2, AverageEngagedSeconds: 25}, stats.Pages[0])
+	assert.Equal(t, PageEngagedTimeStats{Path: "/foo", Sessions: 1, AverageEngagedSeconds: 10}, stats.Pages[1])
+	stats, err = analyzer.AverageEngagedTime(&Filter{Day: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Sessions)
+	assert.Equal(t, 0, stats.AverageEngagedSeconds)
+	assert.Empty(t, stats.Pages)
+	_, err = analyzer.AverageEngagedTime(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_EventMetaFilter(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: "purchase", MetaKeys: []string{"plan", "currency"}, MetaValues: []string{"pro", "EUR"}, Hit: Hit{Fingerprint: "fp1", Time: Today(), Path: "/"}},
+		{Name: "purchase", MetaKeys: []string{"plan", "currency"}, MetaValues: []string{"pro", "USD"}, Hit: Hit{Fingerprint: "fp2", Time: Today(), Path: "/"}},
+		{Name: "purchase", MetaKeys: []string{"currency", "plan"}, MetaValues: []string{"EUR", "pro"}, Hit: Hit{Fingerprint: "fp3", Time: Today(), Path: "/pricing"}},
+		{Name: "purchase", MetaKeys: []string{"plan"}, MetaValues: []string{"basic"}, Hit: Hit{Fingerprint: "fp4", Time: Today(), Path: "/"}},
+		{Name: "signup", MetaKeys: []string{"plan"}, MetaValues: []string{"pro"}, Hit: Hit{Fingerprint: "fp5", Time: Today(), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	count, err := analyzer.CountEvents(&Filter{EventName: "purchase", EventMeta: map[string]string{"plan": "pro"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = analyzer.CountEvents(&Filter{EventName: "purchase", EventMeta: map[string]string{"plan": "pro", "currency": "EUR"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = analyzer.CountEvents(&Filter{EventName: "purchase", Path: "/pricing", EventMeta: map[string]string{"plan": "pro", "currency": "EUR"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	visitors, err := analyzer.Visitors(&Filter{EventName: "purchase", EventMeta: map[string]string{"plan": "basic"}})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 30)
+	assert.Equal(t, 1, visitors[29].Visitors)
+}
+
 func TestAnalyzer_Events(t *testing.T) {
 	cleanupDB()
 
@@ -454,6 +1704,7 @@ func TestAnalyzer_Events(t *testing.T) {
 		{Name: "event2", DurationSeconds: 9, MetaKeys: []string{"status", "price", "third"}, MetaValues: []string{"in", "13.74", "param"}, Hit: Hit{Fingerprint: "fp3", Time: Today(), Path: "/simple/page"}},
 		{Name: "event2", DurationSeconds: 3, MetaKeys: []string{"price"}, MetaValues: []string{"34.56"}, Hit: Hit{Fingerprint: "fp4", Time: Today(), Path: "/"}},
 		{Name: "event2", DurationSeconds: 4, Hit: Hit{Fingerprint: "fp5", Time: Today(), Path: "/"}},
+		{Name: EventHeartbeat, DurationSeconds: 15, Hit: Hit{Fingerprint: "fp6", Time: Today(), Path: "/"}},
 	}))
 	time.Sleep(time.Millisecond * 20)
 	analyzer := NewAnalyzer(dbClient)
@@ -525,57 +1776,426 @@ func TestAnalyzer_Events(t *testing.T) {
 	assert.Equal(t, "13.74", stats[1].MetaValue)
 	stats, err = analyzer.EventBreakdown(&Filter{EventName: "event2", EventMetaKey: "third"})
 	assert.NoError(t, err)
-	assert.Len(t, stats, 1)
-	assert.Equal(t, "event2", stats[0].Name)
-	assert.Equal(t, 1, stats[0].Visitors)
-	assert.Equal(t, 1, stats[0].Views)
-	assert.InDelta(t, 0.1, stats[0].CR, 0.001)
-	assert.InDelta(t, 9, stats[0].AverageDurationSeconds, 0.001)
-	assert.Equal(t, "param", stats[0].MetaValue)
-	stats, err = analyzer.EventBreakdown(&Filter{EventName: "does-not-exist", EventMetaKey: "status"})
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "event2", stats[0].Name)
+	assert.Equal(t, 1, stats[0].Visitors)
+	assert.Equal(t, 1, stats[0].Views)
+	assert.InDelta(t, 0.1, stats[0].CR, 0.001)
+	assert.InDelta(t, 9, stats[0].AverageDurationSeconds, 0.001)
+	assert.Equal(t, "param", stats[0].MetaValue)
+	stats, err = analyzer.EventBreakdown(&Filter{EventName: "does-not-exist", EventMetaKey: "status"})
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+	stats, err = analyzer.EventBreakdown(&Filter{EventName: "event1", EventMetaKey: "does-not-exist"})
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+}
+
+func TestAnalyzer_OutboundAndDownloads(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: Today(), Path: "/"},
+		{Fingerprint: "fp2", Time: Today(), Path: "/"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/"},
+		{Fingerprint: "fp4", Time: Today(), Path: "/"},
+	}))
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{Name: EventOutbound, MetaKeys: []string{EventMetaURL}, MetaValues: []string{"https://example.com/"}, Hit: Hit{Fingerprint: "fp1", Time: Today(), Path: "/"}},
+		{Name: EventOutbound, MetaKeys: []string{EventMetaURL}, MetaValues: []string{"https://example.com/"}, Hit: Hit{Fingerprint: "fp2", Time: Today(), Path: "/"}},
+		{Name: EventOutbound, MetaKeys: []string{EventMetaURL}, MetaValues: []string{"https://pirsch.io/"}, Hit: Hit{Fingerprint: "fp2", Time: Today(), Path: "/"}},
+		{Name: EventDownload, MetaKeys: []string{EventMetaFile}, MetaValues: []string{"/report.pdf"}, Hit: Hit{Fingerprint: "fp3", Time: Today(), Path: "/"}},
+		{Name: "event", MetaKeys: []string{EventMetaURL}, MetaValues: []string{"https://example.com/"}, Hit: Hit{Fingerprint: "fp4", Time: Today(), Path: "/"}},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	outbound, err := analyzer.Outbound(&Filter{EventName: "event"})
+	assert.NoError(t, err)
+	assert.Len(t, outbound, 2)
+	assert.Equal(t, "https://example.com/", outbound[0].MetaValue)
+	assert.Equal(t, "https://pirsch.io/", outbound[1].MetaValue)
+	assert.Equal(t, 2, outbound[0].Visitors)
+	assert.Equal(t, 1, outbound[1].Visitors)
+	assert.InDelta(t, 0.5, outbound[0].CR, 0.01)
+	downloads, err := analyzer.Downloads(nil)
+	assert.NoError(t, err)
+	assert.Len(t, downloads, 1)
+	assert.Equal(t, "/report.pdf", downloads[0].MetaValue)
+	assert.Equal(t, 1, downloads[0].Visitors)
+	_, err = analyzer.Outbound(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Referrer(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/foo", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/bar", Referrer: "ref3"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp4", Time: time.Now(), Path: "/", Referrer: "ref1"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.Referrer(nil)
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 3)
+	assert.Equal(t, "ref1", visitors[0].Referrer)
+	assert.Equal(t, "ref2", visitors[1].Referrer)
+	assert.Equal(t, "ref3", visitors[2].Referrer)
+	assert.Equal(t, 3, visitors[0].Visitors)
+	assert.Equal(t, 2, visitors[1].Visitors)
+	assert.Equal(t, 1, visitors[2].Visitors)
+	assert.InDelta(t, 0.75, visitors[0].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0.5, visitors[1].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0.25, visitors[2].RelativeVisitors, 0.01)
+	assert.Equal(t, 2, visitors[0].Bounces)
+	assert.Equal(t, 2, visitors[1].Bounces)
+	assert.Equal(t, 1, visitors[2].Bounces)
+	assert.InDelta(t, 0.6666, visitors[0].BounceRate, 0.01)
+	assert.InDelta(t, 1, visitors[1].BounceRate, 0.01)
+	assert.InDelta(t, 1, visitors[2].BounceRate, 0.01)
+	_, err = analyzer.Referrer(getMaxFilter())
+	assert.NoError(t, err)
+	visitors, err = analyzer.Referrer(&Filter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 1)
+	visitors, err = analyzer.Referrer(&Filter{Referrer: "ref3", Referrers: []string{"ref2", ""}})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 2)
+	assert.Equal(t, "ref2", visitors[0].Referrer)
+	assert.Equal(t, "ref3", visitors[1].Referrer)
+}
+
+func TestAnalyzer_ReferrerTieBreak(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/", Referrer: "ref3"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/", Referrer: "ref1"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+
+	for i := 0; i < 3; i++ {
+		visitors, err := analyzer.Referrer(nil)
+		assert.NoError(t, err)
+		assert.Len(t, visitors, 3)
+		assert.Equal(t, "ref1", visitors[0].Referrer)
+		assert.Equal(t, "ref2", visitors[1].Referrer)
+		assert.Equal(t, "ref3", visitors[2].Referrer)
+	}
+}
+
+func TestAnalyzer_PathReferrers(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/blog", Referrer: "ref1"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/blog", Referrer: "ref2"},
+		{Fingerprint: "fp4", Time: time.Now(), Path: "/blog", Referrer: "ref2"},
+		{Fingerprint: "fp4", Time: time.Now(), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp5", Time: time.Now(), Path: "/", Referrer: "ref3"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.PathReferrers(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+	stats, err = analyzer.PathReferrers(&Filter{Path: "/blog"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "ref2", stats[0].Referrer)
+	assert.Equal(t, "ref1", stats[1].Referrer)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 1, stats[1].Visitors)
+	assert.InDelta(t, 0.6666, stats[0].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0.3333, stats[1].RelativeVisitors, 0.01)
+	assert.Equal(t, 1, stats[0].Bounces)
+	assert.Equal(t, 1, stats[1].Bounces)
+	stats, err = analyzer.PathReferrers(&Filter{Path: "/blog", Referrer: "ref1"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "ref1", stats[0].Referrer)
+	assert.InDelta(t, 1, stats[0].RelativeVisitors, 0.01)
+	_, err = analyzer.PathReferrers(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_TopItems(t *testing.T) {
+	cleanupDB()
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.TopItems(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, stats.Page)
+	assert.Nil(t, stats.Referrer)
+	assert.Nil(t, stats.Country)
+	assert.Nil(t, stats.Browser)
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref1", CountryCode: "de", Browser: BrowserChrome},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/foo", Referrer: "ref2", CountryCode: "de", Browser: BrowserFirefox},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/foo", Referrer: "ref2", CountryCode: "gb", Browser: BrowserFirefox},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	filter := &Filter{Limit: 5}
+	stats, err = analyzer.TopItems(filter)
+	assert.NoError(t, err)
+	assert.Equal(t, "/foo", stats.Page.Path)
+	assert.Equal(t, 2, stats.Page.Visitors)
+	assert.Equal(t, "ref2", stats.Referrer.Referrer)
+	assert.Equal(t, 2, stats.Referrer.Visitors)
+	assert.Equal(t, "de", stats.Country.CountryCode)
+	assert.Equal(t, 2, stats.Country.Visitors)
+	assert.Equal(t, BrowserFirefox, stats.Browser.Browser)
+	assert.Equal(t, 2, stats.Browser.Visitors)
+	assert.Equal(t, 5, filter.Limit)
+	_, err = analyzer.TopItems(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_EntryReferrers(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/pricing", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Second), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/pricing", Referrer: "ref1"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/pricing", Referrer: "ref2"},
+		{Fingerprint: "fp4", Time: pastDay(1), Session: pastDay(1), Path: "/", Referrer: "ref3"},
+		{Fingerprint: "fp4", Time: pastDay(1).Add(time.Second), Session: pastDay(1), Path: "/pricing", Referrer: "ref3"},
+		{Fingerprint: "fp5", Time: pastDay(1), Session: pastDay(1), Path: "/pricing"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.EntryReferrers(&Filter{AllTime: true})
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+	stats, err = analyzer.EntryReferrers(&Filter{Path: "/pricing", AllTime: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, EntryReferrerStats{Referrer: "ref1", Visitors: 2, Entries: 2}, stats[0])
+	assert.Equal(t, EntryReferrerStats{Visitors: 1, Entries: 1}, stats[1])
+	assert.Equal(t, EntryReferrerStats{Referrer: "ref2", Visitors: 1, Entries: 1}, stats[2])
+	stats, err = analyzer.EntryReferrers(&Filter{Path: "/", AllTime: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "ref3", stats[0].Referrer)
+	_, err = analyzer.EntryReferrers(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_ReferrerBounceRate(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Second), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Hour), Session: pastDay(1).Add(time.Hour), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Second), Session: pastDay(1), Path: "/bar"},
+		{Fingerprint: "fp4", Time: pastDay(1), Session: pastDay(1), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp4", Time: pastDay(1).Add(time.Second), Session: pastDay(1), Path: "/bar"},
+		{Fingerprint: "fp5", Time: pastDay(1), Session: pastDay(1), Path: "/", Referrer: "ref3"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.ReferrerBounceRate(&Filter{AllTime: true}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, "ref1", stats[0].Referrer)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 3, stats[0].Sessions)
+	assert.Equal(t, 2, stats[0].Bounces)
+	assert.InDelta(t, 0.6666, stats[0].BounceRate, 0.001)
+	assert.Equal(t, "ref2", stats[1].Referrer)
+	assert.Equal(t, 2, stats[1].Sessions)
+	assert.Equal(t, 0, stats[1].Bounces)
+	assert.InDelta(t, 0, stats[1].BounceRate, 0.001)
+	assert.Equal(t, "ref3", stats[2].Referrer)
+	assert.InDelta(t, 1, stats[2].BounceRate, 0.001)
+	stats, err = analyzer.ReferrerBounceRate(&Filter{AllTime: true}, 2)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	_, err = analyzer.ReferrerBounceRate(getMaxFilter(), 2)
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_ReferrerByVisitorType(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(3), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp3", Time: pastDay(2), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp4", Time: pastDay(1), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.ReferrerByVisitorType(&Filter{Day: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, ReferrerVisitorTypeStats{Referrer: "ref1", Visitors: 2, NewVisitors: 1, ReturningVisitors: 1}, stats[0])
+	assert.Equal(t, ReferrerVisitorTypeStats{Visitors: 1, NewVisitors: 1}, stats[1])
+	assert.Equal(t, ReferrerVisitorTypeStats{Referrer: "ref2", Visitors: 1, ReturningVisitors: 1}, stats[2])
+	stats, err = analyzer.ReferrerByVisitorType(&Filter{From: pastDay(3), To: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, ReferrerVisitorTypeStats{Referrer: "ref1", Visitors: 2, NewVisitors: 2, ReturningVisitors: 0}, stats[0])
+	_, err = analyzer.ReferrerByVisitorType(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_ReferrerLandingPages(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Session: pastDay(1), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: time.Now().Add(time.Second), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/blog", Referrer: "ref1"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/blog", Referrer: "ref1"},
+		{Fingerprint: "fp4", Time: time.Now(), Path: "/", Referrer: "ref2"},
+		{Fingerprint: "fp5", Time: time.Now(), Path: "/pricing", UTMCampaign: "launch"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.ReferrerLandingPages(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+	stats, err = analyzer.ReferrerLandingPages(&Filter{Referrer: "ref1"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "/blog", stats[0].Path)
+	assert.Equal(t, "/", stats[1].Path)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.Equal(t, 1, stats[1].Visitors)
+	assert.Equal(t, 2, stats[0].Entries)
+	assert.Equal(t, 1, stats[1].Entries)
+	stats, err = analyzer.ReferrerLandingPages(&Filter{UTMCampaign: "launch"})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "/pricing", stats[0].Path)
+	assert.Equal(t, 1, stats[0].Visitors)
+	_, err = analyzer.ReferrerLandingPages(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Channels(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Session: pastDay(1), Path: "/", Referrer: "https://www.google.com/"},
+		{Fingerprint: "fp1", Time: time.Now().Add(time.Second), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/", Referrer: "https://twitter.com/"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/", Referrer: "https://t.co/"},
+		{Fingerprint: "fp4", Time: time.Now(), Path: "/", Referrer: "https://example.com/"},
+		{Fingerprint: "fp5", Time: time.Now(), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	channels, err := analyzer.Channels(nil)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 4)
+	assert.Equal(t, "Social", channels[0].Channel)
+	assert.Equal(t, ChannelDirect, channels[1].Channel)
+	assert.Equal(t, ChannelOther, channels[2].Channel)
+	assert.Equal(t, "Search", channels[3].Channel)
+	assert.Equal(t, 2, channels[0].Visitors)
+	assert.Equal(t, 1, channels[1].Visitors)
+	assert.Equal(t, 1, channels[2].Visitors)
+	assert.Equal(t, 1, channels[3].Visitors)
+	assert.InDelta(t, 0.4, channels[0].RelativeVisitors, 0.01)
+	referrerChannels := DefaultReferrerChannels()
+	referrerChannels["example.com"] = "Partner"
+	analyzer.SetReferrerChannels(referrerChannels)
+	delete(referrerChannels, "example.com")
+	channels, err = analyzer.Channels(nil)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 4)
+	assert.Equal(t, "Partner", channels[2].Channel)
+	assert.NotContains(t, DefaultReferrerChannels(), "example.com")
+	_, err = analyzer.Channels(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_StatusCodes(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: Today(), Path: "/", StatusCode: 200},
+		{Fingerprint: "fp1", Time: Today(), Path: "/missing", StatusCode: 404},
+		{Fingerprint: "fp1", Time: Today(), Path: "/missing", StatusCode: 404},
+		{Fingerprint: "fp2", Time: Today(), Path: "/old", StatusCode: 404},
+		{Fingerprint: "fp3", Time: Today(), Path: "/error", StatusCode: 500},
+		{Fingerprint: "fp4", Time: Today(), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.StatusCodes(nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, StatusCodeStats{StatusCode: 404, Visitors: 2, Views: 3}, stats[0])
+	assert.Equal(t, StatusCodeStats{StatusCode: 200, Visitors: 1, Views: 1}, stats[1])
+	assert.Equal(t, StatusCodeStats{StatusCode: 500, Visitors: 1, Views: 1}, stats[2])
+	pages, err := analyzer.Pages(&Filter{StatusCode: 404})
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "/missing", pages[0].Path)
+	assert.Equal(t, "/old", pages[1].Path)
+	_, err = analyzer.StatusCodes(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Methods(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: Today(), Path: "/", Method: "GET"},
+		{Fingerprint: "fp1", Time: Today(), Path: "/api/user", Method: "POST"},
+		{Fingerprint: "fp1", Time: Today(), Path: "/api/user", Method: "POST"},
+		{Fingerprint: "fp2", Time: Today(), Path: "/api/order", Method: "POST"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/api/user", Method: "DELETE"},
+		{Fingerprint: "fp4", Time: Today(), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.Methods(&Filter{AllTime: true})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, MethodStats{Method: "POST", Visitors: 2, Views: 3}, stats[0])
+	assert.Equal(t, MethodStats{Method: "DELETE", Visitors: 1, Views: 1}, stats[1])
+	assert.Equal(t, MethodStats{Method: "GET", Visitors: 1, Views: 1}, stats[2])
+	pages, err := analyzer.Pages(&Filter{Method: "post", AllTime: true})
 	assert.NoError(t, err)
-	assert.Empty(t, stats)
-	stats, err = analyzer.EventBreakdown(&Filter{EventName: "event1", EventMetaKey: "does-not-exist"})
+	assert.Len(t, pages, 2)
+	assert.Equal(t, "/api/user", pages[0].Path)
+	assert.Equal(t, "/api/order", pages[1].Path)
+	_, err = analyzer.Methods(getMaxFilter())
 	assert.NoError(t, err)
-	assert.Empty(t, stats)
 }
 
-func TestAnalyzer_Referrer(t *testing.T) {
+func TestAnalyzer_SearchTerms(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
-		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref1"},
-		{Fingerprint: "fp1", Time: time.Now(), Path: "/foo", Referrer: "ref1"},
-		{Fingerprint: "fp1", Time: time.Now(), Path: "/", Referrer: "ref2"},
-		{Fingerprint: "fp2", Time: time.Now(), Path: "/", Referrer: "ref2"},
-		{Fingerprint: "fp2", Time: time.Now(), Path: "/bar", Referrer: "ref3"},
-		{Fingerprint: "fp3", Time: time.Now(), Path: "/", Referrer: "ref1"},
-		{Fingerprint: "fp4", Time: time.Now(), Path: "/", Referrer: "ref1"},
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/search", SearchQuery: "pirsch"},
+		{Fingerprint: "fp1", Time: time.Now(), Path: "/search", SearchQuery: "pirsch"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/search", SearchQuery: "pirsch"},
+		{Fingerprint: "fp2", Time: time.Now(), Path: "/search", SearchQuery: "analytics"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/search"},
+		{Fingerprint: "fp3", Time: time.Now(), Path: "/"},
 	}))
 	time.Sleep(time.Millisecond * 20)
 	analyzer := NewAnalyzer(dbClient)
-	visitors, err := analyzer.Referrer(nil)
+	terms, err := analyzer.SearchTerms(nil)
 	assert.NoError(t, err)
-	assert.Len(t, visitors, 3)
-	assert.Equal(t, "ref1", visitors[0].Referrer)
-	assert.Equal(t, "ref2", visitors[1].Referrer)
-	assert.Equal(t, "ref3", visitors[2].Referrer)
-	assert.Equal(t, 3, visitors[0].Visitors)
-	assert.Equal(t, 2, visitors[1].Visitors)
-	assert.Equal(t, 1, visitors[2].Visitors)
-	assert.InDelta(t, 0.75, visitors[0].RelativeVisitors, 0.01)
-	assert.InDelta(t, 0.5, visitors[1].RelativeVisitors, 0.01)
-	assert.InDelta(t, 0.25, visitors[2].RelativeVisitors, 0.01)
-	assert.Equal(t, 2, visitors[0].Bounces)
-	assert.Equal(t, 2, visitors[1].Bounces)
-	assert.Equal(t, 1, visitors[2].Bounces)
-	assert.InDelta(t, 0.6666, visitors[0].BounceRate, 0.01)
-	assert.InDelta(t, 1, visitors[1].BounceRate, 0.01)
-	assert.InDelta(t, 1, visitors[2].BounceRate, 0.01)
-	_, err = analyzer.Referrer(getMaxFilter())
+	assert.Len(t, terms, 2)
+	assert.Equal(t, "pirsch", terms[0].SearchQuery)
+	assert.Equal(t, "analytics", terms[1].SearchQuery)
+	assert.Equal(t, 3, terms[0].Searches)
+	assert.Equal(t, 2, terms[0].Visitors)
+	assert.Equal(t, 1, terms[1].Searches)
+	assert.Equal(t, 1, terms[1].Visitors)
+	terms, err = analyzer.SearchTerms(&Filter{Limit: 1})
 	assert.NoError(t, err)
-	visitors, err = analyzer.Referrer(&Filter{Limit: 1})
+	assert.Len(t, terms, 1)
+	_, err = analyzer.SearchTerms(getMaxFilter())
 	assert.NoError(t, err)
-	assert.Len(t, visitors, 1)
 }
 
 func TestAnalyzer_Platform(t *testing.T) {
@@ -599,10 +2219,76 @@ func TestAnalyzer_Platform(t *testing.T) {
 	assert.InDelta(t, 0.5, platform.RelativePlatformDesktop, 0.01)
 	assert.InDelta(t, 0.3333, platform.RelativePlatformMobile, 0.01)
 	assert.InDelta(t, 0.1666, platform.RelativePlatformUnknown, 0.01)
+	platform, err = analyzer.Platform(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, platform.PlatformDesktop)
 	_, err = analyzer.Platform(getMaxFilter())
 	assert.NoError(t, err)
 }
 
+func TestAnalyzer_PlatformTablet(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Desktop: true},
+		{Fingerprint: "fp2", Time: time.Now(), Mobile: true},
+		{Fingerprint: "fp3", Time: time.Now(), Mobile: true, Tablet: true},
+		{Fingerprint: "fp4", Time: time.Now(), Mobile: true, Tablet: true},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	platform, err := analyzer.Platform(&Filter{From: pastDay(5), To: Today()})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, platform.PlatformDesktop)
+	assert.Equal(t, 1, platform.PlatformMobile)
+	assert.Equal(t, 2, platform.PlatformTablet)
+	assert.Equal(t, 0, platform.PlatformUnknown)
+	assert.InDelta(t, 0.25, platform.RelativePlatformDesktop, 0.01)
+	assert.InDelta(t, 0.25, platform.RelativePlatformMobile, 0.01)
+	assert.InDelta(t, 0.5, platform.RelativePlatformTablet, 0.01)
+	trend, err := analyzer.PlatformTrend(&Filter{From: Today(), To: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, trend, 1)
+	assert.Equal(t, 2, trend[0].PlatformTablet)
+	assert.InDelta(t, 0.5, trend[0].RelativePlatformTablet, 0.01)
+	visitors, err := analyzer.Visitors(&Filter{From: Today(), To: Today(), Platform: PlatformMobile})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 1)
+	assert.Equal(t, 1, visitors[0].Visitors)
+}
+
+func TestAnalyzer_PlatformTrend(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Desktop: true},
+		{Fingerprint: "fp1", Time: pastDay(2).Add(time.Minute), Desktop: true},
+		{Fingerprint: "fp2", Time: pastDay(2), Mobile: true},
+		{Fingerprint: "fp3", Time: pastDay(2), Mobile: true},
+		{Fingerprint: "fp4", Time: pastDay(2)},
+		{Fingerprint: "fp5", Time: Today(), Mobile: true},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	trend, err := analyzer.PlatformTrend(&Filter{From: pastDay(2), To: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, trend, 3)
+	assert.Equal(t, pastDay(2), trend[0].Day)
+	assert.Equal(t, pastDay(1), trend[1].Day)
+	assert.Equal(t, Today(), trend[2].Day)
+	assert.Equal(t, 1, trend[0].PlatformDesktop)
+	assert.Equal(t, 2, trend[0].PlatformMobile)
+	assert.Equal(t, 1, trend[0].PlatformUnknown)
+	assert.InDelta(t, 0.25, trend[0].RelativePlatformDesktop, 0.01)
+	assert.InDelta(t, 0.5, trend[0].RelativePlatformMobile, 0.01)
+	assert.InDelta(t, 0.25, trend[0].RelativePlatformUnknown, 0.01)
+	assert.Equal(t, 0, trend[1].PlatformDesktop)
+	assert.Equal(t, 0, trend[1].PlatformMobile)
+	assert.InDelta(t, 0, trend[1].RelativePlatformMobile, 0.01)
+	assert.Equal(t, 1, trend[2].PlatformMobile)
+	assert.InDelta(t, 1, trend[2].RelativePlatformMobile, 0.01)
+	_, err = analyzer.PlatformTrend(getMaxFilter())
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_Languages(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -632,6 +2318,34 @@ func TestAnalyzer_Languages(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAnalyzer_Locales(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), Language: "en", Locale: "en-US"},
+		{Fingerprint: "fp1", Time: time.Now(), Language: "en", Locale: "en-US"},
+		{Fingerprint: "fp2", Time: time.Now(), Language: "en", Locale: "en-GB"},
+		{Fingerprint: "fp3", Time: time.Now(), Language: "en", Locale: "en-US"},
+		{Fingerprint: "fp4", Time: time.Now(), Language: "de", Locale: "de-CH"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.Locales(nil)
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 3)
+	assert.Equal(t, "en-US", visitors[0].Locale)
+	assert.Equal(t, "de-CH", visitors[1].Locale)
+	assert.Equal(t, "en-GB", visitors[2].Locale)
+	assert.Equal(t, 2, visitors[0].Visitors)
+	assert.Equal(t, 1, visitors[1].Visitors)
+	assert.InDelta(t, 0.5, visitors[0].RelativeVisitors, 0.01)
+	languages, err := analyzer.Languages(nil)
+	assert.NoError(t, err)
+	assert.Len(t, languages, 2)
+	assert.Equal(t, "en", languages[0].Language)
+	_, err = analyzer.Locales(getMaxFilter())
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_Countries(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -657,6 +2371,9 @@ func TestAnalyzer_Countries(t *testing.T) {
 	assert.InDelta(t, 0.75, visitors[0].RelativeVisitors, 0.01)
 	assert.InDelta(t, 0.5, visitors[1].RelativeVisitors, 0.01)
 	assert.InDelta(t, 0.25, visitors[2].RelativeVisitors, 0.01)
+	total, err := analyzer.TotalVisitors(&Filter{Country: "DE", Countries: []string{"jp", ""}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total.Visitors)
 	_, err = analyzer.Countries(getMaxFilter())
 	assert.NoError(t, err)
 }
@@ -733,6 +2450,202 @@ func TestAnalyzer_BrowserVersion(t *testing.T) {
 	assert.InDelta(t, 0.1428, visitors[5].RelativeVisitors, 0.001)
 }
 
+func TestAnalyzer_BrowserAndOSTrend(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Browser: BrowserChrome, OS: OSWindows},
+		{Fingerprint: "fp2", Time: pastDay(2), Browser: BrowserChrome, OS: OSWindows},
+		{Fingerprint: "fp3", Time: pastDay(2), Browser: BrowserFirefox, OS: OSLinux},
+		{Fingerprint: "fp4", Time: pastDay(2), Browser: BrowserSafari, OS: OSMac},
+		{Fingerprint: "fp5", Time: Today(), Browser: BrowserFirefox, OS: OSLinux},
+		{Fingerprint: "fp6", Time: Today(), Browser: BrowserEdge, OS: OSWindows},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	trend, err := analyzer.BrowserTrend(&Filter{From: pastDay(2), To: Today(), Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, trend, 3)
+	assert.Equal(t, BrowserChrome, trend[0].Name)
+	assert.Equal(t, BrowserFirefox, trend[1].Name)
+	assert.Equal(t, TrendOther, trend[2].Name)
+	assert.Len(t, trend[0].Days, 3)
+	assert.Equal(t, pastDay(2), trend[0].Days[0].Day)
+	assert.Equal(t, pastDay(1), trend[0].Days[1].Day)
+	assert.Equal(t, Today(), trend[0].Days[2].Day)
+	assert.Equal(t, 2, trend[0].Days[0].Visitors)
+	assert.Equal(t, 0, trend[0].Days[1].Visitors)
+	assert.Equal(t, 0, trend[0].Days[2].Visitors)
+	assert.InDelta(t, 0.5, trend[0].Days[0].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0, trend[0].Days[1].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0.25, trend[1].Days[0].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0.5, trend[1].Days[2].RelativeVisitors, 0.01)
+	assert.Equal(t, 1, trend[2].Days[0].Visitors)
+	assert.Equal(t, 1, trend[2].Days[2].Visitors)
+	trend, err = analyzer.OSTrend(&Filter{From: pastDay(2), To: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, trend, 3)
+	assert.Equal(t, OSWindows, trend[0].Name)
+	assert.Equal(t, OSLinux, trend[1].Name)
+	assert.Equal(t, OSMac, trend[2].Name)
+	_, err = analyzer.BrowserTrend(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_PageEngagementScore(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/foo", PreviousTimeOnPageSeconds: 60},
+		{Fingerprint: "fp1", Time: pastDay(1).Add(time.Minute * 3), Session: pastDay(1), Path: "/", PreviousTimeOnPageSeconds: 120},
+		{Fingerprint: "fp2", Time: pastDay(1), Session: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Session: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp3", Time: pastDay(1).Add(time.Minute), Session: pastDay(1), Path: "/foo", PreviousTimeOnPageSeconds: 60},
+		{Fingerprint: "fp4", Time: pastDay(1), Session: pastDay(1), Path: "/bar"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.PageEngagementScore(&Filter{AllTime: true}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, "/foo", stats[0].Path)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.InDelta(t, 0.5, stats[0].BounceRate, 0.001)
+	assert.Equal(t, 90, stats[0].AverageTimeSpentSeconds)
+	assert.InDelta(t, 0.5, stats[0].Score, 0.001)
+	assert.Equal(t, "/", stats[1].Path)
+	assert.Equal(t, 60, stats[1].AverageTimeSpentSeconds)
+	assert.InDelta(t, 0.3333, stats[1].Score, 0.001)
+	assert.Equal(t, "/bar", stats[2].Path)
+	assert.InDelta(t, 0, stats[2].Score, 0.001)
+	stats, err = analyzer.PageEngagementScore(&Filter{AllTime: true, Limit: 2}, &EngagementScoreWeights{Visitors: 1})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "/", stats[0].Path)
+	assert.InDelta(t, 1, stats[0].Score, 0.001)
+	assert.Equal(t, "/foo", stats[1].Path)
+	assert.InDelta(t, 1, stats[1].Score, 0.001)
+	_, err = analyzer.PageEngagementScore(&Filter{AllTime: true}, &EngagementScoreWeights{Visitors: 1, TimeOnPage: -1})
+	assert.ErrorIs(t, err, ErrInvalidWeights)
+	_, err = analyzer.PageEngagementScore(getMaxFilter(), nil)
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_PageMovers(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp2", Time: pastDay(1), Path: "/"},
+		{Fingerprint: "fp3", Time: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp4", Time: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp5", Time: pastDay(1), Path: "/foo"},
+		{Fingerprint: "fp6", Time: pastDay(1), Path: "/bar"},
+		{Fingerprint: "fp7", Time: pastDay(1), Path: "/old"},
+		{Fingerprint: "fp1", Time: Today(), Path: "/"},
+		{Fingerprint: "fp2", Time: Today(), Path: "/"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/"},
+		{Fingerprint: "fp4", Time: Today(), Path: "/foo"},
+		{Fingerprint: "fp6", Time: Today(), Path: "/bar"},
+		{Fingerprint: "fp7", Time: Today(), Path: "/new"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	_, err := analyzer.PageMovers(&Filter{AllTime: true})
+	assert.ErrorIs(t, err, ErrNoPeriodOrDay)
+	stats, err := analyzer.PageMovers(&Filter{Day: Today()})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 5)
+	assert.Equal(t, PageMoverStats{Path: "/foo", Visitors: 1, PreviousVisitors: 3, Change: -2, RelativeChange: -2.0 / 3.0}, stats[0])
+	assert.Equal(t, PageMoverStats{Path: "/", Visitors: 3, PreviousVisitors: 2, Change: 1, RelativeChange: 0.5}, stats[1])
+	assert.Equal(t, PageMoverStats{Path: "/new", Visitors: 1, Change: 1, New: true}, stats[2])
+	assert.Equal(t, PageMoverStats{Path: "/old", PreviousVisitors: 1, Change: -1, RelativeChange: -1, Removed: true}, stats[3])
+	assert.Equal(t, PageMoverStats{Path: "/bar", Visitors: 1, PreviousVisitors: 1}, stats[4])
+	stats, err = analyzer.PageMovers(&Filter{Day: Today(), Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	_, err = analyzer.PageMovers(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_Comparison(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), Browser: BrowserChrome, CountryCode: "de", Referrer: "ref1"},
+		{Fingerprint: "fp2", Time: pastDay(2), Browser: BrowserFirefox, CountryCode: "de", Referrer: "ref2"},
+		{Fingerprint: "fp3", Time: pastDay(2), Browser: BrowserFirefox, CountryCode: "gb", Referrer: "ref2"},
+		{Fingerprint: "fp4", Time: pastDay(1), Browser: BrowserChrome, CountryCode: "de", Referrer: "ref1"},
+		{Fingerprint: "fp5", Time: pastDay(1), Browser: BrowserChrome, CountryCode: "fr", Referrer: "ref1"},
+		{Fingerprint: "fp6", Time: pastDay(1), Browser: BrowserSafari, CountryCode: "fr", Referrer: "ref3"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	_, err := analyzer.BrowserComparison(nil)
+	assert.NoError(t, err)
+	_, err = analyzer.BrowserComparison(&Filter{AllTime: true})
+	assert.ErrorIs(t, err, ErrNoPeriodOrDay)
+	stats, err := analyzer.BrowserComparison(&Filter{Day: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, ComparisonStats{Key: BrowserChrome, Visitors: 2, PreviousVisitors: 1}, stats[0])
+	assert.Equal(t, ComparisonStats{Key: BrowserSafari, Visitors: 1, PreviousVisitors: 0}, stats[1])
+	assert.Equal(t, ComparisonStats{Key: BrowserFirefox, Visitors: 0, PreviousVisitors: 2}, stats[2])
+	stats, err = analyzer.CountryComparison(&Filter{Day: pastDay(1), Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, ComparisonStats{Key: "fr", Visitors: 2, PreviousVisitors: 0}, stats[0])
+	assert.Equal(t, ComparisonStats{Key: "de", Visitors: 1, PreviousVisitors: 2}, stats[1])
+	stats, err = analyzer.ReferrerComparison(&Filter{Day: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "ref1", stats[0].Key)
+	assert.Equal(t, 0, stats[0].PreviousVisitors)
+	_, err = analyzer.OSComparison(getMaxFilter())
+	assert.NoError(t, err)
+	_, err = analyzer.LanguageComparison(getMaxFilter())
+	assert.NoError(t, err)
+}
+
+func TestAnalyzer_CountryTrend(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: pastDay(2), CountryCode: "de"},
+		{Fingerprint: "fp2", Time: pastDay(2), CountryCode: "DE"},
+		{Fingerprint: "fp3", Time: pastDay(2), CountryCode: "gb"},
+		{Fingerprint: "fp4", Time: pastDay(2), CountryCode: "us"},
+		{Fingerprint: "fp5", Time: Today(), CountryCode: "gb"},
+		{Fingerprint: "fp6", Time: Today(), CountryCode: "fr"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	trend, err := analyzer.CountryTrend(&Filter{From: pastDay(2), To: Today()}, []string{"GB", "de", "gb", "jp"})
+	assert.NoError(t, err)
+	assert.Len(t, trend, 3)
+	assert.Equal(t, "gb", trend[0].Name)
+	assert.Equal(t, "de", trend[1].Name)
+	assert.Equal(t, "jp", trend[2].Name)
+	assert.Len(t, trend[0].Days, 3)
+	assert.Equal(t, pastDay(2), trend[0].Days[0].Day)
+	assert.Equal(t, pastDay(1), trend[0].Days[1].Day)
+	assert.Equal(t, Today(), trend[0].Days[2].Day)
+	assert.Equal(t, 1, trend[0].Days[0].Visitors)
+	assert.Equal(t, 0, trend[0].Days[1].Visitors)
+	assert.Equal(t, 1, trend[0].Days[2].Visitors)
+	assert.InDelta(t, 0.25, trend[0].Days[0].RelativeVisitors, 0.01)
+	assert.InDelta(t, 0.5, trend[0].Days[2].RelativeVisitors, 0.01)
+	assert.Equal(t, 2, trend[1].Days[0].Visitors)
+	assert.InDelta(t, 0.5, trend[1].Days[0].RelativeVisitors, 0.01)
+	assert.Equal(t, 0, trend[2].Days[0].Visitors)
+	trend, err = analyzer.CountryTrend(nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, trend)
+	_, err = analyzer.CountryTrend(nil, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"})
+	assert.ErrorIs(t, err, ErrTooManyCountries)
+	trend, err = analyzer.CountryTrend(nil, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "J", " ", ""})
+	assert.NoError(t, err)
+	assert.Len(t, trend, 10)
+	_, err = analyzer.CountryTrend(getMaxFilter(), []string{"de"})
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_OS(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -805,6 +2718,34 @@ func TestAnalyzer_OSVersion(t *testing.T) {
 	assert.InDelta(t, 0.1428, visitors[5].RelativeVisitors, 0.001)
 }
 
+func TestAnalyzer_ScreenPercentiles(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: time.Now(), ScreenWidth: 1280, ScreenHeight: 720},
+		{Fingerprint: "fp1", Time: time.Now(), ScreenWidth: 1280, ScreenHeight: 720},
+		{Fingerprint: "fp2", Time: time.Now(), ScreenWidth: 1440, ScreenHeight: 900},
+		{Fingerprint: "fp3", Time: time.Now(), ScreenWidth: 1920, ScreenHeight: 1080},
+		{Fingerprint: "fp4", Time: time.Now(), ScreenWidth: 2560, ScreenHeight: 1440},
+		{Fingerprint: "fp5", Time: time.Now()},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.ScreenPercentiles(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, stats.Visitors)
+	assert.Equal(t, 1920, stats.WidthP50)
+	assert.Equal(t, 2560, stats.WidthP75)
+	assert.Equal(t, 2560, stats.WidthP95)
+	assert.Equal(t, 1080, stats.HeightP50)
+	assert.Equal(t, 1440, stats.HeightP95)
+	stats, err = analyzer.ScreenPercentiles(&Filter{From: pastDay(2), To: pastDay(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Visitors)
+	assert.Equal(t, 0, stats.WidthP50)
+	_, err = analyzer.ScreenPercentiles(getMaxFilter())
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_ScreenClass(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -919,6 +2860,33 @@ func TestAnalyzer_UTM(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAnalyzer_UTMSourceMedium(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: Today(), Path: "/", UTMSource: "google", UTMMedium: "cpc"},
+		{Fingerprint: "fp2", Time: Today(), Path: "/", UTMSource: "google", UTMMedium: "cpc"},
+		{Fingerprint: "fp3", Time: Today(), Path: "/", UTMSource: "google", UTMMedium: "organic"},
+		{Fingerprint: "fp4", Time: Today(), Path: "/", UTMSource: "newsletter"},
+		{Fingerprint: "fp5", Time: Today(), Path: "/"},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	stats, err := analyzer.UTMSourceMedium(nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, "google", stats[0].UTMSource)
+	assert.Equal(t, "cpc", stats[0].UTMMedium)
+	assert.Equal(t, 2, stats[0].Visitors)
+	assert.InDelta(t, 0.4, stats[0].RelativeVisitors, 0.01)
+	assert.Equal(t, "google", stats[1].UTMSource)
+	assert.Equal(t, "organic", stats[1].UTMMedium)
+	assert.Equal(t, "newsletter", stats[2].UTMSource)
+	assert.Equal(t, UTMNotSet, stats[2].UTMMedium)
+	assert.Equal(t, 1, stats[2].Visitors)
+	_, err = analyzer.UTMSourceMedium(getMaxFilter())
+	assert.NoError(t, err)
+}
+
 func TestAnalyzer_AvgTimeOnPage(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -954,12 +2922,142 @@ func TestAnalyzer_AvgTimeOnPage(t *testing.T) {
 	assert.Equal(t, 0, byDay[3].AverageTimeSpentSeconds)
 	byDay, err = analyzer.AvgTimeOnPage(&Filter{MaxTimeOnPageSeconds: 5})
 	assert.NoError(t, err)
+	assert.Len(t, byDay, DefaultPeriodDays)
+	assert.Equal(t, 5, byDay[DefaultPeriodDays-4].AverageTimeSpentSeconds)
+	assert.Equal(t, 4, byDay[DefaultPeriodDays-3].AverageTimeSpentSeconds)
+	assert.Equal(t, 5, byDay[DefaultPeriodDays-2].AverageTimeSpentSeconds)
+	byDay, err = analyzer.AvgTimeOnPage(&Filter{MaxTimeOnPageSeconds: 5, AllTime: true})
+	assert.NoError(t, err)
 	assert.Len(t, byDay, 3)
 	assert.Equal(t, 5, byDay[0].AverageTimeSpentSeconds)
 	assert.Equal(t, 4, byDay[1].AverageTimeSpentSeconds)
 	assert.Equal(t, 5, byDay[2].AverageTimeSpentSeconds)
 }
 
+func TestAnalyzer_Limit(t *testing.T) {
+	cleanupDB()
+	now := time.Now().UTC().Add(-time.Minute * 5)
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: now, Session: now, Path: "/", Referrer: "ref1", Language: "en", CountryCode: "gb", Browser: BrowserChrome, BrowserVersion: "90", OS: OSWindows, OSVersion: "10", ScreenClass: "XL", UTMSource: "s1", UTMMedium: "m1", UTMCampaign: "c1", UTMContent: "ct1", UTMTerm: "t1"},
+		{Fingerprint: "fp1", Time: now.Add(time.Minute), Session: now, Path: "/foo", PreviousTimeOnPageSeconds: 60},
+		{Fingerprint: "fp2", Time: now, Session: now, Path: "/bar", Referrer: "ref2", Language: "de", CountryCode: "de", Browser: BrowserFirefox, BrowserVersion: "89", OS: OSMac, OSVersion: "11", ScreenClass: "L", UTMSource: "s2", UTMMedium: "m2", UTMCampaign: "c2", UTMContent: "ct2", UTMTerm: "t2"},
+		{Fingerprint: "fp2", Time: now.Add(time.Minute * 2), Session: now, Path: "/", PreviousTimeOnPageSeconds: 120},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	filter := &Filter{From: pastDay(1), To: Today(), Limit: 1}
+	active, _, err := analyzer.ActiveVisitors(&Filter{Limit: 1}, time.Minute*10)
+	assert.NoError(t, err)
+	assert.Len(t, active, 1)
+	pages, err := analyzer.Pages(filter)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+	entries, err := analyzer.EntryPages(filter)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	exits, err := analyzer.ExitPages(filter)
+	assert.NoError(t, err)
+	assert.Len(t, exits, 1)
+	referrer, err := analyzer.Referrer(filter)
+	assert.NoError(t, err)
+	assert.Len(t, referrer, 1)
+	languages, err := analyzer.Languages(filter)
+	assert.NoError(t, err)
+	assert.Len(t, languages, 1)
+	countries, err := analyzer.Countries(filter)
+	assert.NoError(t, err)
+	assert.Len(t, countries, 1)
+	browser, err := analyzer.Browser(filter)
+	assert.NoError(t, err)
+	assert.Len(t, browser, 1)
+	browserVersion, err := analyzer.BrowserVersion(filter)
+	assert.NoError(t, err)
+	assert.Len(t, browserVersion, 1)
+	os, err := analyzer.OS(filter)
+	assert.NoError(t, err)
+	assert.Len(t, os, 1)
+	osVersion, err := analyzer.OSVersion(filter)
+	assert.NoError(t, err)
+	assert.Len(t, osVersion, 1)
+	screenClass, err := analyzer.ScreenClass(filter)
+	assert.NoError(t, err)
+	assert.Len(t, screenClass, 1)
+	utmSource, err := analyzer.UTMSource(filter)
+	assert.NoError(t, err)
+	assert.Len(t, utmSource, 1)
+	utmMedium, err := analyzer.UTMMedium(filter)
+	assert.NoError(t, err)
+	assert.Len(t, utmMedium, 1)
+	utmCampaign, err := analyzer.UTMCampaign(filter)
+	assert.NoError(t, err)
+	assert.Len(t, utmCampaign, 1)
+	utmContent, err := analyzer.UTMContent(filter)
+	assert.NoError(t, err)
+	assert.Len(t, utmContent, 1)
+	utmTerm, err := analyzer.UTMTerm(filter)
+	assert.NoError(t, err)
+	assert.Len(t, utmTerm, 1)
+	timeOnPages, err := analyzer.AvgTimeOnPages(filter)
+	assert.NoError(t, err)
+	assert.Len(t, timeOnPages, 1)
+	filter.Limit = 0
+	pages, err = analyzer.Pages(filter)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 3)
+}
+
+func TestAnalyzer_FillDays(t *testing.T) {
+	analyzer := NewAnalyzer(dbClient)
+	filter := NewFilter(NullClient)
+	filter.Timezone = time.UTC
+	assert.Empty(t, analyzer.fillDays(filter, []VisitorStats{}))
+	stats := analyzer.fillDays(filter, []VisitorStats{
+		{Day: pastDay(5), Visitors: 1},
+		{Day: pastDay(2), Visitors: 2},
+		{Day: pastDay(1), Visitors: 3},
+	})
+	assert.Len(t, stats, 5)
+	assert.Equal(t, VisitorStats{Day: pastDay(4)}, stats[1])
+	assert.Equal(t, VisitorStats{Day: pastDay(3)}, stats[2])
+	assert.Equal(t, 3, stats[4].Visitors)
+	filter.ExcludedWindows = []TimeWindow{{From: pastDay(4), To: pastDay(3)}}
+	stats = analyzer.fillDays(filter, []VisitorStats{
+		{Day: pastDay(5), Visitors: 1},
+		{Day: pastDay(2), Visitors: 2},
+	})
+	assert.Len(t, stats, 3)
+	assert.Equal(t, pastDay(3), stats[1].Day)
+}
+
+func TestAnalyzer_FindAnomalies(t *testing.T) {
+	analyzer := NewAnalyzer(dbClient)
+	stats := []VisitorStats{
+		{Day: pastDay(8), Visitors: 10},
+		{Day: pastDay(7), Visitors: 12},
+		{Day: pastDay(6), Visitors: 10},
+		{Day: pastDay(5), Visitors: 12},
+		{Day: pastDay(4), Visitors: 30},
+		{Day: pastDay(3), Visitors: 11},
+		{Day: pastDay(2), Visitors: 0},
+		{Day: pastDay(1), Visitors: 0},
+	}
+	anomalies := analyzer.findAnomalies(stats, 4, 3)
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, pastDay(4), anomalies[0].Day)
+	assert.Equal(t, 30, anomalies[0].Visitors)
+	assert.InDelta(t, 11, anomalies[0].Mean, 0.001)
+	assert.InDelta(t, 1, anomalies[0].StdDev, 0.001)
+	assert.InDelta(t, 19, anomalies[0].ZScore, 0.001)
+	anomalies = analyzer.findAnomalies(stats, 4, 1)
+	assert.Len(t, anomalies, 3)
+	assert.Equal(t, pastDay(4), anomalies[0].Day)
+	assert.Equal(t, pastDay(2), anomalies[1].Day)
+	assert.True(t, anomalies[1].ZScore < 0)
+	assert.Equal(t, pastDay(1), anomalies[2].Day)
+	assert.Empty(t, analyzer.findAnomalies(stats[:3], 4, 3))
+	assert.Empty(t, analyzer.findAnomalies([]VisitorStats{{Visitors: 5}, {Visitors: 5}, {Visitors: 9}}, 2, 3))
+}
+
 func TestAnalyzer_CalculateGrowth(t *testing.T) {
 	analyzer := NewAnalyzer(dbClient)
 	growth := analyzer.calculateGrowth(0, 0)
@@ -974,6 +3072,70 @@ func TestAnalyzer_CalculateGrowth(t *testing.T) {
 	assert.InDelta(t, -0.5, growth, 0.001)
 }
 
+func TestAnalyzer_EmptyResults(t *testing.T) {
+	cleanupDB()
+	analyzer := NewAnalyzer(dbClient)
+	filter := &Filter{AllTime: true}
+	results := make([]interface{}, 0)
+	visitors, err := analyzer.Visitors(filter)
+	assert.NoError(t, err)
+	results = append(results, visitors)
+	pages, err := analyzer.Pages(filter)
+	assert.NoError(t, err)
+	results = append(results, pages)
+	entryPages, err := analyzer.EntryPages(filter)
+	assert.NoError(t, err)
+	results = append(results, entryPages)
+	exitPages, err := analyzer.ExitPages(filter)
+	assert.NoError(t, err)
+	results = append(results, exitPages)
+	events, err := analyzer.Events(filter)
+	assert.NoError(t, err)
+	results = append(results, events)
+	referrer, err := analyzer.Referrer(filter)
+	assert.NoError(t, err)
+	results = append(results, referrer)
+	languages, err := analyzer.Languages(filter)
+	assert.NoError(t, err)
+	results = append(results, languages)
+	countries, err := analyzer.Countries(filter)
+	assert.NoError(t, err)
+	results = append(results, countries)
+	browser, err := analyzer.Browser(filter)
+	assert.NoError(t, err)
+	results = append(results, browser)
+	os, err := analyzer.OS(filter)
+	assert.NoError(t, err)
+	results = append(results, os)
+	screenClass, err := analyzer.ScreenClass(filter)
+	assert.NoError(t, err)
+	results = append(results, screenClass)
+	utmSource, err := analyzer.UTMSource(filter)
+	assert.NoError(t, err)
+	results = append(results, utmSource)
+	timeOnPages, err := analyzer.AvgTimeOnPages(filter)
+	assert.NoError(t, err)
+	results = append(results, timeOnPages)
+
+	for _, result := range results {
+		out, err := json.Marshal(result)
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", string(out))
+	}
+}
+
+// lastDSTTransition returns the last day (in UTC) before yesterday that has given number of hours in given timezone.
+// The day is always less than a year ago, so that hits saved for it are not removed by the table TTL.
+func lastDSTTransition(timezone *time.Location, hours int) time.Time {
+	for day := pastDay(2); ; day = day.Add(-time.Hour * 24) {
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, timezone)
+
+		if start.AddDate(0, 0, 1).Sub(start) == time.Hour*time.Duration(hours) {
+			return day
+		}
+	}
+}
+
 func getMaxFilter() *Filter {
 	return &Filter{
 		ClientID:       42,
@@ -985,6 +3147,8 @@ func getMaxFilter() *Filter {
 		Language:       "en",
 		Country:        "en",
 		Referrer:       "ref",
+		Referrers:      []string{"ref2"},
+		Countries:      []string{"fr"},
 		OS:             OSWindows,
 		OSVersion:      "10",
 		Browser:        BrowserChrome,
@@ -1035,6 +3199,60 @@ func TestAnalyzer_Timezone(t *testing.T) {
 	assert.Equal(t, 1, hours[9].Visitors)
 }
 
+func TestAnalyzer_TimezoneDST(t *testing.T) {
+	cleanupDB()
+	timezone, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	springForward := lastDSTTransition(timezone, 23) // clocks jump from 02:00 to 03:00 CET
+	fallBack := lastDSTTransition(timezone, 25)      // clocks jump from 03:00 back to 02:00 CEST
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{Fingerprint: "fp1", Time: springForward.Add(-time.Minute * 30), Path: "/"},             // 00:30 CET
+		{Fingerprint: "fp2", Time: springForward.Add(time.Minute * 30), Path: "/"},              // 01:30 CET
+		{Fingerprint: "fp3", Time: springForward.Add(time.Hour + time.Minute*30), Path: "/"},    // 03:30 CEST
+		{Fingerprint: "fp4", Time: springForward.Add(time.Hour*21 + time.Minute*30), Path: "/"}, // 23:30 CEST
+		{Fingerprint: "fp5", Time: springForward.Add(time.Hour*22 + time.Minute*30), Path: "/"}, // 00:30 CEST the next day
+		{Fingerprint: "fp6", Time: fallBack.Add(-time.Hour*1 - time.Minute*30), Path: "/"},      // 00:30 CEST
+		{Fingerprint: "fp7", Time: fallBack.Add(time.Minute * 30), Path: "/"},                   // 02:30 CEST
+		{Fingerprint: "fp8", Time: fallBack.Add(time.Hour + time.Minute*30), Path: "/"},         // 02:30 CET
+		{Fingerprint: "fp9", Time: fallBack.Add(time.Hour*22 + time.Minute*30), Path: "/"},      // 23:30 CET
+		{Fingerprint: "fp10", Time: fallBack.Add(time.Hour*23 + time.Minute*30), Path: "/"},     // 00:30 CET the next day
+	}))
+	time.Sleep(time.Millisecond * 20)
+	analyzer := NewAnalyzer(dbClient)
+	visitors, err := analyzer.Visitors(&Filter{From: springForward.Add(-time.Hour * 24), To: springForward.Add(time.Hour * 24), Timezone: timezone})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 3)
+	assert.Equal(t, 0, visitors[0].Visitors)
+	assert.Equal(t, 4, visitors[1].Visitors)
+	assert.Equal(t, 1, visitors[2].Visitors)
+	hours, err := analyzer.VisitorHours(&Filter{Day: springForward, Timezone: timezone})
+	assert.NoError(t, err)
+	assert.Len(t, hours, 24)
+	assert.Equal(t, 1, hours[0].Visitors)
+	assert.Equal(t, 1, hours[1].Visitors)
+	assert.Equal(t, 0, hours[2].Visitors)
+	assert.Equal(t, 1, hours[3].Visitors)
+	assert.Equal(t, 1, hours[23].Visitors)
+	intervals, err := analyzer.VisitorsByInterval(&Filter{Day: springForward, Timezone: timezone}, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, intervals, 23)
+	visitors, err = analyzer.Visitors(&Filter{From: fallBack.Add(-time.Hour * 24), To: fallBack.Add(time.Hour * 24), Timezone: timezone})
+	assert.NoError(t, err)
+	assert.Len(t, visitors, 3)
+	assert.Equal(t, 0, visitors[0].Visitors)
+	assert.Equal(t, 4, visitors[1].Visitors)
+	assert.Equal(t, 1, visitors[2].Visitors)
+	hours, err = analyzer.VisitorHours(&Filter{Day: fallBack, Timezone: timezone})
+	assert.NoError(t, err)
+	assert.Len(t, hours, 24)
+	assert.Equal(t, 1, hours[0].Visitors)
+	assert.Equal(t, 2, hours[2].Visitors)
+	assert.Equal(t, 1, hours[23].Visitors)
+	intervals, err = analyzer.VisitorsByInterval(&Filter{Day: fallBack, Timezone: timezone}, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, intervals, 25)
+}
+
 func TestAnalyzer_PathPattern(t *testing.T) {
 	cleanupDB()
 	assert.NoError(t, dbClient.SaveHits([]Hit{
@@ -1061,3 +3279,34 @@ func TestAnalyzer_PathPattern(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, visitors, 1)
 }
+
+func TestAnalyzer_NotSupported(t *testing.T) {
+	client := &notSupportedClient{MockClient: NewMockClient()}
+	analyzer := NewAnalyzer(client)
+	_, err := analyzer.Pages(&Filter{IncludeAvgTimeOnPage: true})
+	assert.NoError(t, err)
+	client.selects = 0
+	_, err = analyzer.EntryPages(&Filter{IncludeAvgTimeOnPage: true})
+	assert.NoError(t, err)
+	client.selects = 0
+	_, err = analyzer.Pages(nil)
+	assert.NoError(t, err)
+	_, err = analyzer.Pages(nil)
+	assert.True(t, errors.Is(err, ErrNotSupported))
+}
+
+type notSupportedClient struct {
+	*MockClient
+	selects int
+}
+
+func (client *notSupportedClient) Select(results interface{}, query string, args ...interface{}) error {
+	client.selects++
+
+	// only the first query is supported, every query after that is not
+	if client.selects > 1 {
+		return ErrNotSupported
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package pirsch
+
+const (
+	// ChannelDirect is the channel for all sessions without a referrer.
+	ChannelDirect = "Direct"
+
+	// ChannelOther is the channel for all sessions with a referrer that doesn't match any of the referrer channels.
+	ChannelOther = "Other"
+)
+
+// defaultReferrerChannels are the referrer channels used by the Analyzer unless configured otherwise.
+var defaultReferrerChannels = map[string]string{
+	"facebook.com":         "Social",
+	"instagram.com":        "Social",
+	"linkedin.com":         "Social",
+	"lnkd.in":              "Social",
+	"pinterest.com":        "Social",
+	"reddit.com":           "Social",
+	"t.co":                 "Social",
+	"tiktok.com":           "Social",
+	"twitter.com":          "Social",
+	"xing.com":             "Social",
+	"youtube.com":          "Social",
+	"news.ycombinator.com": "Social",
+	"baidu.com":            "Search",
+	"bing.com":             "Search",
+	"duckduckgo.com":       "Search",
+	"ecosia.org":           "Search",
+	"google.com":           "Search",
+	"google.co.uk":         "Search",
+	"google.de":            "Search",
+	"qwant.com":            "Search",
+	"startpage.com":        "Search",
+	"yahoo.com":            "Search",
+	"yandex.ru":            "Search",
+}
+
+// DefaultReferrerChannels returns a copy of the default referrer channels used by the Analyzer.
+// It can be extended and passed to Analyzer.SetReferrerChannels to configure your own channels.
+func DefaultReferrerChannels() map[string]string {
+	return copyReferrerChannels(defaultReferrerChannels)
+}
+
+func copyReferrerChannels(channels map[string]string) map[string]string {
+	c := make(map[string]string, len(channels))
+
+	for hostname, channel := range channels {
+		c[hostname] = channel
+	}
+
+	return c
+}
@@ -42,6 +42,10 @@ func NewClient(connection string, logger *log.Logger) (*Client, error) {
 
 // SaveHits implements the Store interface.
 func (client *Client) SaveHits(hits []Hit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
 	tx, err := client.Beginx()
 
 	if err != nil {
@@ -51,7 +55,7 @@ func (client *Client) SaveHits(hits []Hit) error {
 	query, err := tx.Prepare(`INSERT INTO "hit" (client_id, fingerprint, time, session, previous_time_on_page_seconds,
 		user_agent, path, url, language, country_code, referrer, referrer_name, referrer_icon, os, os_version,
 		browser, browser_version, desktop, mobile, screen_width, screen_height, screen_class,
-		utm_source, utm_medium, utm_campaign, utm_content, utm_term) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
+		utm_source, utm_medium, utm_campaign, utm_content, utm_term, search_query, status_code, tablet, method, locale, ip_hash) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
 
 	if err != nil {
 		return err
@@ -84,7 +88,13 @@ func (client *Client) SaveHits(hits []Hit) error {
 			hit.UTMMedium,
 			hit.UTMCampaign,
 			hit.UTMContent,
-			hit.UTMTerm)
+			hit.UTMTerm,
+			hit.SearchQuery,
+			hit.StatusCode,
+			client.boolean(hit.Tablet),
+			hit.Method,
+			hit.Locale,
+			hit.IPHash)
 
 		if err != nil {
 			if e := tx.Rollback(); e != nil {
@@ -104,6 +114,10 @@ func (client *Client) SaveHits(hits []Hit) error {
 
 // SaveEvents implements the Store interface.
 func (client *Client) SaveEvents(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
 	tx, err := client.Beginx()
 
 	if err != nil {
@@ -114,7 +128,7 @@ func (client *Client) SaveEvents(events []Event) error {
 		user_agent, path, url, language, country_code, referrer, referrer_name, referrer_icon, os, os_version,
 		browser, browser_version, desktop, mobile, screen_width, screen_height, screen_class,
 		utm_source, utm_medium, utm_campaign, utm_content, utm_term,
-		event_name, event_duration_seconds, event_meta_keys, event_meta_values) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
+		event_name, event_duration_seconds, event_meta_keys, event_meta_values, search_query, status_code, tablet, event_value, event_has_value, method, locale, ip_hash) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
 
 	if err != nil {
 		return err
@@ -151,7 +165,15 @@ func (client *Client) SaveEvents(events []Event) error {
 			event.Name,
 			event.DurationSeconds,
 			event.MetaKeys,
-			event.MetaValues)
+			event.MetaValues,
+			event.SearchQuery,
+			event.StatusCode,
+			client.boolean(event.Tablet),
+			event.Value,
+			client.boolean(event.HasValue),
+			event.Method,
+			event.Locale,
+			event.IPHash)
 
 		if err != nil {
 			if e := tx.Rollback(); e != nil {
@@ -171,14 +193,14 @@ func (client *Client) SaveEvents(events []Event) error {
 
 // Session implements the Store interface.
 func (client *Client) Session(clientID int64, fingerprint string, maxAge time.Time) (string, time.Time, time.Time, error) {
-	query := `SELECT path, time, session FROM hit WHERE client_id = ? AND fingerprint = ? AND time > ? LIMIT 1`
+	query := `SELECT path, time, session FROM hit WHERE client_id = ? AND fingerprint = ? AND time > ? ORDER BY time DESC LIMIT 1`
 	data := struct {
 		Path    string
 		Time    time.Time
 		Session time.Time
 	}{}
 
-	if err := client.DB.Get(&data, query, clientID, fingerprint, maxAge); err != nil && err != sql.ErrNoRows {
+	if err := client.DB.Get(&data, query, clientID, fingerprint, maxAge.UTC().Truncate(time.Second)); err != nil && err != sql.ErrNoRows {
 		client.logger.Printf("error reading session timestamp: %s", err)
 		return "", time.Time{}, time.Time{}, err
 	}
@@ -186,6 +208,53 @@ func (client *Client) Session(clientID int64, fingerprint string, maxAge time.Ti
 	return data.Path, data.Time, data.Session, nil
 }
 
+// SaveExclusionWindow implements the ExclusionWindowStore interface.
+func (client *Client) SaveExclusionWindow(clientID int64, window TimeWindow) error {
+	tx, err := client.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	query, err := tx.Prepare(`INSERT INTO "exclusion_window" (client_id, "from", "to") VALUES (?,?,?)`)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := query.Exec(clientID, window.From.UTC(), window.To.UTC()); err != nil {
+		if e := tx.Rollback(); e != nil {
+			client.logger.Printf("error rolling back transaction to save exclusion window: %s", err)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExclusionWindows implements the ExclusionWindowStore interface.
+func (client *Client) DeleteExclusionWindows(clientID int64) error {
+	if _, err := client.Exec(`ALTER TABLE "exclusion_window" DELETE WHERE client_id = ?`, clientID); err != nil {
+		client.logger.Printf("error deleting exclusion windows: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// ExclusionWindows implements the ExclusionWindowStore interface.
+func (client *Client) ExclusionWindows(clientID int64) ([]TimeWindow, error) {
+	windows := make([]TimeWindow, 0)
+
+	if err := client.DB.Select(&windows, `SELECT "from", "to" FROM "exclusion_window" WHERE client_id = ? ORDER BY "from"`, clientID); err != nil {
+		client.logger.Printf("error reading exclusion windows: %s", err)
+		return nil, err
+	}
+
+	return windows, nil
+}
+
 // Count implements the Store interface.
 func (client *Client) Count(query string, args ...interface{}) (int, error) {
 	count := 0
@@ -218,6 +287,31 @@ func (client *Client) Select(results interface{}, query string, args ...interfac
 	return nil
 }
 
+// SelectRows implements the RowStore interface.
+func (client *Client) SelectRows(fn func(func(interface{}) error) error, query string, args ...interface{}) error {
+	rows, err := client.DB.Queryx(query, args...)
+
+	if err != nil {
+		client.logger.Printf("error selecting results: %s", err)
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows.StructScan); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		client.logger.Printf("error selecting results: %s", err)
+		return err
+	}
+
+	return nil
+}
+
 func (client *Client) boolean(b bool) int8 {
 	if b {
 		return 1
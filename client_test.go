@@ -92,6 +92,43 @@ func TestClient_SaveEvent(t *testing.T) {
 	}))
 }
 
+func TestClient_SaveHitsEmptyAndSingle(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveHits(nil))
+	assert.NoError(t, dbClient.SaveHits([]Hit{}))
+	assert.NoError(t, dbClient.SaveHits([]Hit{
+		{
+			Fingerprint: "fp",
+			Time:        time.Now().UTC(),
+			Path:        "/path",
+		},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	count, err := dbClient.Count(`SELECT count(*) FROM hit`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestClient_SaveEventsEmptyAndSingle(t *testing.T) {
+	cleanupDB()
+	assert.NoError(t, dbClient.SaveEvents(nil))
+	assert.NoError(t, dbClient.SaveEvents([]Event{}))
+	assert.NoError(t, dbClient.SaveEvents([]Event{
+		{
+			Hit: Hit{
+				Fingerprint: "fp",
+				Time:        time.Now().UTC(),
+				Path:        "/path",
+			},
+			Name: "event",
+		},
+	}))
+	time.Sleep(time.Millisecond * 20)
+	count, err := dbClient.Count(`SELECT count(*) FROM event`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
 func TestClient_Session(t *testing.T) {
 	cleanupDB()
 	fp := "session_fp"
@@ -1,5 +1,25 @@
 package pirsch
 
+import "sort"
+
+const (
+	// EventOutbound is the event name used to track clicks on outbound links (see Tracker.TrackOutbound).
+	EventOutbound = "Outbound Link Click"
+
+	// EventDownload is the event name used to track file downloads (see Tracker.TrackDownload).
+	EventDownload = "File Download"
+
+	// EventHeartbeat is the event name used to track the time a visitor engaged with a page (see Tracker.Heartbeat).
+	// Heartbeats are left out of Analyzer.Events and Analyzer.CountEvents unless they are selected by name.
+	EventHeartbeat = "Heartbeat"
+
+	// EventMetaURL is the event meta key used to store the target URL of an outbound link click.
+	EventMetaURL = "url"
+
+	// EventMetaFile is the event meta key used to store the file of a download.
+	EventMetaFile = "file"
+)
+
 // EventOptions are the options to save a new event.
 // The name is required. All other fields are optional.
 type EventOptions struct {
@@ -9,6 +29,10 @@ type EventOptions struct {
 	// Duration is an optional duration that is used to calculate an average time on the dashboard.
 	Duration int
 
+	// Value is an optional numeric value, like the total of an order, that can be summed up and averaged (see Analyzer.EventValue).
+	// Events without a value are not included in the average.
+	Value *float64
+
 	// Meta are optional fields used to break down the events that were send for a name.
 	Meta map[string]string
 }
@@ -23,3 +47,78 @@ func (options *EventOptions) getMetaData() ([]string, []string) {
 
 	return keys, values
 }
+
+// EventMetaLimits limits the meta data stored with events to protect the storage and breakdowns from unbounded cardinality.
+// Meta data exceeding the limits is truncated or removed, the event itself is always stored.
+type EventMetaLimits struct {
+	// MaxKeys is the maximum number of meta keys per event.
+	// The keys are sorted alphabetically and all keys after the limit are removed. Zero or less disables the limit.
+	MaxKeys int
+
+	// MaxKeyLength is the maximum length of a meta key in bytes. Longer keys are truncated.
+	// Zero or less disables the limit.
+	MaxKeyLength int
+
+	// MaxValueLength is the maximum length of a meta value in bytes. Longer values are truncated.
+	// Zero or less disables the limit.
+	MaxValueLength int
+
+	// AllowedKeys maps event names to the meta keys allowed for them. All other keys are removed.
+	// Events that are not listed accept all keys.
+	AllowedKeys map[string][]string
+
+	// OnLimit is called with the event name and the meta keys that were removed or truncated, if any.
+	OnLimit func(eventName string, removed, truncated []string)
+}
+
+// apply returns a copy of the meta data with the limits applied.
+func (limits *EventMetaLimits) apply(eventName string, meta map[string]string) map[string]string {
+	if limits == nil || len(meta) == 0 {
+		return meta
+	}
+
+	keys := make([]string, 0, len(meta))
+
+	for k := range meta {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	allowed, allowList := limits.AllowedKeys[eventName]
+	result := make(map[string]string, len(meta))
+	var removed, truncated []string
+
+	for _, k := range keys {
+		if allowList && !containsString(allowed, k) {
+			removed = append(removed, k)
+			continue
+		}
+
+		key, value := k, meta[k]
+
+		if limits.MaxKeyLength > 0 {
+			key = shortenString(key, limits.MaxKeyLength)
+		}
+
+		if limits.MaxValueLength > 0 {
+			value = shortenString(value, limits.MaxValueLength)
+		}
+
+		if _, found := result[key]; found || (limits.MaxKeys > 0 && len(result) >= limits.MaxKeys) {
+			removed = append(removed, k)
+			continue
+		}
+
+		if key != k || value != meta[k] {
+			truncated = append(truncated, k)
+		}
+
+		result[key] = value
+	}
+
+	if limits.OnLimit != nil && (len(removed) > 0 || len(truncated) > 0) {
+		limits.OnLimit(eventName, removed, truncated)
+	}
+
+	return result
+}
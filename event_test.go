@@ -20,3 +20,34 @@ func TestEventOptions_getMetaData(t *testing.T) {
 	assert.Contains(t, v, "value")
 	assert.Contains(t, v, "world")
 }
+
+func TestEventMetaLimits_apply(t *testing.T) {
+	var limits *EventMetaLimits
+	meta := map[string]string{"key": "value"}
+	assert.Equal(t, meta, limits.apply("event", meta))
+	var removed, truncated []string
+	limits = &EventMetaLimits{
+		MaxKeys:        2,
+		MaxKeyLength:   5,
+		MaxValueLength: 3,
+		AllowedKeys: map[string][]string{
+			"signup": {"plan"},
+		},
+		OnLimit: func(eventName string, r, t []string) {
+			removed, truncated = r, t
+		},
+	}
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, limits.apply("event", map[string]string{"c": "3", "b": "2", "a": "1"}))
+	assert.Equal(t, []string{"c"}, removed)
+	assert.Empty(t, truncated)
+	assert.Equal(t, map[string]string{"long_": "val"}, limits.apply("event", map[string]string{"long_key": "value"}))
+	assert.Empty(t, removed)
+	assert.Equal(t, []string{"long_key"}, truncated)
+	assert.Equal(t, map[string]string{"plan": "pro"}, limits.apply("signup", map[string]string{"plan": "pro", "email": "foo@bar.com"}))
+	assert.Equal(t, []string{"email"}, removed)
+	assert.Empty(t, truncated)
+	removed, truncated = nil, nil
+	assert.Equal(t, map[string]string{"key": "val"}, limits.apply("event", map[string]string{"key": "val"}))
+	assert.Nil(t, removed)
+	assert.Nil(t, truncated)
+}
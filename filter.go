@@ -2,6 +2,9 @@ package pirsch
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -10,13 +13,36 @@ const (
 	// PlatformDesktop filters for everything on desktops.
 	PlatformDesktop = "desktop"
 
-	// PlatformMobile filters for everything on mobile devices.
+	// PlatformMobile filters for everything on mobile devices, excluding tablets.
 	PlatformMobile = "mobile"
 
+	// PlatformTablet filters for everything on tablets.
+	PlatformTablet = "tablet"
+
 	// PlatformUnknown filters for everything where the platform is unspecified.
 	PlatformUnknown = "unknown"
+
+	// SnapWeek extends the selected period to full weeks, starting on Monday.
+	SnapWeek = "week"
+
+	// SnapMonth extends the selected period to full months.
+	SnapMonth = "month"
+
+	// SnapQuarter extends the selected period to full quarters.
+	SnapQuarter = "quarter"
+
+	// DefaultPeriodDays is the number of days (including today) selected if a Filter has no period (From, To, Day, and Start).
+	// Set Filter.AllTime to select all data instead.
+	DefaultPeriodDays = 30
+
+	// MaxLimit is the maximum number of results that can be requested using Filter.Limit.
+	MaxLimit = 10_000
+
+	filterDateFormat = "2006-01-02"
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // NullClient is a placeholder for no client (0).
 var NullClient = int64(0)
 
@@ -26,21 +52,43 @@ type Filter struct {
 	ClientID int64
 
 	// Timezone sets the timezone used to interpret dates and times.
+	// Days and hours are calculated in this timezone, so days on which daylight saving time starts or ends have 23 or 25 hours.
 	// It will be set to UTC by default.
 	Timezone *time.Location
 
 	// From is the start date of the selected period.
+	// If From, To, Day, and Start are all empty, the last DefaultPeriodDays days are selected, unless AllTime is set.
 	From time.Time
 
 	// To is the end date of the selected period.
 	To time.Time
 
+	// AllTime selects all data if no period is set, instead of the last DefaultPeriodDays days.
+	// This can be expensive for large installations, as it scans all data of a client.
+	AllTime bool
+
+	// IncludeToday overrides whether today is part of the selected period or not.
+	// By default, today is included if To is set to today (or left empty).
+	// Set it to true to extend the period to today, or false to end the period yesterday.
+	// Today's data changes while the day progresses, so exclude it to get stable results for reports.
+	IncludeToday *bool
+
+	// Snap extends the selected period to the enclosing week, month, or quarter (SnapWeek, SnapMonth, SnapQuarter).
+	// From is moved to the first and To to the last day of the period, so that there are no partial weeks, months, or quarters.
+	// From and To are dates in the filter timezone, so the boundaries are calendar days in that timezone.
+	// To is still limited to today, as there is no data for the future. Leave it empty to keep the period as it is.
+	Snap string
+
 	// Day is an exact match for the result set ("on this day").
 	Day time.Time
 
 	// Start is the start date and time of the selected period.
 	Start time.Time
 
+	// ExcludedWindows are periods of time whose data is ignored, like an outage.
+	// Use Analyzer.WithExcludedWindows to set the windows stored for the ClientID.
+	ExcludedWindows []TimeWindow
+
 	// Path filters for the path.
 	// Note that if this and PathPattern are both set, Path will be preferred.
 	Path string
@@ -58,11 +106,21 @@ type Filter struct {
 	Language string
 
 	// Country filters for the ISO country code.
+	// Country codes are compared case-insensitively.
 	Country string
 
+	// Countries filters for a list of ISO country codes, like all countries of a region.
+	// Results matching either Country or one of the Countries will be included.
+	// Empty entries are ignored and codes are compared case-insensitively.
+	Countries []string
+
 	// Referrer filters for the referrer.
 	Referrer string
 
+	// Referrers filters for a list of referrers.
+	// Results matching either Referrer or one of the Referrers will be included. Empty entries are ignored.
+	Referrers []string
+
 	// OS filters for the operating system.
 	OS string
 
@@ -75,7 +133,7 @@ type Filter struct {
 	// BrowserVersion filters for the browser version.
 	BrowserVersion string
 
-	// Platform filters for the platform (desktop, mobile, unknown).
+	// Platform filters for the platform (desktop, mobile, tablet, unknown).
 	Platform string
 
 	// ScreenClass filters for the screen class.
@@ -96,14 +154,29 @@ type Filter struct {
 	// UTMTerm filters for the utm_term query parameter.
 	UTMTerm string
 
+	// StatusCode filters for an HTTP status code (like 404).
+	StatusCode int
+
+	// Method filters for an HTTP method (like POST), ignoring the case.
+	Method string
+
+	// Search filters for a search term contained in the path or referrer, ignoring the case.
+	// This can be used for a "type to filter" search box. Wildcards in the term are matched literally.
+	Search string
+
 	// EventName filters for an event by its name.
 	EventName string
 
-	// EventMetaKey filters for an event meta key.
-	// This must be used together with an EventName.
+	// EventMetaKey filters for events having an event meta key.
+	// This must be used together with an EventName, except for Analyzer.CountEvents.
 	EventMetaKey string
 
-	// Limit limits the number of results. Less or equal to zero means no limit.
+	// EventMeta filters for events having all given meta key-value pairs (like plan=pro).
+	// This must be used together with an EventName.
+	EventMeta map[string]string
+
+	// Limit limits the number of results for breakdowns (pages, referrers, languages, ...) to the top N by visitors.
+	// Less or equal to zero means no limit. Values above MaxLimit will be set to MaxLimit.
 	Limit int
 
 	// IncludeAvgTimeOnPage indicates whether Analyzer.Pages should contain the average time on page or not.
@@ -113,6 +186,27 @@ type Filter struct {
 	// Visitors who are idle artificially increase the average time spent on a page, this option can be used to limit the effect.
 	// Set to 0 to disable this option (default).
 	MaxTimeOnPageSeconds int
+
+	// SessionBounces counts bounces per session instead of per visitor for Analyzer.Visitors, Analyzer.TotalVisitors, and Analyzer.Growth.
+	// By default, a visitor is counted as a bounce if they viewed a single page on a day.
+	// If set, each session in which a single distinct path was viewed is counted as a bounce and the bounce rate is relative to the number of sessions.
+	// The bounces then add up to Analyzer.CountBounces.
+	SessionBounces bool
+
+	// DistinctPerDay only keeps the first hit or event of each visitor per day matching the filter, before the results are grouped.
+	// Visitors are then counted at most once a day in breakdowns, page views, and events, which changes the number of views and events.
+	// Breakdowns by path only include the first page a visitor viewed on a day, for example.
+	// The time on page is calculated from all hits, as it depends on the following page view.
+	DistinctPerDay bool
+
+	// Compare indicates whether Analyzer.TotalVisitors should attach the statistics for the previous period and the growth.
+	// The previous period immediately precedes the selected period and has the same length.
+	// A month-to-date period (starting on the first day of a month and ending today) is compared to the same span of the previous month,
+	// and a whole month to the whole previous month.
+	Compare bool
+
+	// clientIDs are queried instead of the ClientID if set (see Analyzer.VisitorsForClients).
+	clientIDs []int64
 }
 
 // NewFilter creates a new filter for given client ID.
@@ -122,6 +216,137 @@ func NewFilter(clientID int64) *Filter {
 	}
 }
 
+// ParseFilterQuery returns a new filter for given client ID from URL query parameters.
+// This is the counterpart to Filter.EncodeQuery. Invalid parameters are ignored and left empty.
+func ParseFilterQuery(clientID int64, values url.Values) *Filter {
+	filter := NewFilter(clientID)
+
+	for _, param := range filter.queryParams() {
+		*param.value = strings.TrimSpace(values.Get(param.key))
+	}
+
+	for _, param := range filter.queryDateParams() {
+		if date, err := time.Parse(filterDateFormat, values.Get(param.key)); err == nil {
+			*param.value = date
+		}
+	}
+
+	for _, param := range filter.queryIntParams() {
+		if i, err := strconv.Atoi(values.Get(param.key)); err == nil {
+			*param.value = i
+		}
+	}
+
+	for _, param := range filter.queryBoolParams() {
+		if b, err := strconv.ParseBool(values.Get(param.key)); err == nil {
+			*param.value = b
+		}
+	}
+
+	if timezone := values.Get("tz"); timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			filter.Timezone = location
+		}
+	}
+
+	if includeToday, err := strconv.ParseBool(values.Get("include_today")); err == nil {
+		filter.IncludeToday = &includeToday
+	}
+
+	if start, err := time.Parse(time.RFC3339, values.Get("start")); err == nil {
+		filter.Start = start.UTC()
+	}
+
+	for _, param := range filter.queryListParams() {
+		for _, value := range values[param.key] {
+			if value = strings.TrimSpace(value); value != "" {
+				*param.value = append(*param.value, value)
+			}
+		}
+	}
+
+	for _, value := range values["event_meta"] {
+		parts := strings.SplitN(value, ":", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		// the key is escaped, so that it can contain colons
+		key, err := url.QueryUnescape(strings.TrimSpace(parts[0]))
+
+		if err != nil || key == "" {
+			continue
+		}
+
+		if filter.EventMeta == nil {
+			filter.EventMeta = make(map[string]string)
+		}
+
+		filter.EventMeta[key] = strings.TrimSpace(parts[1])
+	}
+
+	return filter
+}
+
+// EncodeQuery returns the filter as URL query parameters. Empty fields and the client ID are left out.
+// Dates are formatted as YYYY-MM-DD, the start time is formatted as RFC3339, and the timezone is stored by name.
+// Event meta data is stored as escaped key and value separated by a colon.
+// Use ParseFilterQuery to read the filter back.
+func (filter *Filter) EncodeQuery() url.Values {
+	values := make(url.Values)
+
+	for _, param := range filter.queryParams() {
+		if *param.value != "" {
+			values.Set(param.key, *param.value)
+		}
+	}
+
+	for _, param := range filter.queryDateParams() {
+		if !param.value.IsZero() {
+			values.Set(param.key, param.value.Format(filterDateFormat))
+		}
+	}
+
+	for _, param := range filter.queryIntParams() {
+		if *param.value != 0 {
+			values.Set(param.key, strconv.Itoa(*param.value))
+		}
+	}
+
+	for _, param := range filter.queryBoolParams() {
+		if *param.value {
+			values.Set(param.key, "true")
+		}
+	}
+
+	if filter.Timezone != nil && filter.Timezone != time.UTC {
+		values.Set("tz", filter.Timezone.String())
+	}
+
+	if filter.IncludeToday != nil {
+		values.Set("include_today", strconv.FormatBool(*filter.IncludeToday))
+	}
+
+	if !filter.Start.IsZero() {
+		values.Set("start", filter.Start.UTC().Format(time.RFC3339))
+	}
+
+	for _, param := range filter.queryListParams() {
+		for _, value := range *param.value {
+			if value != "" {
+				values.Add(param.key, value)
+			}
+		}
+	}
+
+	for _, key := range filter.eventMetaKeys() {
+		values.Add("event_meta", url.QueryEscape(key)+":"+filter.EventMeta[key])
+	}
+
+	return values
+}
+
 func (filter *Filter) validate() {
 	if filter.Timezone == nil {
 		filter.Timezone = time.UTC
@@ -149,23 +374,55 @@ func (filter *Filter) validate() {
 		filter.Start = time.Date(filter.Start.Year(), filter.Start.Month(), filter.Start.Day(), filter.Start.Hour(), filter.Start.Minute(), filter.Start.Second(), 0, time.UTC)
 	}
 
+	today := filter.today()
+
+	if !filter.AllTime && filter.From.IsZero() && filter.To.IsZero() && filter.Day.IsZero() && filter.Start.IsZero() {
+		filter.From = today.AddDate(0, 0, -(DefaultPeriodDays - 1))
+		filter.To = today
+	}
+
 	if !filter.To.IsZero() && filter.From.After(filter.To) {
 		filter.From, filter.To = filter.To, filter.From
 	}
 
-	now := time.Now().UTC()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if filter.Snap != "" {
+		filter.snapPeriod()
+	}
 
 	if !filter.To.IsZero() && filter.To.After(today) {
 		filter.To = today
 	}
 
+	if filter.IncludeToday != nil {
+		if *filter.IncludeToday {
+			if !filter.To.IsZero() {
+				filter.To = today
+			}
+		} else if filter.To.IsZero() || filter.To.Equal(today) {
+			filter.To = today.Add(-time.Hour * 24)
+		}
+	}
+
 	if filter.Path != "" && filter.PathPattern != "" {
 		filter.PathPattern = ""
 	}
 
+	filter.Country = strings.ToLower(filter.Country)
+
+	if len(filter.Countries) > 0 {
+		countries := make([]string, 0, len(filter.Countries))
+
+		for _, country := range filter.Countries {
+			countries = append(countries, strings.ToLower(country))
+		}
+
+		filter.Countries = countries
+	}
+
 	if filter.Limit < 0 {
 		filter.Limit = 0
+	} else if filter.Limit > MaxLimit {
+		filter.Limit = MaxLimit
 	}
 }
 
@@ -178,11 +435,10 @@ func (filter *Filter) table() string {
 }
 
 func (filter *Filter) queryTime() ([]interface{}, string) {
-	args := make([]interface{}, 0, 5)
-	args = append(args, filter.ClientID)
+	args, clientQuery := filter.queryClient()
 	timezone := filter.Timezone.String()
 	var sqlQuery strings.Builder
-	sqlQuery.WriteString("client_id = ? ")
+	sqlQuery.WriteString(clientQuery)
 
 	if !filter.From.IsZero() {
 		args = append(args, filter.From)
@@ -204,16 +460,50 @@ func (filter *Filter) queryTime() ([]interface{}, string) {
 		sqlQuery.WriteString(fmt.Sprintf("AND toDateTime(time, '%s') >= toDateTime(?, '%s') ", timezone, timezone))
 	}
 
+	for _, window := range filter.ExcludedWindows {
+		args = append(args, window.From.UTC(), window.To.UTC())
+		sqlQuery.WriteString("AND NOT (time >= toDateTime(?, 'UTC') AND time < toDateTime(?, 'UTC')) ")
+	}
+
 	return args, sqlQuery.String()
 }
 
+// queryClient returns the condition for the ClientID, or the list of clients if set.
+func (filter *Filter) queryClient() ([]interface{}, string) {
+	if len(filter.clientIDs) == 0 {
+		return []interface{}{filter.ClientID}, "client_id = ? "
+	}
+
+	args := make([]interface{}, 0, len(filter.clientIDs))
+
+	for _, clientID := range filter.clientIDs {
+		args = append(args, clientID)
+	}
+
+	return args, fmt.Sprintf("client_id IN (%s) ", strings.TrimSuffix(strings.Repeat("?,", len(filter.clientIDs)), ","))
+}
+
+// excludedDay returns true if given day (in the filter timezone) is fully covered by one of the ExcludedWindows.
+func (filter *Filter) excludedDay(day time.Time) bool {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, filter.Timezone)
+	end := start.AddDate(0, 0, 1)
+
+	for _, window := range filter.ExcludedWindows {
+		if !window.From.After(start) && !window.To.Before(end) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (filter *Filter) queryFields() ([]interface{}, string) {
 	args := make([]interface{}, 0, 16)
 	fields := make([]string, 0, 16)
 	filter.appendQuery(&fields, &args, "path", filter.Path)
 	filter.appendQuery(&fields, &args, "language", filter.Language)
-	filter.appendQuery(&fields, &args, "country_code", filter.Country)
-	filter.appendQuery(&fields, &args, "referrer", filter.Referrer)
+	filter.appendQueryList(&fields, &args, "lower(country_code)", append([]string{filter.Country}, filter.Countries...))
+	filter.appendQueryList(&fields, &args, "referrer", append([]string{filter.Referrer}, filter.Referrers...))
 	filter.appendQuery(&fields, &args, "os", filter.OS)
 	filter.appendQuery(&fields, &args, "os_version", filter.OSVersion)
 	filter.appendQuery(&fields, &args, "browser", filter.Browser)
@@ -230,20 +520,75 @@ func (filter *Filter) queryFields() ([]interface{}, string) {
 		if filter.Platform == PlatformDesktop {
 			fields = append(fields, "desktop = 1 ")
 		} else if filter.Platform == PlatformMobile {
-			fields = append(fields, "mobile = 1 ")
+			fields = append(fields, "mobile = 1 AND tablet = 0 ")
+		} else if filter.Platform == PlatformTablet {
+			fields = append(fields, "tablet = 1 ")
 		} else {
 			fields = append(fields, "desktop = 0 AND mobile = 0 ")
 		}
 	}
 
+	if filter.StatusCode > 0 {
+		args = append(args, filter.StatusCode)
+		fields = append(fields, "status_code = ? ")
+	}
+
+	filter.appendQuery(&fields, &args, "method", strings.ToUpper(filter.Method))
+
+	if filter.Search != "" {
+		search := "%" + escapeLike(filter.Search) + "%"
+		args = append(args, search, search)
+		fields = append(fields, `("path" ILIKE ? OR referrer ILIKE ?) `)
+	}
+
+	if filter.EventName != "" {
+		for _, key := range filter.eventMetaKeys() {
+			args = append(args, key, filter.EventMeta[key])
+			fields = append(fields, "arrayExists((k, v) -> k = ? AND v = ?, event_meta_keys, event_meta_values) ")
+		}
+	}
+
 	if filter.PathPattern != "" {
 		args = append(args, filter.PathPattern)
-		fields = append(fields, `match("path", ?) = 1`)
+		fields = append(fields, `match("path", ?) = 1 `)
 	}
 
 	return args, strings.Join(fields, "AND ")
 }
 
+// returns the keys of the EventMeta in alphabetical order, so that the query is stable
+func (filter *Filter) eventMetaKeys() []string {
+	keys := make([]string, 0, len(filter.EventMeta))
+
+	for key := range filter.EventMeta {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// queryEvents returns the conditions only applicable to events.
+// Heartbeats are left out when querying all events and the EventMetaKey is required if set.
+func (filter *Filter) queryEvents() ([]interface{}, string) {
+	args := make([]interface{}, 0, 2)
+	var sqlQuery strings.Builder
+
+	if filter.EventName == "" {
+		args = append(args, EventHeartbeat)
+		sqlQuery.WriteString("AND event_name != ? ")
+	}
+
+	if filter.EventMetaKey != "" {
+		args = append(args, filter.EventMetaKey)
+		sqlQuery.WriteString("AND has(event_meta_keys, ?) ")
+	}
+
+	return args, sqlQuery.String()
+}
+
 func (filter *Filter) withFill() ([]interface{}, string) {
 	if !filter.From.IsZero() && !filter.To.IsZero() {
 		timezone := filter.Timezone.String()
@@ -253,6 +598,153 @@ func (filter *Filter) withFill() ([]interface{}, string) {
 	return nil, ""
 }
 
+func (filter *Filter) days() []time.Time {
+	if filter.From.IsZero() || filter.To.IsZero() {
+		return nil
+	}
+
+	days := make([]time.Time, 0, int(filter.To.Sub(filter.From).Hours()/24)+1)
+
+	for day := filter.From; !day.After(filter.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	return days
+}
+
+// today returns the current date in the filter timezone, as dates are compared in that timezone.
+func (filter *Filter) today() time.Time {
+	timezone := filter.Timezone
+
+	if timezone == nil {
+		timezone = time.UTC
+	}
+
+	now := time.Now().In(timezone)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// isMonthToDateOrMonth returns true if the period starts on the first day of a month and either ends today or covers the whole month.
+func (filter *Filter) isMonthToDateOrMonth() bool {
+	if filter.From.Day() != 1 || filter.From.Year() != filter.To.Year() || filter.From.Month() != filter.To.Month() {
+		return false
+	}
+
+	return filter.To.Equal(filter.today()) || filter.To.AddDate(0, 0, 1).Day() == 1
+}
+
+func (filter *Filter) previousPeriod() *Filter {
+	previous := *filter
+	previous.Compare = false
+
+	if !filter.Day.IsZero() {
+		previous.Day = filter.Day.AddDate(0, 0, -1)
+	} else if filter.isMonthToDateOrMonth() {
+		previous.From = filter.From.AddDate(0, -1, 0)
+		endOfPreviousMonth := filter.From.AddDate(0, 0, -1)
+
+		if filter.To.AddDate(0, 0, 1).Day() == 1 {
+			previous.To = endOfPreviousMonth
+		} else {
+			previous.To = previous.From.AddDate(0, 0, filter.To.Day()-1)
+
+			if previous.To.After(endOfPreviousMonth) {
+				previous.To = endOfPreviousMonth
+			}
+		}
+	} else {
+		days := filter.To.Sub(filter.From)
+		previous.To = filter.From.AddDate(0, 0, -1)
+		previous.From = previous.To.Add(-days)
+	}
+
+	return &previous
+}
+
+type filterQueryParam struct {
+	key   string
+	value *string
+}
+
+type filterQueryDateParam struct {
+	key   string
+	value *time.Time
+}
+
+type filterQueryIntParam struct {
+	key   string
+	value *int
+}
+
+type filterQueryListParam struct {
+	key   string
+	value *[]string
+}
+
+type filterQueryBoolParam struct {
+	key   string
+	value *bool
+}
+
+func (filter *Filter) queryParams() []filterQueryParam {
+	return []filterQueryParam{
+		{"path", &filter.Path},
+		{"path_pattern", &filter.PathPattern},
+		{"language", &filter.Language},
+		{"country", &filter.Country},
+		{"referrer", &filter.Referrer},
+		{"os", &filter.OS},
+		{"os_version", &filter.OSVersion},
+		{"browser", &filter.Browser},
+		{"browser_version", &filter.BrowserVersion},
+		{"platform", &filter.Platform},
+		{"screen_class", &filter.ScreenClass},
+		{"utm_source", &filter.UTMSource},
+		{"utm_medium", &filter.UTMMedium},
+		{"utm_campaign", &filter.UTMCampaign},
+		{"utm_content", &filter.UTMContent},
+		{"utm_term", &filter.UTMTerm},
+		{"method", &filter.Method},
+		{"search", &filter.Search},
+		{"snap", &filter.Snap},
+		{"event", &filter.EventName},
+		{"event_meta_key", &filter.EventMetaKey},
+	}
+}
+
+func (filter *Filter) queryDateParams() []filterQueryDateParam {
+	return []filterQueryDateParam{
+		{"from", &filter.From},
+		{"to", &filter.To},
+		{"day", &filter.Day},
+	}
+}
+
+func (filter *Filter) queryIntParams() []filterQueryIntParam {
+	return []filterQueryIntParam{
+		{"status_code", &filter.StatusCode},
+		{"limit", &filter.Limit},
+		{"max_time_on_page_seconds", &filter.MaxTimeOnPageSeconds},
+	}
+}
+
+func (filter *Filter) queryListParams() []filterQueryListParam {
+	return []filterQueryListParam{
+		{"referrers", &filter.Referrers},
+		{"countries", &filter.Countries},
+	}
+}
+
+func (filter *Filter) queryBoolParams() []filterQueryBoolParam {
+	return []filterQueryBoolParam{
+		{"include_avg_time_on_page", &filter.IncludeAvgTimeOnPage},
+		{"session_bounces", &filter.SessionBounces},
+		{"distinct_per_day", &filter.DistinctPerDay},
+		{"all_time", &filter.AllTime},
+		{"compare", &filter.Compare},
+	}
+}
+
 func (filter *Filter) withLimit() string {
 	if filter.Limit > 0 {
 		return fmt.Sprintf("LIMIT %d ", filter.Limit)
@@ -262,6 +754,12 @@ func (filter *Filter) withLimit() string {
 }
 
 func (filter *Filter) query() ([]interface{}, string) {
+	return filter.queryTable(filter.table())
+}
+
+// queryTable returns the same as query for given table, which is required to query all events, as the EventName is not set in that case.
+// If DistinctPerDay is set, only the first row matching the filter is selected for each visitor and day.
+func (filter *Filter) queryTable(table string) ([]interface{}, string) {
 	args, query := filter.queryTime()
 	fieldArgs, queryFields := filter.queryFields()
 	args = append(args, fieldArgs...)
@@ -270,6 +768,24 @@ func (filter *Filter) query() ([]interface{}, string) {
 		query += "AND " + queryFields
 	}
 
+	if table == "event" {
+		eventArgs, eventQuery := filter.queryEvents()
+		args = append(args, eventArgs...)
+		query += eventQuery
+	}
+
+	if filter.DistinctPerDay {
+		timezone := filter.Timezone.String()
+		args = append(args, args...)
+		query = strings.TrimSpace(query)
+		query = fmt.Sprintf(`%s AND (client_id, fingerprint, time) IN (
+			SELECT client_id, fingerprint, min(time)
+			FROM %s
+			WHERE %s
+			GROUP BY client_id, fingerprint, toDate(time, '%s')
+		) `, query, table, query, timezone)
+	}
+
 	return args, query
 }
 
@@ -280,6 +796,47 @@ func (filter *Filter) appendQuery(fields *[]string, args *[]interface{}, field,
 	}
 }
 
+func (filter *Filter) appendQueryList(fields *[]string, args *[]interface{}, field string, values []string) {
+	list := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if value != "" && !containsString(list, value) {
+			list = append(list, value)
+			*args = append(*args, value)
+		}
+	}
+
+	if len(list) == 1 {
+		*fields = append(*fields, fmt.Sprintf("%s = ? ", field))
+	} else if len(list) > 1 {
+		*fields = append(*fields, fmt.Sprintf("%s IN (%s) ", field, strings.TrimSuffix(strings.Repeat("?,", len(list)), ",")))
+	}
+}
+
+func (filter *Filter) snapPeriod() {
+	if !filter.From.IsZero() {
+		switch filter.Snap {
+		case SnapWeek:
+			filter.From = filter.From.AddDate(0, 0, -(int(filter.From.Weekday())+6)%7)
+		case SnapMonth:
+			filter.From = time.Date(filter.From.Year(), filter.From.Month(), 1, 0, 0, 0, 0, time.UTC)
+		case SnapQuarter:
+			filter.From = time.Date(filter.From.Year(), (filter.From.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+
+	if !filter.To.IsZero() {
+		switch filter.Snap {
+		case SnapWeek:
+			filter.To = filter.To.AddDate(0, 0, (7-int(filter.To.Weekday()))%7)
+		case SnapMonth:
+			filter.To = time.Date(filter.To.Year(), filter.To.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		case SnapQuarter:
+			filter.To = time.Date(filter.To.Year(), (filter.To.Month()-1)/3*3+4, 0, 0, 0, 0, 0, time.UTC)
+		}
+	}
+}
+
 func (filter *Filter) toDate(date time.Time) time.Time {
 	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
 }
@@ -291,3 +848,7 @@ func (filter *Filter) boolean(b bool) int8 {
 
 	return 0
 }
+
+func escapeLike(term string) string {
+	return likeEscaper.Replace(term)
+}
@@ -2,6 +2,7 @@ package pirsch
 
 import (
 	"github.com/stretchr/testify/assert"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -12,8 +13,28 @@ func TestFilter_Validate(t *testing.T) {
 	assert.NotNil(t, filter)
 	assert.NotNil(t, filter.Timezone)
 	assert.Equal(t, time.UTC, filter.Timezone)
+	assert.Equal(t, pastDay(DefaultPeriodDays-1), filter.From)
+	assert.Equal(t, Today(), filter.To)
+	filter = &Filter{AllTime: true}
+	filter.validate()
+	assert.Zero(t, filter.From)
+	assert.Zero(t, filter.To)
+	filter = &Filter{Day: pastDay(3)}
+	filter.validate()
+	assert.Zero(t, filter.From)
+	assert.Zero(t, filter.To)
+	filter = &Filter{Start: time.Now().Add(-time.Minute)}
+	filter.validate()
 	assert.Zero(t, filter.From)
 	assert.Zero(t, filter.To)
+	timezone, err := time.LoadLocation("Pacific/Kiritimati")
+	assert.NoError(t, err)
+	now := time.Now().In(timezone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	filter = &Filter{Timezone: timezone}
+	filter.validate()
+	assert.Equal(t, today.AddDate(0, 0, -(DefaultPeriodDays-1)), filter.From)
+	assert.Equal(t, today, filter.To)
 	filter = &Filter{From: pastDay(2), To: pastDay(5), Limit: 42}
 	filter.validate()
 	assert.Equal(t, pastDay(5), filter.From)
@@ -36,6 +57,135 @@ func TestFilter_Validate(t *testing.T) {
 	filter.validate()
 	assert.Empty(t, filter.Path)
 	assert.Equal(t, "pattern", filter.PathPattern)
+	filter = &Filter{Limit: MaxLimit + 1}
+	filter.validate()
+	assert.Equal(t, MaxLimit, filter.Limit)
+}
+
+func TestFilter_ValidateIncludeToday(t *testing.T) {
+	include, exclude := true, false
+	filter := &Filter{From: pastDay(5), To: pastDay(1), IncludeToday: &include}
+	filter.validate()
+	assert.Equal(t, pastDay(5), filter.From)
+	assert.Equal(t, Today(), filter.To)
+	filter = &Filter{From: pastDay(5), To: Today(), IncludeToday: &exclude}
+	filter.validate()
+	assert.Equal(t, pastDay(5), filter.From)
+	assert.Equal(t, pastDay(1), filter.To)
+	filter = &Filter{From: pastDay(5), To: pastDay(2), IncludeToday: &exclude}
+	filter.validate()
+	assert.Equal(t, pastDay(2), filter.To)
+	filter = &Filter{IncludeToday: &exclude}
+	filter.validate()
+	assert.Equal(t, pastDay(1), filter.To)
+	filter = &Filter{IncludeToday: &include, AllTime: true}
+	filter.validate()
+	assert.Zero(t, filter.To)
+}
+
+func TestFilter_ValidateSnap(t *testing.T) {
+	input := []struct {
+		snap     string
+		from     time.Time
+		to       time.Time
+		expected [2]time.Time
+	}{
+		{SnapWeek, date(2021, 6, 9), date(2021, 6, 9), [2]time.Time{date(2021, 6, 7), date(2021, 6, 13)}},
+		{SnapWeek, date(2021, 6, 7), date(2021, 6, 13), [2]time.Time{date(2021, 6, 7), date(2021, 6, 13)}},
+		{SnapWeek, date(2021, 6, 13), date(2021, 6, 14), [2]time.Time{date(2021, 6, 7), date(2021, 6, 20)}},
+		{SnapMonth, date(2021, 2, 10), date(2021, 2, 11), [2]time.Time{date(2021, 2, 1), date(2021, 2, 28)}},
+		{SnapMonth, date(2021, 1, 31), date(2021, 12, 1), [2]time.Time{date(2021, 1, 1), date(2021, 12, 31)}},
+		{SnapQuarter, date(2021, 5, 15), date(2021, 5, 20), [2]time.Time{date(2021, 4, 1), date(2021, 6, 30)}},
+		{SnapQuarter, date(2021, 3, 31), date(2021, 10, 1), [2]time.Time{date(2021, 1, 1), date(2021, 12, 31)}},
+		{"", date(2021, 5, 15), date(2021, 5, 20), [2]time.Time{date(2021, 5, 15), date(2021, 5, 20)}},
+	}
+
+	for _, in := range input {
+		filter := &Filter{From: in.from, To: in.to, Snap: in.snap}
+		filter.validate()
+		assert.Equal(t, in.expected[0], filter.From)
+		assert.Equal(t, in.expected[1], filter.To)
+	}
+
+	filter := &Filter{From: Today(), To: Today(), Snap: SnapQuarter}
+	filter.validate()
+	assert.Equal(t, Today(), filter.To)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFilter_EncodeQuery(t *testing.T) {
+	timezone, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	includeToday := false
+	filter := &Filter{
+		ClientID:             42,
+		Timezone:             timezone,
+		From:                 pastDay(5),
+		To:                   pastDay(2),
+		IncludeToday:         &includeToday,
+		Day:                  pastDay(1),
+		Start:                time.Date(2021, 7, 1, 12, 30, 15, 0, time.UTC),
+		Path:                 "/path",
+		PathPattern:          "(?i)^/path/[^/]+$",
+		Language:             "en",
+		Country:              "de",
+		Referrer:             "ref",
+		Referrers:            []string{"ref1", "ref2"},
+		Countries:            []string{"fr", "it"},
+		OS:                   OSWindows,
+		OSVersion:            "10",
+		Browser:              BrowserChrome,
+		BrowserVersion:       "90",
+		Platform:             PlatformDesktop,
+		ScreenClass:          "XL",
+		UTMSource:            "source",
+		UTMMedium:            "medium",
+		UTMCampaign:          "campaign",
+		UTMContent:           "content",
+		UTMTerm:              "term",
+		StatusCode:           404,
+		Method:               "POST",
+		Search:               "search",
+		Snap:                 SnapMonth,
+		EventName:            "event",
+		EventMetaKey:         "key",
+		EventMeta:            map[string]string{"plan": "pro", "currency": "EUR", "time:zone": "UTC+1:00", "100%": "yes"},
+		Limit:                42,
+		IncludeAvgTimeOnPage: true,
+		MaxTimeOnPageSeconds: 300,
+		SessionBounces:       true,
+		DistinctPerDay:       true,
+		AllTime:              true,
+		Compare:              true,
+	}
+	values := filter.EncodeQuery()
+	assert.Equal(t, pastDay(5).Format("2006-01-02"), values.Get("from"))
+	assert.Equal(t, "Europe/Berlin", values.Get("tz"))
+	assert.Equal(t, "2021-07-01T12:30:15Z", values.Get("start"))
+	assert.Equal(t, []string{"ref1", "ref2"}, values["referrers"])
+	assert.Empty(t, values.Get("client_id"))
+	parsed := ParseFilterQuery(42, values)
+	assert.Equal(t, "Europe/Berlin", parsed.Timezone.String())
+	filter.Timezone, parsed.Timezone = nil, nil
+	assert.Equal(t, filter, parsed)
+	assert.Empty(t, NewFilter(NullClient).EncodeQuery())
+	parsed = ParseFilterQuery(NullClient, url.Values{
+		"from":          []string{"invalid"},
+		"limit":         []string{"nan"},
+		"tz":            []string{"Invalid/Zone"},
+		"include_today": []string{"maybe"},
+		"referrers":     []string{" ", "ref"},
+		"event_meta":    []string{"invalid", ":value", "%zz:value", "key:value"},
+	})
+	assert.Zero(t, parsed.From)
+	assert.Zero(t, parsed.Limit)
+	assert.Nil(t, parsed.Timezone)
+	assert.Nil(t, parsed.IncludeToday)
+	assert.Equal(t, []string{"ref"}, parsed.Referrers)
+	assert.Equal(t, map[string]string{"key": "value"}, parsed.EventMeta)
 }
 
 func TestFilter_Table(t *testing.T) {
@@ -45,6 +195,24 @@ func TestFilter_Table(t *testing.T) {
 	assert.Equal(t, "event", filter.table())
 }
 
+func TestFilter_QueryDistinctPerDay(t *testing.T) {
+	filter := NewFilter(42)
+	filter.DistinctPerDay = true
+	filter.Path = "/"
+	args, query := filter.query()
+	assert.Equal(t, []interface{}{int64(42), "/", int64(42), "/"}, args)
+	assert.Equal(t, `client_id = ? AND path = ? AND (client_id, fingerprint, time) IN (
+			SELECT client_id, fingerprint, min(time)
+			FROM hit
+			WHERE client_id = ? AND path = ?
+			GROUP BY client_id, fingerprint, toDate(time, 'UTC')
+		) `, query)
+	filter.Path = ""
+	args, query = filter.queryTable("event")
+	assert.Equal(t, []interface{}{int64(42), EventHeartbeat, int64(42), EventHeartbeat}, args)
+	assert.Contains(t, query, "FROM event")
+}
+
 func TestFilter_QueryTime(t *testing.T) {
 	filter := NewFilter(NullClient)
 	filter.From = pastDay(5)
@@ -61,6 +229,28 @@ func TestFilter_QueryTime(t *testing.T) {
 	assert.Equal(t, "client_id = ? AND toDate(time, 'UTC') >= toDate(?, 'UTC') AND toDate(time, 'UTC') <= toDate(?, 'UTC') AND toDate(time, 'UTC') = toDate(?, 'UTC') AND toDateTime(time, 'UTC') >= toDateTime(?, 'UTC') ", query)
 }
 
+func TestFilter_QueryTimeExcludedWindows(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.ExcludedWindows = []TimeWindow{{From: pastDay(3), To: pastDay(2)}}
+	args, query := filter.queryTime()
+	assert.Equal(t, []interface{}{NullClient, pastDay(3), pastDay(2)}, args)
+	assert.Equal(t, "client_id = ? AND NOT (time >= toDateTime(?, 'UTC') AND time < toDateTime(?, 'UTC')) ", query)
+}
+
+func TestFilter_ExcludedDay(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.validate()
+	assert.False(t, filter.excludedDay(pastDay(2)))
+	filter.ExcludedWindows = []TimeWindow{
+		{From: pastDay(3), To: pastDay(2)},
+		{From: pastDay(1), To: pastDay(1).Add(time.Hour)},
+	}
+	assert.False(t, filter.excludedDay(pastDay(4)))
+	assert.True(t, filter.excludedDay(pastDay(3)))
+	assert.False(t, filter.excludedDay(pastDay(2)))
+	assert.False(t, filter.excludedDay(pastDay(1)))
+}
+
 func TestFilter_QueryFields(t *testing.T) {
 	filter := NewFilter(NullClient)
 	filter.Path = "/"
@@ -83,7 +273,60 @@ func TestFilter_QueryFields(t *testing.T) {
 	filter.validate()
 	args, query := filter.queryFields()
 	assert.Len(t, args, 15)
-	assert.Equal(t, "path = ? AND language = ? AND country_code = ? AND referrer = ? AND os = ? AND os_version = ? AND browser = ? AND browser_version = ? AND screen_class = ? AND utm_source = ? AND utm_medium = ? AND utm_campaign = ? AND utm_content = ? AND utm_term = ? AND event_name = ? AND desktop = 0 AND mobile = 0 ", query)
+	assert.Equal(t, "path = ? AND language = ? AND lower(country_code) = ? AND referrer = ? AND os = ? AND os_version = ? AND browser = ? AND browser_version = ? AND screen_class = ? AND utm_source = ? AND utm_medium = ? AND utm_campaign = ? AND utm_content = ? AND utm_term = ? AND event_name = ? AND desktop = 0 AND mobile = 0 ", query)
+}
+
+func TestFilter_QueryFieldsReferrers(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.Referrers = []string{"", "ref1"}
+	args, query := filter.queryFields()
+	assert.Len(t, args, 1)
+	assert.Equal(t, "ref1", args[0])
+	assert.Equal(t, "referrer = ? ", query)
+	filter.Referrer = "ref1"
+	filter.Referrers = []string{"ref2", "", "ref3", "ref2"}
+	filter.Language = "en"
+	args, query = filter.queryFields()
+	assert.Len(t, args, 4)
+	assert.Equal(t, "en", args[0])
+	assert.Equal(t, "ref1", args[1])
+	assert.Equal(t, "ref2", args[2])
+	assert.Equal(t, "ref3", args[3])
+	assert.Equal(t, "language = ? AND referrer IN (?,?,?) ", query)
+	filter = NewFilter(NullClient)
+	filter.Referrers = []string{"", ""}
+	args, query = filter.queryFields()
+	assert.Empty(t, args)
+	assert.Empty(t, query)
+}
+
+func TestFilter_QueryFieldsCountries(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.Country = "DE"
+	filter.Countries = []string{"", "fr", "De", "IT"}
+	filter.validate()
+	args, query := filter.queryFields()
+	assert.Len(t, args, 3)
+	assert.Equal(t, "de", args[0])
+	assert.Equal(t, "fr", args[1])
+	assert.Equal(t, "it", args[2])
+	assert.Equal(t, "lower(country_code) IN (?,?,?) ", query)
+	filter = NewFilter(NullClient)
+	filter.Countries = []string{"", ""}
+	args, query = filter.queryFields()
+	assert.Empty(t, args)
+	assert.Empty(t, query)
+}
+
+func TestFilter_QueryFieldsSearch(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.Search = `50%_off\`
+	filter.PathPattern = "pattern"
+	args, query := filter.queryFields()
+	assert.Len(t, args, 3)
+	assert.Equal(t, `%50\%\_off\\%`, args[0])
+	assert.Equal(t, args[0], args[1])
+	assert.Equal(t, `("path" ILIKE ? OR referrer ILIKE ?) AND match("path", ?) = 1 `, query)
 }
 
 func TestFilter_QueryFieldsPlatform(t *testing.T) {
@@ -96,7 +339,12 @@ func TestFilter_QueryFieldsPlatform(t *testing.T) {
 	filter.Platform = PlatformMobile
 	args, query = filter.queryFields()
 	assert.Len(t, args, 0)
-	assert.Equal(t, "mobile = 1 ", query)
+	assert.Equal(t, "mobile = 1 AND tablet = 0 ", query)
+	filter = NewFilter(NullClient)
+	filter.Platform = PlatformTablet
+	args, query = filter.queryFields()
+	assert.Len(t, args, 0)
+	assert.Equal(t, "tablet = 1 ", query)
 	filter = NewFilter(NullClient)
 	filter.Platform = PlatformUnknown
 	args, query = filter.queryFields()
@@ -112,7 +360,44 @@ func TestFilter_QueryFieldsPathPattern(t *testing.T) {
 	args, query := filter.queryFields()
 	assert.Len(t, args, 1)
 	assert.Equal(t, "/some/pattern", args[0])
-	assert.Equal(t, `match("path", ?) = 1`, query)
+	assert.Equal(t, `match("path", ?) = 1 `, query)
+}
+
+func TestFilter_QueryEvents(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.PathPattern = "/some/pattern"
+	filter.EventMetaKey = "key"
+	args, query := filter.queryTable("event")
+	assert.Equal(t, []interface{}{NullClient, "/some/pattern", EventHeartbeat, "key"}, args)
+	assert.Equal(t, `client_id = ? AND match("path", ?) = 1 AND event_name != ? AND has(event_meta_keys, ?) `, query)
+	filter.EventName = "event"
+	args, query = filter.query()
+	assert.Equal(t, []interface{}{NullClient, "event", "/some/pattern", "key"}, args)
+	assert.Equal(t, `client_id = ? AND event_name = ? AND match("path", ?) = 1 AND has(event_meta_keys, ?) `, query)
+	filter.EventName = ""
+	_, query = filter.query()
+	assert.NotContains(t, query, "event_meta_keys")
+}
+
+func TestFilter_QueryFieldsEventMeta(t *testing.T) {
+	filter := NewFilter(NullClient)
+	filter.EventMeta = map[string]string{"plan": "pro", "currency": "EUR"}
+	args, query := filter.queryFields()
+	assert.Len(t, args, 0)
+	assert.Empty(t, query)
+	filter.EventName = "purchase"
+	args, query = filter.queryFields()
+	assert.Equal(t, []interface{}{"purchase", "currency", "EUR", "plan", "pro"}, args)
+	assert.Equal(t, "event_name = ? AND arrayExists((k, v) -> k = ? AND v = ?, event_meta_keys, event_meta_values) AND arrayExists((k, v) -> k = ? AND v = ?, event_meta_keys, event_meta_values) ", query)
+}
+
+func TestFilter_QueryClients(t *testing.T) {
+	filter := NewFilter(42)
+	filter.Path = "/"
+	filter.clientIDs = []int64{1, 2, 3}
+	args, query := filter.query()
+	assert.Equal(t, []interface{}{int64(1), int64(2), int64(3), "/"}, args)
+	assert.Equal(t, "client_id IN (?,?,?) AND path = ? ", query)
 }
 
 func TestFilter_WithFill(t *testing.T) {
@@ -129,6 +414,59 @@ func TestFilter_WithFill(t *testing.T) {
 	assert.Equal(t, "WITH FILL FROM toDate(?, 'UTC') TO toDate(?, 'UTC')+1 ", query)
 }
 
+func TestFilter_Days(t *testing.T) {
+	filter := NewFilter(NullClient)
+	assert.Nil(t, filter.days())
+	filter.From = pastDay(3)
+	filter.To = pastDay(1)
+	days := filter.days()
+	assert.Len(t, days, 3)
+	assert.Equal(t, pastDay(3), days[0])
+	assert.Equal(t, pastDay(2), days[1])
+	assert.Equal(t, pastDay(1), days[2])
+}
+
+func TestFilter_PreviousPeriod(t *testing.T) {
+	filter := &Filter{Day: pastDay(2), Compare: true}
+	previous := filter.previousPeriod()
+	assert.Equal(t, pastDay(3), previous.Day)
+	assert.False(t, previous.Compare)
+	assert.True(t, filter.Compare)
+	filter = &Filter{From: pastDay(13), To: pastDay(7)}
+	previous = filter.previousPeriod()
+	assert.Equal(t, pastDay(20), previous.From)
+	assert.Equal(t, pastDay(14), previous.To)
+	filter = &Filter{From: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)}
+	previous = filter.previousPeriod()
+	assert.Equal(t, time.Date(2021, 2, 22, 0, 0, 0, 0, time.UTC), previous.From)
+	assert.Equal(t, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), previous.To)
+	filter = &Filter{From: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)}
+	previous = filter.previousPeriod()
+	assert.Equal(t, time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), previous.From)
+	assert.Equal(t, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), previous.To)
+	today := Today()
+	startOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	filter = &Filter{From: startOfMonth, To: today}
+	previous = filter.previousPeriod()
+	endOfPreviousMonth := startOfMonth.AddDate(0, 0, -1)
+	expectedTo := startOfMonth.AddDate(0, -1, today.Day()-1)
+
+	if expectedTo.After(endOfPreviousMonth) {
+		expectedTo = endOfPreviousMonth
+	}
+
+	assert.Equal(t, startOfMonth.AddDate(0, -1, 0), previous.From)
+	assert.Equal(t, expectedTo, previous.To)
+	filter = &Filter{From: time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 4, 30, 0, 0, 0, 0, time.UTC)}
+	previous = filter.previousPeriod()
+	assert.Equal(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), previous.From)
+	assert.Equal(t, time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC), previous.To)
+	filter = &Filter{From: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 4, 15, 0, 0, 0, 0, time.UTC)}
+	previous = filter.previousPeriod()
+	assert.Equal(t, time.Date(2021, 1, 14, 0, 0, 0, 0, time.UTC), previous.From)
+	assert.Equal(t, time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), previous.To)
+}
+
 func TestFilter_WithLimit(t *testing.T) {
 	filter := NewFilter(NullClient)
 	assert.Empty(t, filter.withLimit())
@@ -2,6 +2,7 @@ package pirsch
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"io"
 	"net/http"
@@ -23,3 +24,22 @@ func Fingerprint(r *http.Request, salt string) string {
 
 	return hex.EncodeToString(hash.Sum(nil))
 }
+
+// UserFingerprint returns a hash for given user ID and salt, which can be used instead of the Fingerprint.
+// The user ID is never stored, only the hash, so it should already be a pseudonymous key.
+func UserFingerprint(userID, salt string) string {
+	hash := md5.New()
+
+	if _, err := io.WriteString(hash, "user:"+userID+salt); err != nil {
+		return "" // this should never fail actually...
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// IPHash returns a hash of the client IP for given request and salt.
+// Other than the Fingerprint, it only depends on the IP, so that all hits from the same IP can be grouped.
+func IPHash(r *http.Request, salt string) string {
+	hash := sha256.Sum256([]byte(getIP(r) + salt))
+	return hex.EncodeToString(hash[:])
+}
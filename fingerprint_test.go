@@ -2,6 +2,7 @@ package pirsch
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -20,3 +21,22 @@ func TestFingerprint(t *testing.T) {
 	fp := hex.EncodeToString(hash.Sum(nil))
 	assert.Equal(t, fp, Fingerprint(req, "salt"))
 }
+
+func TestUserFingerprint(t *testing.T) {
+	hash := md5.New()
+	_, err := io.WriteString(hash, "user:42salt")
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(hash.Sum(nil)), UserFingerprint("42", "salt"))
+	assert.NotEqual(t, UserFingerprint("42", "salt"), UserFingerprint("43", "salt"))
+}
+
+func TestIPHash(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "test")
+	req.RemoteAddr = "127.0.0.1:80"
+	hash := sha256.Sum256([]byte("127.0.0.1salt"))
+	assert.Equal(t, hex.EncodeToString(hash[:]), IPHash(req, "salt"))
+	assert.NotEqual(t, IPHash(req, "salt"), IPHash(req, "rotated"))
+	req.Header.Set("User-Agent", "other")
+	assert.Equal(t, hex.EncodeToString(hash[:]), IPHash(req, "salt"))
+}
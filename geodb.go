@@ -32,12 +32,22 @@ type GeoDBConfig struct {
 	// Note that this will log the IP address and should therefore only be used for debugging.
 	// Set it to nil to disable logging for GeoDB.
 	Logger *log.Logger
+
+	// SkipPrivateIPs disables the lookup for loopback, link-local, and private (RFC 1918) addresses.
+	// These can't be mapped to a country and will return the PrivateIPCountryCode instead.
+	SkipPrivateIPs bool
+
+	// PrivateIPCountryCode is the optional country code returned for private addresses if SkipPrivateIPs is enabled.
+	// This can be used to assign a country to traffic from local development environments.
+	PrivateIPCountryCode string
 }
 
 // GeoDB maps IPs to their geo location based on MaxMinds GeoLite2 or GeoIP2 database.
 type GeoDB struct {
-	db     *maxminddb.Reader
-	logger *log.Logger
+	db                   *maxminddb.Reader
+	logger               *log.Logger
+	skipPrivateIPs       bool
+	privateIPCountryCode string
 }
 
 // NewGeoDB creates a new GeoDB for given database file.
@@ -57,8 +67,10 @@ func NewGeoDB(config GeoDBConfig) (*GeoDB, error) {
 	}
 
 	return &GeoDB{
-		db:     db,
-		logger: config.Logger,
+		db:                   db,
+		logger:               config.Logger,
+		skipPrivateIPs:       config.SkipPrivateIPs,
+		privateIPCountryCode: strings.ToLower(config.PrivateIPCountryCode),
 	}, nil
 }
 
@@ -76,6 +88,10 @@ func (db *GeoDB) CountryCode(ip string) string {
 		return ""
 	}
 
+	if db.skipPrivateIPs && isPrivateIP(parsedIP) {
+		return db.privateIPCountryCode
+	}
+
 	record := struct {
 		Country struct {
 			ISOCode string `maxminddb:"iso_code"`
@@ -29,3 +29,22 @@ func TestGeoDB_CountryCode(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "gb", db.CountryCode("81.2.69.142"))
 }
+
+func TestGeoDB_CountryCodePrivateIP(t *testing.T) {
+	db, err := NewGeoDB(GeoDBConfig{
+		File:           filepath.Join("geodb/GeoIP2-Country-Test.mmdb"),
+		SkipPrivateIPs: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "gb", db.CountryCode("81.2.69.142"))
+	assert.Empty(t, db.CountryCode("127.0.0.1"))
+	db, err = NewGeoDB(GeoDBConfig{
+		File:                 filepath.Join("geodb/GeoIP2-Country-Test.mmdb"),
+		SkipPrivateIPs:       true,
+		PrivateIPCountryCode: "DE",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "gb", db.CountryCode("81.2.69.142"))
+	assert.Equal(t, "de", db.CountryCode("127.0.0.1"))
+	assert.Equal(t, "de", db.CountryCode("192.168.0.10"))
+}
@@ -0,0 +1,177 @@
+package pirsch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMaxBodySize = 16 * 1024
+)
+
+// defaultEventMetaLimits are applied by the EventHandler if the Tracker has no EventMetaLimits.
+var defaultEventMetaLimits = &EventMetaLimits{
+	MaxKeys:        20,
+	MaxKeyLength:   200,
+	MaxValueLength: 200,
+}
+
+// HandlerConfig is the optional configuration for the tracking handlers.
+type HandlerConfig struct {
+	// AllowedOrigins is a list of origins (like https://example.com) that are allowed to send cross-origin requests.
+	// Requests with an Origin header that is not on the list will be rejected with 403 Forbidden.
+	// Requests without an Origin header (like same-origin GET requests) are always accepted.
+	// Leave it empty to accept requests from all origins.
+	AllowedOrigins []string
+
+	// MaxBodySize is the maximum size of a request body in bytes.
+	// Requests with a larger body will be rejected with 413 Request Entity Too Large.
+	// Set to 16 KB by default.
+	MaxBodySize int64
+}
+
+// copyHandlerConfig returns a validated copy of given config, so that later changes to it don't affect the handlers.
+func copyHandlerConfig(config *HandlerConfig) *HandlerConfig {
+	handlerConfig := new(HandlerConfig)
+
+	if config != nil {
+		*handlerConfig = *config
+	}
+
+	handlerConfig.validate()
+	return handlerConfig
+}
+
+func (config *HandlerConfig) validate() {
+	allowedOrigins := make([]string, 0, len(config.AllowedOrigins))
+
+	for _, origin := range config.AllowedOrigins {
+		allowedOrigins = append(allowedOrigins, strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/"))
+	}
+
+	config.AllowedOrigins = allowedOrigins
+
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultMaxBodySize
+	}
+}
+
+type eventRequest struct {
+	Name     string            `json:"event_name"`
+	Duration int               `json:"event_duration"`
+	Value    *float64          `json:"event_value"`
+	Meta     map[string]string `json:"event_meta"`
+}
+
+func (req *eventRequest) validate() bool {
+	return strings.TrimSpace(req.Name) != ""
+}
+
+// HitHandler returns a http.Handler to track hits sent by pirsch.js.
+// The HitOptions are read from the request (see HitOptionsFromRequest) and CORS preflight requests are answered for the allowed origins.
+// Pass nil for the config to use the defaults. The config is copied, so changing it afterwards has no effect.
+// You might want to wrap the handler to run additional checks, like for the client ID.
+func HitHandler(tracker *Tracker, config *HandlerConfig) http.Handler {
+	config = copyHandlerConfig(config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !handleCORS(w, r, config) {
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		tracker.Hit(r, HitOptionsFromRequest(r))
+	})
+}
+
+// EventHandler returns a http.Handler to track events sent by pirsch.js.
+// Events must be sent as POST requests with a JSON body containing the event_name, and optionally the event_duration, event_value, and event_meta.
+// The HitOptions are read from the request (see HitOptionsFromRequest) and CORS preflight requests are answered for the allowed origins.
+// Bodies larger than HandlerConfig.MaxBodySize are rejected with 413 Request Entity Too Large and events without a name with 400 Bad Request.
+// The event meta data is limited by the Tracker (see TrackerConfig.EventMetaLimits).
+// If the Tracker has no limits, the meta data is limited to 20 keys, and keys and values are truncated to 200 bytes.
+// Pass nil for the config to use the defaults. The config is copied, so changing it afterwards has no effect.
+func EventHandler(tracker *Tracker, config *HandlerConfig) http.Handler {
+	config = copyHandlerConfig(config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !handleCORS(w, r, config) {
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, config.MaxBodySize))
+
+		if err != nil {
+			if int64(len(body)) >= config.MaxBodySize {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+
+			return
+		}
+
+		var req eventRequest
+
+		if err := json.Unmarshal(body, &req); err != nil || !req.validate() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if tracker.eventMetaLimits == nil {
+			req.Meta = defaultEventMetaLimits.apply(strings.TrimSpace(req.Name), req.Meta)
+		}
+
+		tracker.Event(r, EventOptions{
+			Name:     req.Name,
+			Duration: req.Duration,
+			Value:    req.Value,
+			Meta:     req.Meta,
+		}, HitOptionsFromRequest(r))
+	})
+}
+
+// handleCORS sets the CORS headers and returns true if the request should be processed any further.
+func handleCORS(w http.ResponseWriter, r *http.Request, config *HandlerConfig) bool {
+	origin := r.Header.Get("Origin")
+
+	// the response depends on the origin if not all origins are allowed, or if it's sent back
+	if len(config.AllowedOrigins) > 0 || origin != "" {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if origin != "" {
+		if !allowOrigin(config.AllowedOrigins, origin) {
+			w.WriteHeader(http.StatusForbidden)
+			return false
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	}
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+
+	return true
+}
+
+func allowOrigin(allowedOrigins []string, origin string) bool {
+	if len(allowedOrigins) == 0 {
+		return true
+	}
+
+	return containsString(allowedOrigins, strings.TrimSuffix(strings.ToLower(origin), "/"))
+}
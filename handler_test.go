@@ -0,0 +1,164 @@
+package pirsch
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHitHandler(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	allowedOrigins := []string{"https://example.com/", "HTTPS://Pirsch.io"}
+	config := &HandlerConfig{
+		AllowedOrigins: allowedOrigins,
+	}
+	handler := HitHandler(tracker, config)
+	assert.Equal(t, []string{"https://example.com/", "HTTPS://Pirsch.io"}, allowedOrigins)
+	assert.Equal(t, &HandlerConfig{AllowedOrigins: allowedOrigins}, config)
+	config.AllowedOrigins = []string{"https://evil.com"}
+	req := httptest.NewRequest(http.MethodOptions, "/count", nil)
+	req.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	req = httptest.NewRequest(http.MethodGet, "/count?url=https://pirsch.io/path", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req.Header.Set("Origin", "https://pirsch.io")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://pirsch.io", w.Header().Get("Access-Control-Allow-Origin"))
+	req = httptest.NewRequest(http.MethodGet, "/count?url=https://pirsch.io/path", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	req = httptest.NewRequest(http.MethodGet, "/count?url=https://pirsch.io/same-origin", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	req = httptest.NewRequest(http.MethodDelete, "/count", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 2)
+	paths := []string{client.Hits[0].Path, client.Hits[1].Path}
+	assert.Contains(t, paths, "/path")
+	assert.Contains(t, paths, "/same-origin")
+}
+
+func TestHitHandlerTrackerConfig(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		ReferrerDomainBlacklist: []string{"blacklisted.com"},
+		StoreLocale:             true,
+	})
+	handler := HitHandler(tracker, nil)
+	req := httptest.NewRequest(http.MethodGet, "/count?url=https://pirsch.io/path&ref=https://blacklisted.com/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req.Header.Set("Accept-Language", "en-US")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+	assert.Empty(t, client.Hits[0].Referrer)
+	assert.Equal(t, "en-US", client.Hits[0].Locale)
+}
+
+func TestHitHandlerAllOrigins(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	handler := HitHandler(tracker, nil)
+	req := httptest.NewRequest(http.MethodGet, "/count", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+}
+
+func TestEventHandler(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		EventMetaLimits: &EventMetaLimits{
+			MaxKeys:        2,
+			MaxValueLength: 10,
+		},
+	})
+	handler := EventHandler(tracker, &HandlerConfig{
+		MaxBodySize: 200,
+	})
+	bodies := []struct {
+		method string
+		body   string
+		code   int
+	}{
+		{http.MethodPost, `{"event_name": "event", "event_duration": 42, "event_meta": {"key": "value"}}`, http.StatusOK},
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, `{"event_name": "` + strings.Repeat("a", 300) + `"}`, http.StatusRequestEntityTooLarge},
+		{http.MethodPost, `invalid`, http.StatusBadRequest},
+		{http.MethodPost, `{"event_name": " "}`, http.StatusBadRequest},
+		{http.MethodPost, `{"event_name": "limits", "event_meta": {"a": "1", "b": "too long value", "c": "3"}}`, http.StatusOK},
+	}
+
+	for _, b := range bodies {
+		req := httptest.NewRequest(b.method, "/event?url=https://pirsch.io/path", strings.NewReader(b.body))
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, b.code, w.Code, b.body)
+	}
+
+	tracker.Stop()
+	assert.Len(t, client.Events, 2)
+	sort.Slice(client.Events, func(i, j int) bool {
+		return client.Events[i].Name < client.Events[j].Name
+	})
+	assert.Equal(t, "event", client.Events[0].Name)
+	assert.Equal(t, 42, client.Events[0].DurationSeconds)
+	assert.Equal(t, "/path", client.Events[0].Path)
+	assert.Equal(t, []string{"key"}, client.Events[0].MetaKeys)
+	assert.Equal(t, []string{"value"}, client.Events[0].MetaValues)
+	assert.Equal(t, "limits", client.Events[1].Name)
+	assert.Equal(t, []string{"a", "b"}, client.Events[1].MetaKeys)
+	assert.Equal(t, []string{"1", "too long v"}, client.Events[1].MetaValues)
+}
+
+func TestEventHandlerDefaultMetaLimits(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	handler := EventHandler(tracker, nil)
+	meta := make([]string, 0, 25)
+
+	for i := 0; i < 25; i++ {
+		meta = append(meta, fmt.Sprintf(`"key%02d": "%s"`, i, strings.Repeat("a", 300)))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/event?url=https://pirsch.io/path", strings.NewReader(`{"event_name": "event", "event_meta": {`+strings.Join(meta, ",")+`}}`))
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	tracker.Stop()
+	assert.Len(t, client.Events, 1)
+	assert.Len(t, client.Events[0].MetaKeys, 20)
+	assert.Contains(t, client.Events[0].MetaKeys, "key00")
+	assert.NotContains(t, client.Events[0].MetaKeys, "key20")
+	assert.Len(t, client.Events[0].MetaValues[0], 200)
+}
@@ -3,6 +3,7 @@ package pirsch
 import (
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +21,17 @@ const (
 	defaultSessionMaxAge = time.Minute * 15
 )
 
+// ignoreReason is the reason a request is dropped instead of being tracked.
+type ignoreReason int
+
+const (
+	notIgnored ignoreReason = iota
+	ignoredDoNotTrack
+	ignoredBot
+	ignoredRateLimit
+	ignoredOther
+)
+
 // HitOptions is used to manipulate the data saved on a hit.
 type HitOptions struct {
 	// Client is the database client required to look up sessions.
@@ -41,6 +53,11 @@ type HitOptions struct {
 	// This will also affect the URL.
 	Path string
 
+	// UserID is an optional stable key for a logged-in user (like a hashed account ID).
+	// If set, it's hashed together with the salt using UserFingerprint and replaces the fingerprint of the request,
+	// so visitors and sessions are counted per user instead of per device. The user ID itself is never stored.
+	UserID string
+
 	// Referrer can be set to manually overwrite the referrer from the request.
 	Referrer string
 
@@ -55,20 +72,61 @@ type HitOptions struct {
 	// If the blacklist contains domain.com, sub.domain.com and domain.com will be treated as equals.
 	ReferrerDomainBlacklistIncludesSubdomains bool
 
+	// IgnoreSelfReferrer drops the referrer if its host equals the host of the page that was visited (internal navigation).
+	// The host is read from the URL, or the Host header of the request if the URL doesn't contain a host.
+	// This filters out self-referrals without listing the domain in the ReferrerDomainBlacklist.
+	IgnoreSelfReferrer bool
+
+	// IPHashSalt enables storing a salted hash of the client IP with the hit (see IPHash), which is disabled if it's empty.
+	// This allows grouping hits by IP to investigate abuse, without storing the IP itself.
+	// Note that this is a privacy tradeoff: as long as the salt is known, the hash can be matched to an IP by hashing it again.
+	// Rotate the salt regularly and keep it secret. Hashes created using different salts cannot be correlated.
+	IPHashSalt string
+
+	// StoreLocale stores the full locale (like en-US) from the Accept-Language header with the hit, in addition to the language.
+	StoreLocale bool
+
 	// ScreenWidth sets the screen width to be stored with the hit.
 	ScreenWidth int
 
 	// ScreenHeight sets the screen height to be stored with the hit.
 	ScreenHeight int
 
-	geoDB *GeoDB
+	// EntryReferrerOnly stores the referrer for the first hit of a session only.
+	// Subsequent hits of the session are stored without a referrer, so that internal navigation doesn't overwrite the source of a session.
+	// This requires the Client to look up the session.
+	EntryReferrerOnly bool
+
+	// StatusCode is the optional HTTP response status code (like 404) stored with the hit.
+	// Leave it 0 if it's unknown.
+	StatusCode int
+
+	// Method is the optional HTTP method (like POST) stored with the hit.
+	// It's not taken from the request, as that's usually the method used to send the hit, not the one of the page.
+	Method string
+
+	// SignificantQueryParams is a list of query parameters that are kept in the path (like "product" for /shop?product=shoes).
+	// The path is stored without a query by default. Parameters listed here are appended in canonical (sorted) order,
+	// so that ?a=1&b=2 and ?b=2&a=1 result in the same path, while all other parameters are dropped.
+	// This has no effect if the Path is set manually.
+	SignificantQueryParams []string
+
+	// SearchQueryParameter is the name of the query parameter used for site search (like "q" for /search?q=term).
+	// If set, the search query will be extracted from the URL and stored with the hit.
+	SearchQueryParameter string
+
+	geoDB           *GeoDB
+	referrerMapping func(string) (ReferrerMapping, bool)
 }
 
 // HitFromRequest returns a new Hit for given request, salt and HitOptions.
 // The salt must stay consistent to track visitors across multiple calls.
 // The easiest way to track visitors is to use the Tracker.
 func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
-	now := time.Now().UTC() // capture first to get as close as possible, hits and sessions use UTC
+	// capture first to get as close as possible, hits and sessions use UTC
+	// the time is truncated to seconds, as that's the precision stored in the database,
+	// so that the session timestamp read back from the database equals the one set here
+	now := time.Now().UTC().Truncate(time.Second)
 
 	// set default options in case they're nil
 	if options == nil {
@@ -81,7 +139,20 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 
 	// shorten strings if required and parse User-Agent to extract more data (OS, Browser)
 	getRequestURI(r, options)
-	fingerprint := Fingerprint(r, salt)
+	var fingerprint string
+
+	if options.UserID != "" {
+		fingerprint = UserFingerprint(options.UserID, salt)
+	} else {
+		fingerprint = Fingerprint(r, salt)
+	}
+
+	ipHash := ""
+
+	if options.IPHashSalt != "" {
+		ipHash = IPHash(r, options.IPHashSalt)
+	}
+
 	userAgent := r.UserAgent()
 	path := shortenString(options.Path, 2000)
 	requestURL := shortenString(options.URL, 2000)
@@ -92,13 +163,38 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 	uaInfo.BrowserVersion = shortenString(uaInfo.BrowserVersion, 20)
 	userAgent = shortenString(userAgent, 200)
 	lang := shortenString(getLanguage(r), 10)
+	locale := ""
+
+	if options.StoreLocale {
+		locale = shortenString(getLocale(r), 20)
+	}
+
 	referrer, referrerName, referrerIcon := getReferrer(r, options.Referrer, options.ReferrerDomainBlacklist, options.ReferrerDomainBlacklistIncludesSubdomains)
+
+	if options.IgnoreSelfReferrer && referrer != "" && isSelfReferrer(r, referrer, options.URL) {
+		referrer, referrerName, referrerIcon = "", "", ""
+	}
+
+	if referrer != "" && referrerName == "" && options.referrerMapping != nil {
+		if mapping, found := options.referrerMapping(referrer); found {
+			referrerName, referrerIcon = mapping.Name, mapping.Icon
+		}
+	}
+
 	referrer = shortenString(referrer, 200)
 	referrerName = shortenString(referrerName, 200)
 	referrerIcon = shortenString(referrerIcon, 2000)
 	screen := GetScreenClass(options.ScreenWidth)
 	utm := getUTMParams(r)
+	searchQuery := shortenString(getSearchQuery(requestURL, options.SearchQueryParameter), 200)
 	countryCode := ""
+	statusCode := options.StatusCode
+
+	if statusCode < 100 || statusCode > 599 {
+		statusCode = 0
+	}
+
+	method := shortenString(strings.ToUpper(strings.TrimSpace(options.Method)), 20)
 
 	if options.geoDB != nil {
 		countryCode = options.geoDB.CountryCode(getIP(r))
@@ -108,8 +204,7 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 	session := now
 
 	if options.Client != nil {
-		// hits and sessions use UTC
-		p, t, s, _ := options.Client.Session(options.ClientID, fingerprint, time.Now().UTC().Add(-options.SessionMaxAge))
+		p, t, s, _ := options.Client.Session(options.ClientID, fingerprint, now.Add(-options.SessionMaxAge))
 
 		if !t.IsZero() && p != path {
 			lastHitSeconds = int(now.Sub(t).Seconds())
@@ -117,6 +212,10 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 
 		if !s.IsZero() {
 			session = s
+
+			if options.EntryReferrerOnly {
+				referrer, referrerName, referrerIcon = "", "", ""
+			}
 		}
 	}
 
@@ -139,6 +238,8 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 		Path:                      path,
 		URL:                       requestURL,
 		Language:                  lang,
+		Locale:                    locale,
+		IPHash:                    ipHash,
 		CountryCode:               countryCode,
 		Referrer:                  referrer,
 		ReferrerName:              referrerName,
@@ -149,6 +250,7 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 		BrowserVersion:            uaInfo.BrowserVersion,
 		Desktop:                   uaInfo.IsDesktop(),
 		Mobile:                    uaInfo.IsMobile(),
+		Tablet:                    uaInfo.IsTablet(),
 		ScreenWidth:               options.ScreenWidth,
 		ScreenHeight:              options.ScreenHeight,
 		ScreenClass:               screen,
@@ -157,48 +259,62 @@ func HitFromRequest(r *http.Request, salt string, options *HitOptions) Hit {
 		UTMCampaign:               utm.campaign,
 		UTMContent:                utm.content,
 		UTMTerm:                   utm.term,
+		SearchQuery:               searchQuery,
+		StatusCode:                statusCode,
+		Method:                    method,
 	}
 }
 
 // IgnoreHit returns true, if a hit should be ignored for given request, or false otherwise.
 // The easiest way to track visitors is to use the Tracker.
 func IgnoreHit(r *http.Request) bool {
+	return ignoreHit(r, false)
+}
+
+func ignoreHit(r *http.Request, allowPrefetch bool) bool {
+	return ignoreHitReason(r, allowPrefetch) != notIgnored
+}
+
+// ignoreHitReason returns the reason a hit should be ignored for given request, or notIgnored otherwise.
+func ignoreHitReason(r *http.Request, allowPrefetch bool) ignoreReason {
 	// respect do not track header
 	if r.Header.Get("DNT") == "1" {
-		return true
+		return ignoredDoNotTrack
 	}
 
 	// empty User-Agents are usually bots
 	userAgent := strings.TrimSpace(strings.ToLower(r.Header.Get("User-Agent")))
 
 	if userAgent == "" {
-		return true
+		return ignoredBot
 	}
 
 	// ignore browsers pre-fetching data
-	xPurpose := r.Header.Get("X-Purpose")
-	purpose := r.Header.Get("Purpose")
-
-	if r.Header.Get("X-Moz") == "prefetch" ||
-		xPurpose == "prefetch" ||
-		xPurpose == "preview" ||
-		purpose == "prefetch" ||
-		purpose == "preview" {
-		return true
+	if !allowPrefetch && isPrefetch(r) {
+		return ignoredOther
 	}
 
 	// filter referrer spammers
 	if ignoreReferrer(r) {
-		return true
+		return ignoredOther
 	}
 
 	userAgentResult := ParseUserAgent(r.UserAgent())
 
 	if ignoreBrowserVersion(userAgentResult.Browser, userAgentResult.BrowserVersion) {
-		return true
+		return ignoredOther
 	}
 
 	// filter for bot keywords (most expensive operation last)
+	if isBotUserAgent(userAgent) {
+		return ignoredBot
+	}
+
+	return notIgnored
+}
+
+// isBotUserAgent returns true if given lowercase User-Agent contains a known bot keyword.
+func isBotUserAgent(userAgent string) bool {
 	for _, botUserAgent := range userAgentBlacklist {
 		if strings.Contains(userAgent, botUserAgent) {
 			return true
@@ -208,6 +324,24 @@ func IgnoreHit(r *http.Request) bool {
 	return false
 }
 
+// isPrefetch returns true if the request was sent by a browser or proxy to prefetch or prerender a page, instead of a visitor viewing it.
+// The Sec-Purpose header can contain multiple tokens, like "prefetch;prerender".
+func isPrefetch(r *http.Request) bool {
+	for _, header := range []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"} {
+		for _, purpose := range strings.FieldsFunc(strings.ToLower(r.Header.Get(header)), func(c rune) bool {
+			return c == ';' || c == ','
+		}) {
+			purpose = strings.TrimSpace(purpose)
+
+			if purpose == "prefetch" || purpose == "prerender" || purpose == "preview" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // HitOptionsFromRequest returns the HitOptions for given client request.
 // This function can be used to accept hits from pirsch.js. Invalid parameters are ignored and left empty.
 // You might want to add additional checks before calling HitFromRequest afterwards (like for the HitOptions.ClientID).
@@ -262,8 +396,32 @@ func getRequestURI(r *http.Request, options *HitOptions) {
 			options.URL = u.String()
 		} else {
 			options.Path = u.Path
+
+			if query := getSignificantQuery(u, options.SignificantQueryParams); query != "" {
+				options.Path += "?" + query
+			}
+		}
+	}
+}
+
+// returns the significant query parameters of the URL encoded in canonical order
+func getSignificantQuery(u *url.URL, params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	query := u.Query()
+	significant := make(url.Values)
+
+	for _, param := range params {
+		if values, found := query[param]; found {
+			values = append([]string{}, values...)
+			sort.Strings(values)
+			significant[param] = values
 		}
 	}
+
+	return significant.Encode()
 }
 
 func shortenString(str string, n int) string {
@@ -71,6 +71,44 @@ func TestHitFromRequestSession(t *testing.T) {
 	assert.NotEmpty(t, hit2.Fingerprint)
 	assert.Equal(t, 5, hit2.PreviousTimeOnPageSeconds)
 	assert.Equal(t, hit1.Session.Unix(), hit2.Session.Unix())
+	assert.Zero(t, hit1.Time.Nanosecond())
+	assert.Zero(t, hit1.Session.Nanosecond())
+	assert.True(t, hit1.Session.Equal(hit2.Session))
+}
+
+func TestHitFromRequestEntryReferrerOnly(t *testing.T) {
+	cleanupDB()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/84.0.4147.135 Safari/537.36")
+	req.Header.Set("Referer", "https://www.google.com/")
+	options := &HitOptions{
+		Client:            dbClient,
+		EntryReferrerOnly: true,
+	}
+	hit1 := HitFromRequest(req, "salt", options)
+	assert.Equal(t, "https://www.google.com/", hit1.Referrer)
+	assert.NoError(t, dbClient.SaveHits([]Hit{hit1}))
+	time.Sleep(time.Millisecond * 20)
+	hit2 := HitFromRequest(req, "salt", options)
+	assert.Equal(t, hit1.Session.Unix(), hit2.Session.Unix())
+	assert.Empty(t, hit2.Referrer)
+	assert.Empty(t, hit2.ReferrerName)
+	assert.Empty(t, hit2.ReferrerIcon)
+	hit3 := HitFromRequest(req, "salt", &HitOptions{Client: dbClient})
+	assert.Equal(t, "https://www.google.com/", hit3.Referrer)
+}
+
+func TestHitFromRequestIgnoreSelfReferrer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/page", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/84.0.4147.135 Safari/537.36")
+	req.Header.Set("Referer", "https://www.example.com/")
+	hit := HitFromRequest(req, "salt", &HitOptions{IgnoreSelfReferrer: true})
+	assert.Empty(t, hit.Referrer)
+	hit = HitFromRequest(req, "salt", nil)
+	assert.Equal(t, "https://www.example.com/", hit.Referrer)
+	req.Header.Set("Referer", "https://google.com/")
+	hit = HitFromRequest(req, "salt", &HitOptions{IgnoreSelfReferrer: true})
+	assert.Equal(t, "https://google.com/", hit.Referrer)
 }
 
 func TestHitFromRequestOverwrite(t *testing.T) {
@@ -100,6 +138,82 @@ func TestHitFromRequestOverwritePathAndReferrer(t *testing.T) {
 	}
 }
 
+func TestHitFromRequestSearchQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?q=Search+Term", nil)
+	req.Header.Set("User-Agent", "ua")
+	hit := HitFromRequest(req, "salt", nil)
+	assert.Empty(t, hit.SearchQuery)
+	hit = HitFromRequest(req, "salt", &HitOptions{SearchQueryParameter: "q"})
+	assert.Equal(t, "search term", hit.SearchQuery)
+	hit = HitFromRequest(req, "salt", &HitOptions{
+		URL:                  "https://example.com/find?query=foo",
+		SearchQueryParameter: "query",
+	})
+	assert.Equal(t, "foo", hit.SearchQuery)
+}
+
+func TestHitFromRequestSignificantQueryParams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/shop?ref=x&size=42&product=shoes&size=40", nil)
+	req.Header.Set("User-Agent", "ua")
+	hit := HitFromRequest(req, "salt", nil)
+	assert.Equal(t, "/shop", hit.Path)
+	options := &HitOptions{SignificantQueryParams: []string{"size", "product", "color"}}
+	hit = HitFromRequest(req, "salt", options)
+	assert.Equal(t, "/shop?product=shoes&size=40&size=42", hit.Path)
+	assert.Equal(t, "/shop?ref=x&size=42&product=shoes&size=40", hit.URL)
+	req = httptest.NewRequest(http.MethodGet, "/shop?size=40&size=42&product=shoes", nil)
+	req.Header.Set("User-Agent", "ua")
+	hit = HitFromRequest(req, "salt", &HitOptions{SignificantQueryParams: []string{"product", "size"}})
+	assert.Equal(t, "/shop?product=shoes&size=40&size=42", hit.Path)
+	req = httptest.NewRequest(http.MethodGet, "/shop?ref=x", nil)
+	req.Header.Set("User-Agent", "ua")
+	hit = HitFromRequest(req, "salt", &HitOptions{SignificantQueryParams: []string{"product"}})
+	assert.Equal(t, "/shop", hit.Path)
+	hit = HitFromRequest(req, "salt", &HitOptions{Path: "/custom", SignificantQueryParams: []string{"ref"}})
+	assert.Equal(t, "/custom", hit.Path)
+}
+
+func TestHitFromRequestUserID(t *testing.T) {
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.Header.Set("User-Agent", "ua1")
+	req2 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req2.Header.Set("User-Agent", "ua2")
+	assert.Equal(t, Fingerprint(req1, "salt"), HitFromRequest(req1, "salt", nil).Fingerprint)
+	hit1 := HitFromRequest(req1, "salt", &HitOptions{UserID: "42"})
+	hit2 := HitFromRequest(req2, "salt", &HitOptions{UserID: "42"})
+	assert.Equal(t, UserFingerprint("42", "salt"), hit1.Fingerprint)
+	assert.Equal(t, hit1.Fingerprint, hit2.Fingerprint)
+	assert.NotContains(t, hit1.String(), `"42"`)
+}
+
+func TestHitFromRequestStatusCode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "ua")
+	assert.Equal(t, 0, HitFromRequest(req, "salt", nil).StatusCode)
+	assert.Equal(t, 404, HitFromRequest(req, "salt", &HitOptions{StatusCode: 404}).StatusCode)
+	assert.Equal(t, 0, HitFromRequest(req, "salt", &HitOptions{StatusCode: 42}).StatusCode)
+	assert.Equal(t, 0, HitFromRequest(req, "salt", &HitOptions{StatusCode: 1000}).StatusCode)
+}
+
+func TestHitFromRequestMethod(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("User-Agent", "ua")
+	assert.Empty(t, HitFromRequest(req, "salt", nil).Method)
+	assert.Equal(t, http.MethodDelete, HitFromRequest(req, "salt", &HitOptions{Method: " delete "}).Method)
+}
+
+func TestHitFromRequestLocale(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "ua")
+	req.Header.Set("Accept-Language", "en-us, en;q=0.9")
+	hit := HitFromRequest(req, "salt", nil)
+	assert.Equal(t, "en", hit.Language)
+	assert.Empty(t, hit.Locale)
+	hit = HitFromRequest(req, "salt", &HitOptions{StoreLocale: true})
+	assert.Equal(t, "en", hit.Language)
+	assert.Equal(t, "en-US", hit.Locale)
+}
+
 func TestHitFromRequestScreenSize(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar/test/path?query=param&foo=bar#anchor", nil)
 	hit := HitFromRequest(req, "salt", &HitOptions{
@@ -196,6 +310,23 @@ func TestIgnoreHitPrefetch(t *testing.T) {
 	}
 
 	req.Header.Del("Purpose")
+	req.Header.Set("Sec-Purpose", "prefetch;prerender")
+
+	if !IgnoreHit(req) {
+		t.Fatal("Hit with Sec-Purpose header must be ignored")
+	}
+
+	if ignoreHit(req, true) {
+		t.Fatal("Hit with Sec-Purpose header must not be ignored if prefetching is allowed")
+	}
+
+	req.Header.Set("Sec-Purpose", "Prefetch")
+
+	if !IgnoreHit(req) {
+		t.Fatal("Hit with Sec-Purpose header must be ignored")
+	}
+
+	req.Header.Del("Sec-Purpose")
 
 	if IgnoreHit(req) {
 		t.Fatal("Hit must not be ignored")
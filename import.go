@@ -0,0 +1,235 @@
+package pirsch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportFormat is the file format read by ImportHits.
+type ImportFormat int
+
+const (
+	// ImportCSV reads hits from a CSV file.
+	// The first line is a header containing the column names, as stored in the database (fingerprint, time, path, country_code, ...).
+	// Times must be formatted as RFC3339, booleans as true/false or 1/0. Columns that are not present are left empty.
+	ImportCSV ImportFormat = iota
+
+	// ImportJSONL reads hits from a JSON lines file.
+	// Each line is a single hit in the format returned by Hit.String. Empty lines are skipped.
+	ImportJSONL
+)
+
+const importBatchSize = 1000
+
+var (
+	// ErrUnknownImportFormat is returned by ImportHits in case the format is not supported.
+	ErrUnknownImportFormat = errors.New("unknown import format")
+
+	// ErrMissingImportField is returned by ImportHits in case a hit is missing the fingerprint, time, or path.
+	ErrMissingImportField = errors.New("fingerprint, time, and path are required")
+)
+
+// ImportHits reads historical hits from given reader and saves them in batches.
+// The time of each hit is preserved, but truncated to seconds and converted to UTC.
+// The session is set to the time of the hit if it is missing.
+// Errors include the line number of the hit that could not be parsed.
+// Batches saved before an error occurred are not rolled back.
+func ImportHits(store Store, r io.Reader, format ImportFormat) error {
+	switch format {
+	case ImportCSV:
+		return importCSV(store, r)
+	case ImportJSONL:
+		return importJSONL(store, r)
+	default:
+		return ErrUnknownImportFormat
+	}
+}
+
+func importCSV(store Store, r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+
+	if err != nil {
+		return fmt.Errorf("line 1: %w", err)
+	}
+
+	columns := importColumns()
+	fields := make([]int, len(header))
+
+	for i, name := range header {
+		index, found := columns[strings.ToLower(strings.TrimSpace(name))]
+
+		if !found {
+			return fmt.Errorf("line 1: unknown column %q", name)
+		}
+
+		fields[i] = index
+	}
+
+	batch := make([]Hit, 0, importBatchSize)
+	line := 1
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		line++
+
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var hit Hit
+		value := reflect.ValueOf(&hit).Elem()
+
+		for i, v := range record {
+			if err := setImportField(value.Field(fields[i]), v); err != nil {
+				return fmt.Errorf("line %d: column %q: %w", line, header[i], err)
+			}
+		}
+
+		if batch, err = appendImportHit(store, batch, hit); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	return saveImportBatch(store, batch)
+}
+
+func importJSONL(store Store, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	batch := make([]Hit, 0, importBatchSize)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		data := strings.TrimSpace(scanner.Text())
+
+		if data == "" {
+			continue
+		}
+
+		var hit Hit
+
+		if err := json.Unmarshal([]byte(data), &hit); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var err error
+
+		if batch, err = appendImportHit(store, batch, hit); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line+1, err)
+	}
+
+	return saveImportBatch(store, batch)
+}
+
+// validates and adds the hit to the batch, which is saved once it's full
+func appendImportHit(store Store, batch []Hit, hit Hit) ([]Hit, error) {
+	if hit.Fingerprint == "" || hit.Time.IsZero() || hit.Path == "" {
+		return batch, ErrMissingImportField
+	}
+
+	hit.Time = hit.Time.UTC().Truncate(time.Second)
+
+	if hit.Session.IsZero() {
+		hit.Session = hit.Time
+	} else {
+		hit.Session = hit.Session.UTC().Truncate(time.Second)
+	}
+
+	batch = append(batch, hit)
+
+	if len(batch) >= importBatchSize {
+		if err := saveImportBatch(store, batch); err != nil {
+			return batch, err
+		}
+
+		batch = batch[:0]
+	}
+
+	return batch, nil
+}
+
+func saveImportBatch(store Store, batch []Hit) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return store.SaveHits(batch)
+}
+
+// maps the column names to the Hit field index
+func importColumns() map[string]int {
+	t := reflect.TypeOf(Hit{})
+	columns := make(map[string]int)
+
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("db")
+
+		if name == "" {
+			name = strings.ToLower(t.Field(i).Name)
+		}
+
+		columns[name] = i
+	}
+
+	return columns
+}
+
+func setImportField(field reflect.Value, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case int, int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(i)
+	case bool:
+		b, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(b)
+	case time.Time:
+		t, err := time.Parse(time.RFC3339, value)
+
+		if err != nil {
+			return err
+		}
+
+		field.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
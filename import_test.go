@@ -0,0 +1,61 @@
+package pirsch
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportHitsCSV(t *testing.T) {
+	input := `fingerprint,time,path,country_code,desktop,screen_width
+fp1,2021-06-01T10:15:30.5Z,/,de,true,1920
+fp2,2021-06-01T12:00:00+02:00,/foo,,0,
+`
+	client := NewMockClient()
+	assert.NoError(t, ImportHits(client, strings.NewReader(input), ImportCSV))
+	assert.Len(t, client.Hits, 2)
+	assert.Equal(t, "fp1", client.Hits[0].Fingerprint)
+	assert.Equal(t, time.Date(2021, 6, 1, 10, 15, 30, 0, time.UTC), client.Hits[0].Time)
+	assert.Equal(t, client.Hits[0].Time, client.Hits[0].Session)
+	assert.Equal(t, "/", client.Hits[0].Path)
+	assert.Equal(t, "de", client.Hits[0].CountryCode)
+	assert.True(t, client.Hits[0].Desktop)
+	assert.Equal(t, 1920, client.Hits[0].ScreenWidth)
+	assert.Equal(t, time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC), client.Hits[1].Time)
+	assert.False(t, client.Hits[1].Desktop)
+	err := ImportHits(client, strings.NewReader("fingerprint,time,path\nfp1,2021-06-01T10:15:30Z,/\nfp2,,/\n"), ImportCSV)
+	assert.True(t, errors.Is(err, ErrMissingImportField))
+	assert.Contains(t, err.Error(), "line 3")
+	err = ImportHits(client, strings.NewReader("fingerprint,time,path\nfp1,yesterday,/\n"), ImportCSV)
+	assert.Contains(t, err.Error(), "line 2")
+	err = ImportHits(client, strings.NewReader("fingerprint,unknown\n"), ImportCSV)
+	assert.Contains(t, err.Error(), "unknown column")
+	assert.Len(t, client.Hits, 2)
+}
+
+func TestImportHitsJSONL(t *testing.T) {
+	hit := Hit{
+		Fingerprint: "fp1",
+		Time:        time.Date(2021, 6, 1, 10, 15, 30, 0, time.UTC),
+		Session:     time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+		Path:        "/",
+		Mobile:      true,
+	}
+	var input strings.Builder
+
+	for i := 0; i < importBatchSize+1; i++ {
+		input.WriteString(hit.String())
+		input.WriteString("\n\n")
+	}
+
+	client := NewMockClient()
+	assert.NoError(t, ImportHits(client, strings.NewReader(input.String()), ImportJSONL))
+	assert.Len(t, client.Hits, importBatchSize+1)
+	assert.Equal(t, hit, client.Hits[0])
+	err := ImportHits(client, strings.NewReader(fmt.Sprintf("%s\n{invalid", hit.String())), ImportJSONL)
+	assert.Contains(t, err.Error(), "line 2")
+	assert.Equal(t, ErrUnknownImportFormat, ImportHits(client, strings.NewReader(""), ImportFormat(42)))
+}
@@ -17,6 +17,18 @@ var ipHeaders = []ipHeader{
 	{"X-Real-IP", parseXRealIPHeader},
 }
 
+// privateIPRanges are the loopback, link-local, and private (RFC 1918 and RFC 4193) address ranges.
+var privateIPRanges = parseCIDRs(
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
 type ipHeader struct {
 	header string
 	parser func(string) string
@@ -81,3 +93,30 @@ func parseXForwardedForHeader(value string) string {
 func parseXRealIPHeader(value string) string {
 	return value
 }
+
+// isPrivateIP returns whether given IP is a loopback, link-local, or private address.
+func isPrivateIP(ip net.IP) bool {
+	for _, ipRange := range privateIPRanges {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	ranges := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, ipRange, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			panic(err)
+		}
+
+		ranges = append(ranges, ipRange)
+	}
+
+	return ranges
+}
@@ -2,6 +2,7 @@ package pirsch
 
 import (
 	"github.com/stretchr/testify/assert"
+	"net"
 	"net/http/httptest"
 	"testing"
 )
@@ -73,3 +74,13 @@ func TestGetIP(t *testing.T) {
 	r.Header.Set("CF-Connecting-IP", "127.0.0.1, 23.21.45.67")
 	assert.Equal(t, "127.0.0.1", getIP(r))
 }
+
+func TestIsPrivateIP(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "172.31.255.255", "192.168.1.1", "169.254.1.1", "::1", "fd00::1", "fe80::1"} {
+		assert.True(t, isPrivateIP(net.ParseIP(ip)), ip)
+	}
+
+	for _, ip := range []string{"81.2.69.142", "172.32.0.1", "8.8.8.8", "2001:4860:4860::8888"} {
+		assert.False(t, isPrivateIP(net.ParseIP(ip)), ip)
+	}
+}
@@ -22,3 +22,23 @@ func getLanguage(r *http.Request) string {
 
 	return ""
 }
+
+// getLocale returns the first locale from the Accept-Language header (like en-US).
+// Valid language-region pairs are formatted as language-REGION, everything else is returned as is.
+func getLocale(r *http.Request) string {
+	lang := r.Header.Get("Accept-Language")
+
+	if lang == "" {
+		return ""
+	}
+
+	locale := strings.Split(lang, ";")[0]
+	locale = strings.TrimSpace(strings.Split(locale, ",")[0])
+	parts := strings.Split(locale, "-")
+
+	if len(parts) == 2 && iso6391.ValidCode(strings.ToLower(parts[0])) && len(parts[1]) == 2 {
+		return strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1])
+	}
+
+	return locale
+}
@@ -33,3 +33,35 @@ func TestGetLanguage(t *testing.T) {
 		}
 	}
 }
+
+func TestGetLocale(t *testing.T) {
+	input := []string{
+		"",
+		"  \t ",
+		"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5",
+		"en-us, en",
+		"en-GB;q=0.9",
+		"de",
+		"invalid",
+		"zh-Hant-TW",
+	}
+	expected := []string{
+		"",
+		"",
+		"fr-CH",
+		"en-US",
+		"en-GB",
+		"de",
+		"invalid",
+		"zh-Hant-TW",
+	}
+
+	for i, in := range input {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", in)
+
+		if locale := getLocale(req); locale != expected[i] {
+			t.Fatalf("Expected '%v', but was: %v", expected[i], locale)
+		}
+	}
+}
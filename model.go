@@ -2,10 +2,12 @@ package pirsch
 
 import (
 	"encoding/json"
+	"math"
 	"time"
 )
 
 // Hit represents a single data point/page visit and is the central entity of Pirsch.
+// Time and Session are in UTC and truncated to seconds, which is the precision stored in the database.
 type Hit struct {
 	ClientID                  int64 `db:"client_id"`
 	Fingerprint               string
@@ -26,6 +28,7 @@ type Hit struct {
 	BrowserVersion            string `db:"browser_version"`
 	Desktop                   bool
 	Mobile                    bool
+	Tablet                    bool
 	ScreenWidth               int    `db:"screen_width"`
 	ScreenHeight              int    `db:"screen_height"`
 	ScreenClass               string `db:"screen_class"`
@@ -34,6 +37,11 @@ type Hit struct {
 	UTMCampaign               string `db:"utm_campaign"`
 	UTMContent                string `db:"utm_content"`
 	UTMTerm                   string `db:"utm_term"`
+	SearchQuery               string `db:"search_query"`
+	StatusCode                int    `db:"status_code"`
+	Method                    string
+	Locale                    string
+	IPHash                    string `db:"ip_hash"`
 }
 
 // String implements the Stringer interface.
@@ -50,6 +58,8 @@ type Event struct {
 	DurationSeconds int      `db:"event_duration_seconds" json:"duration_seconds"`
 	MetaKeys        []string `db:"event_meta_keys" json:"meta_keys"`
 	MetaValues      []string `db:"event_meta_values" json:"meta_values"`
+	Value           float64  `db:"event_value" json:"value"`
+	HasValue        bool     `db:"event_has_value" json:"has_value"`
 }
 
 // String implements the Stringer interface.
@@ -74,6 +84,81 @@ type VisitorStats struct {
 	BounceRate float64   `db:"bounce_rate" json:"bounce_rate"`
 }
 
+// VisitorIntervalStats is the result type for visitor statistics grouped by an interval.
+type VisitorIntervalStats struct {
+	Start    time.Time `json:"start"`
+	Visitors int       `json:"visitors"`
+}
+
+// HitsPerDayStats is the result type for the total number of hits per day.
+type HitsPerDayStats struct {
+	Day  time.Time `json:"day"`
+	Hits int       `json:"hits"`
+}
+
+// WeekdayWeekendStats is the result type for the weekday and weekend comparison.
+type WeekdayWeekendStats struct {
+	Weekday DayTypeStats `json:"weekday"`
+	Weekend DayTypeStats `json:"weekend"`
+}
+
+// DayTypeStats is the result type for the visitor statistics on either weekdays or weekends.
+type DayTypeStats struct {
+	Days        int     `json:"days"`
+	Visitors    int     `json:"visitors"`
+	Sessions    int     `json:"sessions"`
+	Bounces     int     `json:"bounces"`
+	BounceRate  float64 `json:"bounce_rate"`
+	AvgVisitors float64 `json:"avg_visitors"`
+	AvgSessions float64 `json:"avg_sessions"`
+	AvgBounces  float64 `json:"avg_bounces"`
+}
+
+// EngagementStats is the result type for the engagement rate.
+type EngagementStats struct {
+	Sessions        int                  `json:"sessions"`
+	EngagedSessions int                  `json:"engaged_sessions"`
+	EngagementRate  float64              `json:"engagement_rate"`
+	Days            []EngagementDayStats `json:"days"`
+}
+
+// EngagementDayStats is the result type for the engagement rate on a single day.
+type EngagementDayStats struct {
+	Day             time.Time `json:"day"`
+	Sessions        int       `json:"sessions"`
+	EngagedSessions int       `db:"engaged_sessions" json:"engaged_sessions"`
+	EngagementRate  float64   `db:"engagement_rate" json:"engagement_rate"`
+}
+
+// AnomalyStats is the result type for days with an unusual visitor count.
+type AnomalyStats struct {
+	Day      time.Time `json:"day"`
+	Visitors int       `json:"visitors"`
+	Mean     float64   `json:"mean"`
+	StdDev   float64   `json:"std_dev"`
+	ZScore   float64   `json:"z_score"`
+}
+
+// NewReturningStats is the result type for new and returning visitor statistics grouped by day.
+type NewReturningStats struct {
+	Day               time.Time `json:"day"`
+	Visitors          int       `json:"visitors"`
+	NewVisitors       int       `db:"new_visitors" json:"new_visitors"`
+	ReturningVisitors int       `db:"returning_visitors" json:"returning_visitors"`
+}
+
+// TotalVisitorStats is the result type for the total visitor statistics of a period.
+// Previous and Growth are only set if the filter is configured to compare periods.
+type TotalVisitorStats struct {
+	Visitors         int                `json:"visitors"`
+	Views            int                `json:"views"`
+	Sessions         int                `json:"sessions"`
+	Bounces          int                `json:"bounces"`
+	TimeSpentSeconds int                `json:"time_spent_seconds"`
+	Previous         *TotalVisitorStats `json:"previous,omitempty"`
+	Growth           *Growth            `json:"growth,omitempty"`
+}
+
 // Growth represents the visitors, views, sessions, bounces, and average session duration growth between two time periods.
 type Growth struct {
 	VisitorsGrowth  float64 `json:"visitors_growth"`
@@ -83,12 +168,58 @@ type Growth struct {
 	TimeSpentGrowth float64 `json:"time_spent_growth"`
 }
 
+// SessionsPerVisitorStats is the result type for the average number of sessions per visitor.
+type SessionsPerVisitorStats struct {
+	Visitors     int                 `json:"visitors"`
+	Sessions     int                 `json:"sessions"`
+	Average      float64             `json:"average"`
+	Distribution []SessionCountStats `json:"distribution"`
+}
+
+// SessionCountStats is the result type for the number of visitors having a session count.
+type SessionCountStats struct {
+	Sessions int `json:"sessions"`
+	Visitors int `json:"visitors"`
+}
+
+// VisitDepthStats is the result type for the number of page views per session.
+type VisitDepthStats struct {
+	Depth    int `json:"depth"`
+	Sessions int `json:"sessions"`
+	Visitors int `json:"visitors"`
+}
+
 // VisitorHourStats is the result type for visitor statistics grouped by time of day.
 type VisitorHourStats struct {
 	Hour     int `json:"hour"`
 	Visitors int `json:"visitors"`
 }
 
+// PeakStats is the result type for the hour and day with the most visitors.
+type PeakStats struct {
+	Hour         time.Time `json:"hour"`
+	HourVisitors int       `json:"hour_visitors"`
+	Day          time.Time `json:"day"`
+	DayVisitors  int       `json:"day_visitors"`
+}
+
+// TimeOfDayVisitors is the result type for the visitor count grouped by hour for a single day.
+type TimeOfDayVisitors struct {
+	Day   time.Time          `json:"day"`
+	Stats []VisitorHourStats `json:"stats"`
+}
+
+// SessionStats is the result type for a single session.
+// The SessionID is an anonymized identifier that does not contain the fingerprint.
+type SessionStats struct {
+	SessionID       string    `db:"session_id" json:"session_id"`
+	Session         time.Time `json:"session"`
+	Views           int       `json:"views"`
+	DurationSeconds int       `db:"duration_seconds" json:"duration_seconds"`
+	EntryPath       string    `db:"entry_path" json:"entry_path"`
+	ExitPath        string    `db:"exit_path" json:"exit_path"`
+}
+
 // PageStats is the result type for page statistics.
 type PageStats struct {
 	Path                    string  `json:"path"`
@@ -102,6 +233,113 @@ type PageStats struct {
 	AverageTimeSpentSeconds int     `db:"average_time_spent_seconds" json:"average_time_spent_seconds"`
 }
 
+// TopItemStats is the result type for the top entry of the pages, referrers, countries, and browsers.
+type TopItemStats struct {
+	Page     *PageStats     `json:"page"`
+	Referrer *ReferrerStats `json:"referrer"`
+	Country  *CountryStats  `json:"country"`
+	Browser  *BrowserStats  `json:"browser"`
+}
+
+// StatusCodeStats is the result type for HTTP status code statistics.
+type StatusCodeStats struct {
+	StatusCode int `db:"status_code" json:"status_code"`
+	Visitors   int `json:"visitors"`
+	Views      int `json:"views"`
+}
+
+// TimeWindow is a period of time from (inclusive) to (exclusive).
+type TimeWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// PageVisitorStats is the result type for the visitors of a path per day.
+type PageVisitorStats struct {
+	Path string         `json:"path"`
+	Days []PageDayStats `json:"days"`
+}
+
+// PageDayStats is the result type for a single day of PageVisitorStats.
+type PageDayStats struct {
+	Day      time.Time `json:"day"`
+	Visitors int       `json:"visitors"`
+	Views    int       `json:"views"`
+}
+
+// PageDayMatrix is the result type for the visitors per path and day, as returned by Analyzer.PageDayMatrix.
+// Visitors holds a row for each path, with a column for each day.
+type PageDayMatrix struct {
+	Paths    []string    `json:"paths"`
+	Days     []time.Time `json:"days"`
+	Visitors [][]int     `json:"visitors"`
+}
+
+// Get returns the number of visitors for given path and day, or zero if the path or day is not part of the matrix.
+func (matrix *PageDayMatrix) Get(path string, day time.Time) int {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	for i, p := range matrix.Paths {
+		if p == path {
+			for j, d := range matrix.Days {
+				if d.UTC().Equal(day) {
+					return matrix.Visitors[i][j]
+				}
+			}
+
+			break
+		}
+	}
+
+	return 0
+}
+
+// EngagementScoreWeights are the weights used to calculate the score in Analyzer.PageEngagementScore.
+// Higher weights make a metric more important, zero ignores it. Weights must not be negative.
+type EngagementScoreWeights struct {
+	Visitors   float64
+	Bounce     float64
+	TimeOnPage float64
+}
+
+func (weights *EngagementScoreWeights) valid() bool {
+	for _, w := range []float64{weights.Visitors, weights.Bounce, weights.TimeOnPage} {
+		// a negative weight results in an infinite score for metrics of zero
+		if w < 0 || math.IsNaN(w) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PageEngagementStats is the result type for the engagement score of a path, including the metrics it is calculated from.
+type PageEngagementStats struct {
+	Path                    string  `json:"path"`
+	Visitors                int     `json:"visitors"`
+	BounceRate              float64 `json:"bounce_rate"`
+	AverageTimeSpentSeconds int     `json:"average_time_spent_seconds"`
+	Score                   float64 `json:"score"`
+}
+
+// PageMoverStats is the result type for the change in visitors of a path compared to the previous period.
+type PageMoverStats struct {
+	Path             string  `json:"path"`
+	Visitors         int     `json:"visitors"`
+	PreviousVisitors int     `json:"previous_visitors"`
+	Change           int     `json:"change"`
+	RelativeChange   float64 `json:"relative_change"`
+	New              bool    `json:"new"`
+	Removed          bool    `json:"removed"`
+}
+
+// MethodStats is the result type for HTTP method statistics.
+type MethodStats struct {
+	Method   string `json:"method"`
+	Visitors int    `json:"visitors"`
+	Views    int    `json:"views"`
+}
+
 // EntryStats is the result type for entry page statistics.
 type EntryStats struct {
 	Path                    string `json:"path"`
@@ -125,6 +363,28 @@ type PageConversionsStats struct {
 	CR       float64 `json:"cr"`
 }
 
+// EngagedTimeStats is the result type for the time visitors engaged with the site, as reported by heartbeats.
+type EngagedTimeStats struct {
+	Sessions              int                    `json:"sessions"`
+	AverageEngagedSeconds int                    `db:"average_engaged_seconds" json:"average_engaged_seconds"`
+	Pages                 []PageEngagedTimeStats `json:"pages"`
+}
+
+// PageEngagedTimeStats is the result type for the time visitors engaged with a page, as reported by heartbeats.
+type PageEngagedTimeStats struct {
+	Path                  string `json:"path"`
+	Sessions              int    `json:"sessions"`
+	AverageEngagedSeconds int    `db:"average_engaged_seconds" json:"average_engaged_seconds"`
+}
+
+// EventValueStats is the result type for the numeric values of an event.
+// Count is the number of events having a value.
+type EventValueStats struct {
+	Count   int     `json:"count"`
+	Total   float64 `json:"total"`
+	Average float64 `json:"average"`
+}
+
 // EventStats is the result type for custom events.
 type EventStats struct {
 	Name                   string   `db:"event_name" json:"name"`
@@ -147,16 +407,70 @@ type ReferrerStats struct {
 	BounceRate       float64 `db:"bounce_rate" json:"bounce_rate"`
 }
 
+// EntryReferrerStats is the result type for the referrers of sessions that started on a page.
+type EntryReferrerStats struct {
+	Referrer     string `json:"referrer"`
+	ReferrerName string `db:"referrer_name" json:"referrer_name"`
+	ReferrerIcon string `db:"referrer_icon" json:"referrer_icon"`
+	Visitors     int    `json:"visitors"`
+	Entries      int    `json:"entries"`
+}
+
+// LandingConversionStats is the result type for the conversion rate of sessions by entry page.
+type LandingConversionStats struct {
+	Path        string  `json:"path"`
+	Sessions    int     `json:"sessions"`
+	Conversions int     `json:"conversions"`
+	CR          float64 `json:"cr"`
+}
+
+// ReferrerBounceStats is the result type for the bounce rate of sessions grouped by referrer.
+type ReferrerBounceStats struct {
+	Referrer     string  `json:"referrer"`
+	ReferrerName string  `db:"referrer_name" json:"referrer_name"`
+	ReferrerIcon string  `db:"referrer_icon" json:"referrer_icon"`
+	Visitors     int     `json:"visitors"`
+	Sessions     int     `json:"sessions"`
+	Bounces      int     `json:"bounces"`
+	BounceRate   float64 `db:"bounce_rate" json:"bounce_rate"`
+}
+
+// ReferrerVisitorTypeStats is the result type for referrers split into new and returning visitors.
+type ReferrerVisitorTypeStats struct {
+	Referrer          string `json:"referrer"`
+	ReferrerName      string `db:"referrer_name" json:"referrer_name"`
+	ReferrerIcon      string `db:"referrer_icon" json:"referrer_icon"`
+	Visitors          int    `json:"visitors"`
+	NewVisitors       int    `db:"new_visitors" json:"new_visitors"`
+	ReturningVisitors int    `db:"returning_visitors" json:"returning_visitors"`
+}
+
 // PlatformStats is the result type for platform statistics.
 type PlatformStats struct {
 	PlatformDesktop         int     `db:"platform_desktop" json:"platform_desktop"`
 	PlatformMobile          int     `db:"platform_mobile" json:"platform_mobile"`
+	PlatformTablet          int     `db:"platform_tablet" json:"platform_tablet"`
 	PlatformUnknown         int     `db:"platform_unknown" json:"platform_unknown"`
 	RelativePlatformDesktop float64 `db:"relative_platform_desktop" json:"relative_platform_desktop"`
 	RelativePlatformMobile  float64 `db:"relative_platform_mobile" json:"relative_platform_mobile"`
+	RelativePlatformTablet  float64 `db:"relative_platform_tablet" json:"relative_platform_tablet"`
 	RelativePlatformUnknown float64 `db:"relative_platform_unknown" json:"relative_platform_unknown"`
 }
 
+// SearchTermStats is the result type for site search statistics.
+type SearchTermStats struct {
+	SearchQuery string `db:"search_query" json:"search_query"`
+	Searches    int    `json:"searches"`
+	Visitors    int    `json:"visitors"`
+}
+
+// PlatformTrendStats is the result type for platform statistics grouped by day.
+type PlatformTrendStats struct {
+	PlatformStats
+
+	Day time.Time `json:"day"`
+}
+
 // TimeSpentStats is the result type for average time spent statistics (sessions, time on page).
 type TimeSpentStats struct {
 	Day                     time.Time `json:"day"`
@@ -164,6 +478,26 @@ type TimeSpentStats struct {
 	AverageTimeSpentSeconds int       `db:"average_time_spent_seconds" json:"average_time_spent_seconds"`
 }
 
+// ComparisonStats is the result type for the visitor count of an attribute value (browser, country, referrer, ...) in two periods.
+type ComparisonStats struct {
+	Key              string `json:"key"`
+	Visitors         int    `json:"visitors"`
+	PreviousVisitors int    `db:"previous_visitors" json:"previous_visitors"`
+}
+
+// TrendStats is the result type for the share of visitors of an attribute value (browser, operating system, ...) over time.
+type TrendStats struct {
+	Name string          `json:"name"`
+	Days []TrendDayStats `json:"days"`
+}
+
+// TrendDayStats is the result type for a single day of TrendStats.
+type TrendDayStats struct {
+	Day              time.Time `json:"day"`
+	Visitors         int       `json:"visitors"`
+	RelativeVisitors float64   `json:"relative_visitors"`
+}
+
 // MetaStats is the base for meta result types (languages, countries, ...).
 type MetaStats struct {
 	Visitors         int     `json:"visitors"`
@@ -176,6 +510,12 @@ type LanguageStats struct {
 	Language string `json:"language"`
 }
 
+// LocaleStats is the result type for locale statistics.
+type LocaleStats struct {
+	MetaStats
+	Locale string `json:"locale"`
+}
+
 // CountryStats is the result type for country statistics.
 type CountryStats struct {
 	MetaStats
@@ -214,12 +554,38 @@ type ScreenClassStats struct {
 	ScreenClass string `db:"screen_class" json:"screen_class"`
 }
 
+// ScreenPercentileStats is the result type for the screen size percentiles across visitors.
+type ScreenPercentileStats struct {
+	Visitors  int `json:"visitors"`
+	WidthP50  int `db:"width_p50" json:"width_p50"`
+	WidthP75  int `db:"width_p75" json:"width_p75"`
+	WidthP90  int `db:"width_p90" json:"width_p90"`
+	WidthP95  int `db:"width_p95" json:"width_p95"`
+	HeightP50 int `db:"height_p50" json:"height_p50"`
+	HeightP75 int `db:"height_p75" json:"height_p75"`
+	HeightP90 int `db:"height_p90" json:"height_p90"`
+	HeightP95 int `db:"height_p95" json:"height_p95"`
+}
+
+// ChannelStats is the result type for channel statistics.
+type ChannelStats struct {
+	MetaStats
+	Channel string `json:"channel"`
+}
+
 // UTMSourceStats is the result type for utm source statistics.
 type UTMSourceStats struct {
 	MetaStats
 	UTMSource string `db:"utm_source" json:"utm_source"`
 }
 
+// UTMSourceMediumStats is the result type for utm source and medium pair statistics.
+type UTMSourceMediumStats struct {
+	MetaStats
+	UTMSource string `db:"source" json:"utm_source"`
+	UTMMedium string `db:"medium" json:"utm_medium"`
+}
+
 // UTMMediumStats is the result type for utm medium statistics.
 type UTMMediumStats struct {
 	MetaStats
@@ -243,3 +609,10 @@ type UTMTermStats struct {
 	MetaStats
 	UTMTerm string `db:"utm_term" json:"utm_term"`
 }
+
+// StatsWithTotal is the result type for breakdowns including the total number of unique visitors.
+// Stats holds the result of the breakdown, like []PageStats for Analyzer.PagesWithTotal.
+type StatsWithTotal struct {
+	Stats    interface{} `json:"stats"`
+	Visitors int         `json:"visitors"`
+}
@@ -0,0 +1,131 @@
+package pirsch
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the number of hits and events a client can send to the Tracker.
+// It's implemented as a token bucket, which is refilled with Rate tokens per second and holds up to Burst tokens.
+type RateLimit struct {
+	// Rate is the number of hits and events per second a client can send on average.
+	// Zero or less disables the limit.
+	Rate float64
+
+	// Burst is the maximum number of hits and events that are accepted at once.
+	// It will be set to the rate (rounded up) if it's less than that.
+	Burst int
+}
+
+func (limit RateLimit) burst() float64 {
+	if float64(limit.Burst) < limit.Rate {
+		return math.Ceil(limit.Rate)
+	}
+
+	return float64(limit.Burst)
+}
+
+// rateLimiterSweepInterval is the interval in which full buckets are removed from the rateLimiter.
+const rateLimiterSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket for each client ID.
+// Buckets that have been refilled to the burst are removed periodically, as they equal a new bucket,
+// so that the client IDs sent to the Tracker (like from the query string) don't grow the map without limit.
+type rateLimiter struct {
+	defaultLimit RateLimit
+	limits       map[int64]RateLimit
+	buckets      map[int64]*tokenBucket
+	lastSweep    time.Time
+	now          func() time.Time
+	m            sync.Mutex
+}
+
+func newRateLimiter(defaultLimit RateLimit, limits map[int64]RateLimit) *rateLimiter {
+	if defaultLimit.Rate <= 0 && len(limits) == 0 {
+		return nil
+	}
+
+	clientLimits := make(map[int64]RateLimit, len(limits))
+
+	for clientID, limit := range limits {
+		clientLimits[clientID] = limit
+	}
+
+	return &rateLimiter{
+		defaultLimit: defaultLimit,
+		limits:       clientLimits,
+		buckets:      make(map[int64]*tokenBucket),
+		lastSweep:    time.Now(),
+		now:          time.Now,
+	}
+}
+
+// allow takes a token from the bucket for given client ID and returns false if it's empty.
+func (limiter *rateLimiter) allow(clientID int64) bool {
+	if limiter == nil {
+		return true
+	}
+
+	limit := limiter.limit(clientID)
+
+	if limit.Rate <= 0 {
+		return true
+	}
+
+	limiter.m.Lock()
+	defer limiter.m.Unlock()
+	now := limiter.now()
+
+	if now.Sub(limiter.lastSweep) >= rateLimiterSweepInterval {
+		limiter.sweep(now)
+	}
+
+	burst := limit.burst()
+	bucket, found := limiter.buckets[clientID]
+
+	if !found {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		limiter.buckets[clientID] = bucket
+	} else {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * limit.Rate
+		bucket.last = now
+
+		if bucket.tokens > burst {
+			bucket.tokens = burst
+		}
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+func (limiter *rateLimiter) limit(clientID int64) RateLimit {
+	if limit, found := limiter.limits[clientID]; found {
+		return limit
+	}
+
+	return limiter.defaultLimit
+}
+
+// sweep removes all buckets that have been refilled to the burst. The mutex must be locked.
+func (limiter *rateLimiter) sweep(now time.Time) {
+	for clientID, bucket := range limiter.buckets {
+		limit := limiter.limit(clientID)
+
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= limit.burst() {
+			delete(limiter.buckets, clientID)
+		}
+	}
+
+	limiter.lastSweep = now
+}
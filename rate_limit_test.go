@@ -0,0 +1,65 @@
+package pirsch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(RateLimit{}, nil))
+	limiter := newRateLimiter(RateLimit{Rate: 1, Burst: 2}, map[int64]RateLimit{
+		42: {Rate: 0.5},
+		43: {},
+	})
+	now := time.Now()
+	limiter.now = func() time.Time {
+		return now
+	}
+	assert.True(t, limiter.allow(1))
+	assert.True(t, limiter.allow(1))
+	assert.False(t, limiter.allow(1))
+	assert.True(t, limiter.allow(2))
+	assert.True(t, limiter.allow(42))
+	assert.False(t, limiter.allow(42))
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.allow(43))
+	}
+
+	now = now.Add(time.Second)
+	assert.True(t, limiter.allow(1))
+	assert.False(t, limiter.allow(1))
+	assert.False(t, limiter.allow(42))
+	now = now.Add(time.Second * 10)
+	assert.True(t, limiter.allow(1))
+	assert.True(t, limiter.allow(1))
+	assert.False(t, limiter.allow(1))
+	assert.True(t, limiter.allow(42))
+	var nilLimiter *rateLimiter
+	assert.True(t, nilLimiter.allow(1))
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{Rate: 1, Burst: 10}, map[int64]RateLimit{
+		1: {Rate: 0.001, Burst: 1},
+	})
+	now := time.Now()
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	for i := int64(1); i <= 100; i++ {
+		assert.True(t, limiter.allow(i))
+	}
+
+	assert.Len(t, limiter.buckets, 100)
+	now = now.Add(rateLimiterSweepInterval / 2)
+	assert.True(t, limiter.allow(2))
+	assert.Len(t, limiter.buckets, 100)
+	now = now.Add(rateLimiterSweepInterval / 2)
+	assert.True(t, limiter.allow(2))
+	assert.Len(t, limiter.buckets, 2)
+	assert.Contains(t, limiter.buckets, int64(1))
+	assert.False(t, limiter.allow(1))
+}
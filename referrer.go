@@ -14,6 +14,12 @@ const (
 	googlePlayStoreURL = "https://play.google.com/store/apps/details?id=%s"
 )
 
+// ReferrerMapping is the name and icon stored for a referrer host.
+type ReferrerMapping struct {
+	Name string
+	Icon string
+}
+
 var referrerQueryParams = []string{
 	"ref",
 	"referer",
@@ -98,6 +104,42 @@ func getReferrer(r *http.Request, ref string, domainBlacklist []string, ignoreSu
 	return u.String(), "", ""
 }
 
+func getReferrerHostname(referrer string) string {
+	u, err := url.ParseRequestURI(referrer)
+
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(referrer))
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// isSelfReferrer returns true if the referrer host equals the host of the page URL, or the request host if the URL has none.
+// The www subdomain is ignored, so www.example.com and example.com are considered the same host.
+func isSelfReferrer(r *http.Request, referrer, requestURL string) bool {
+	referrerHost := strings.TrimPrefix(getReferrerHostname(referrer), "www.")
+
+	if referrerHost == "" {
+		return false
+	}
+
+	host := ""
+
+	if u, err := url.Parse(requestURL); err == nil {
+		host = u.Hostname()
+	}
+
+	if host == "" {
+		host = r.Host
+
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+
+	return referrerHost == strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
 func getReferrerFromHeaderOrQuery(r *http.Request) string {
 	referrer := r.Header.Get("Referer")
 
@@ -117,6 +117,32 @@ func TestGetReferrerFromHeaderOrQuery(t *testing.T) {
 	}
 }
 
+func TestIsSelfReferrer(t *testing.T) {
+	input := []struct {
+		host     string
+		url      string
+		referrer string
+		expected bool
+	}{
+		{"example.com", "", "", false},
+		{"example.com", "", "https://example.com/page", true},
+		{"example.com:8080", "", "http://Example.com:8080/", true},
+		{"example.com", "", "https://www.example.com/", true},
+		{"www.example.com", "", "https://example.com/", true},
+		{"example.com", "", "https://blog.example.com/", false},
+		{"example.com", "", "https://google.com/", false},
+		{"pirsch.io", "https://example.com/page", "https://example.com/", true},
+		{"pirsch.io", "https://example.com/page", "https://pirsch.io/", false},
+		{"pirsch.io", "/page", "https://pirsch.io/", true},
+	}
+
+	for _, in := range input {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = in.host
+		assert.Equal(t, in.expected, isSelfReferrer(req, in.referrer, in.url), in)
+	}
+}
+
 func TestStripSubdomain(t *testing.T) {
 	input := []string{
 		"",
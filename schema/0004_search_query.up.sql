@@ -0,0 +1,2 @@
+ALTER TABLE "hit" ADD COLUMN "search_query" String DEFAULT '';
+ALTER TABLE "event" ADD COLUMN "search_query" String DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE "hit" ADD COLUMN "status_code" UInt16 DEFAULT 0;
+ALTER TABLE "event" ADD COLUMN "status_code" UInt16 DEFAULT 0;
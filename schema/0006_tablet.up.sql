@@ -0,0 +1,2 @@
+ALTER TABLE "hit" ADD COLUMN "tablet" Boolean DEFAULT 0;
+ALTER TABLE "event" ADD COLUMN "tablet" Boolean DEFAULT 0;
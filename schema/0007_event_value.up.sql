@@ -0,0 +1,2 @@
+ALTER TABLE "event" ADD COLUMN "event_value" Float64 DEFAULT 0;
+ALTER TABLE "event" ADD COLUMN "event_has_value" Boolean DEFAULT 0;
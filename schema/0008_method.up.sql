@@ -0,0 +1,2 @@
+ALTER TABLE "hit" ADD COLUMN "method" LowCardinality(String) DEFAULT '';
+ALTER TABLE "event" ADD COLUMN "method" LowCardinality(String) DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE "hit" ADD COLUMN "locale" LowCardinality(String) DEFAULT '';
+ALTER TABLE "event" ADD COLUMN "locale" LowCardinality(String) DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE "hit" ADD COLUMN "ip_hash" String DEFAULT '';
+ALTER TABLE "event" ADD COLUMN "ip_hash" String DEFAULT '';
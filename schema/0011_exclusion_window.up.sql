@@ -0,0 +1,7 @@
+CREATE TABLE "exclusion_window" (
+    client_id UInt64,
+    "from" DateTime('UTC'),
+    "to" DateTime('UTC')
+) ENGINE = MergeTree()
+ORDER BY (client_id, "from")
+;
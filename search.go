@@ -0,0 +1,21 @@
+package pirsch
+
+import (
+	"net/url"
+	"strings"
+)
+
+// getSearchQuery returns the (lowercase) search query for given URL and query parameter.
+func getSearchQuery(rawURL, param string) string {
+	if param == "" {
+		return ""
+	}
+
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(u.Query().Get(param)))
+}
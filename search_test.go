@@ -0,0 +1,15 @@
+package pirsch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetSearchQuery(t *testing.T) {
+	assert.Equal(t, "pirsch analytics", getSearchQuery("https://example.com/search?q=+Pirsch%20Analytics+&page=2", "q"))
+	assert.Equal(t, "term", getSearchQuery("/search?query=Term", "query"))
+	assert.Empty(t, getSearchQuery("/search?q=term", ""))
+	assert.Empty(t, getSearchQuery("/search?q=term", "query"))
+	assert.Empty(t, getSearchQuery("/search?q=+", "q"))
+	assert.Empty(t, getSearchQuery("%invalid", "q"))
+}
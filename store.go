@@ -1,18 +1,28 @@
 package pirsch
 
 import (
+	"errors"
 	"time"
 )
 
+// ErrNotSupported is returned by a Store for operations or queries it does not implement.
+var ErrNotSupported = errors.New("not supported")
+
 // Store is the database storage interface.
+// SaveEvents and Session are optional and may return ErrNotSupported.
+// Count, Get, and Select may return ErrNotSupported for queries the backend cannot run.
+// The Analyzer then skips optional results, like the average time on page, instead of failing.
 type Store interface {
 	// SaveHits saves given hits.
 	SaveHits([]Hit) error
 
 	// SaveEvents saves given events.
+	// This method is optional.
 	SaveEvents([]Event) error
 
 	// Session returns the last path, time, and session timestamp for given client, fingerprint, and maximum age.
+	// Timestamps are stored with a precision of one second.
+	// This method is optional. A new session is started for each hit if it is not supported.
 	Session(int64, string, time.Time) (string, time.Time, time.Time, error)
 
 	// Count returns the number of results for given query.
@@ -26,3 +36,24 @@ type Store interface {
 	// The results must be a pointer to a slice.
 	Select(interface{}, string, ...interface{}) error
 }
+
+// ExclusionWindowStore is an optional interface for a Store to save time windows excluded from the statistics.
+// Use Analyzer.WithExcludedWindows to exclude them from the queries for a client.
+type ExclusionWindowStore interface {
+	// SaveExclusionWindow saves a time window to be excluded for given client ID.
+	SaveExclusionWindow(int64, TimeWindow) error
+
+	// DeleteExclusionWindows deletes all time windows for given client ID.
+	DeleteExclusionWindows(int64) error
+
+	// ExclusionWindows returns the time windows excluded for given client ID.
+	ExclusionWindows(int64) ([]TimeWindow, error)
+}
+
+// RowStore is an optional interface for a Store to iterate over the results of a query, instead of loading them all at once.
+type RowStore interface {
+	// SelectRows calls given function for each result of given query.
+	// The function is passed another function to scan the result into, which must be a pointer to a struct.
+	// Iteration stops at the first error returned by the function, which is then returned.
+	SelectRows(func(func(interface{}) error) error, string, ...interface{}) error
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ const (
 	defaultWorkerBufferSize = 100
 	defaultWorkerTimeout    = time.Second * 10
 	maxWorkerTimeout        = time.Second * 60
+	subscriberBufferSize    = 100
 )
 
 var logger = log.New(os.Stdout, "[pirsch] ", log.LstdFlags)
@@ -42,13 +44,57 @@ type TrackerConfig struct {
 	// ReferrerDomainBlacklistIncludesSubdomains see HitOptions.ReferrerDomainBlacklistIncludesSubdomains.
 	ReferrerDomainBlacklistIncludesSubdomains bool
 
+	// IgnoreSelfReferrer see HitOptions.IgnoreSelfReferrer.
+	IgnoreSelfReferrer bool
+
+	// StoreLocale see HitOptions.StoreLocale.
+	StoreLocale bool
+
 	// SessionMaxAge see HitOptions.SessionMaxAge.
 	SessionMaxAge time.Duration
 
+	// EntryReferrerOnly see HitOptions.EntryReferrerOnly.
+	EntryReferrerOnly bool
+
+	// AllowUnknownUserAgents disables dropping hits with a User-Agent that cannot be parsed into an operating system or browser.
+	// These are usually bots or malformed clients, so they are dropped by default.
+	// This is checked in addition to IgnoreHit, which always drops requests with an empty User-Agent or from known bots.
+	AllowUnknownUserAgents bool
+
+	// SearchQueryParameter see HitOptions.SearchQueryParameter.
+	SearchQueryParameter string
+
+	// SignificantQueryParams see HitOptions.SignificantQueryParams.
+	SignificantQueryParams []string
+
+	// AllowPrefetch disables dropping requests made to prefetch or prerender a page (Purpose, Sec-Purpose, X-Purpose, and X-Moz headers).
+	// These requests are sent by browsers and data-saver proxies before (or without) the visitor viewing the page, so they are dropped by default.
+	// Enable it if you prerender pages on purpose and the page isn't tracked again once it's shown to the visitor.
+	AllowPrefetch bool
+
+	// RateLimit is the default limit for hits and events per client ID (see HitOptions.ClientID).
+	// Hits and events exceeding the limit are dropped, without affecting other clients. It's disabled by default.
+	RateLimit RateLimit
+
+	// ClientRateLimits overrides the RateLimit for individual client IDs.
+	// Set the rate to zero to disable the limit for a client.
+	ClientRateLimits map[int64]RateLimit
+
+	// EventMetaLimits limits the number and length of event meta keys and values (optional).
+	EventMetaLimits *EventMetaLimits
+
+	// IPHashSalt see HitOptions.IPHashSalt.
+	// Can be set/updated at runtime by calling Tracker.SetIPHashSalt.
+	IPHashSalt string
+
 	// GeoDB enables/disabled mapping IPs to country codes.
 	// Can be set/updated at runtime by calling Tracker.SetGeoDB.
 	GeoDB *GeoDB
 
+	// PanicHandler is called with the recovered value in case tracking a request or saving hits panics.
+	// The panic is logged and the request or batch is dropped, so that the Tracker keeps running.
+	PanicHandler func(interface{})
+
 	// Logger is the log.Logger used for logging.
 	// The default log will be used printing to os.Stdout with "pirsch" in its prefix in case it is not set.
 	Logger *log.Logger
@@ -91,8 +137,27 @@ type Tracker struct {
 	workerDone                                chan bool
 	referrerDomainBlacklist                   []string
 	referrerDomainBlacklistIncludesSubdomains bool
+	ignoreSelfReferrer                        bool
+	storeLocale                               bool
+	searchQueryParameter                      string
+	significantQueryParams                    []string
+	entryReferrerOnly                         bool
+	allowUnknownUserAgents                    bool
+	allowPrefetch                             bool
+	rateLimiter                               *rateLimiter
+	eventMetaLimits                           *EventMetaLimits
 	geoDB                                     *GeoDB
 	geoDBMutex                                sync.RWMutex
+	ipHashSalt                                string
+	ipHashSaltMutex                           sync.RWMutex
+	referrerMapping                           map[string]ReferrerMapping
+	referrerMappingMutex                      sync.RWMutex
+	botPatterns                               []*regexp.Regexp
+	botPatternsMutex                          sync.RWMutex
+	stats                                     *trackerStats
+	subscribers                               map[chan Hit]struct{}
+	subscribersMutex                          sync.RWMutex
+	panicHandler                              func(interface{})
 	logger                                    *log.Logger
 }
 
@@ -116,74 +181,154 @@ func NewTracker(client Store, salt string, config *TrackerConfig) *Tracker {
 		workerDone:              make(chan bool),
 		referrerDomainBlacklist: config.ReferrerDomainBlacklist,
 		referrerDomainBlacklistIncludesSubdomains: config.ReferrerDomainBlacklistIncludesSubdomains,
-		geoDB:  config.GeoDB,
-		logger: config.Logger,
+		ignoreSelfReferrer:                        config.IgnoreSelfReferrer,
+		storeLocale:                               config.StoreLocale,
+		searchQueryParameter:                      config.SearchQueryParameter,
+		significantQueryParams:                    config.SignificantQueryParams,
+		entryReferrerOnly:                         config.EntryReferrerOnly,
+		allowUnknownUserAgents:                    config.AllowUnknownUserAgents,
+		allowPrefetch:                             config.AllowPrefetch,
+		rateLimiter:                               newRateLimiter(config.RateLimit, config.ClientRateLimits),
+		eventMetaLimits:                           config.EventMetaLimits,
+		geoDB:                                     config.GeoDB,
+		ipHashSalt:                                config.IPHashSalt,
+		panicHandler:                              config.PanicHandler,
+		logger:                                    config.Logger,
+		referrerMapping:                           make(map[string]ReferrerMapping),
+		stats:                                     newTrackerStats(),
+		subscribers:                               make(map[chan Hit]struct{}),
 	}
 	tracker.startWorker()
 	return tracker
 }
 
 // Hit stores the given request.
-// The request might be ignored if it meets certain conditions. The HitOptions, if passed, will overwrite the Tracker configuration.
+// The request might be ignored if it meets certain conditions. The HitOptions, if passed, will overwrite the Tracker configuration. Options left empty fall back to it.
 // It's save (and recommended!) to call this function in its own goroutine.
 func (tracker *Tracker) Hit(r *http.Request, options *HitOptions) {
+	defer tracker.recoverPanic()
+	tracker.stats.receive()
+
 	if atomic.LoadInt32(&tracker.stopped) > 0 {
+		tracker.stats.drop(ignoredOther)
 		return
 	}
 
-	if !IgnoreHit(r) {
-		if options == nil {
-			options = &HitOptions{
-				ReferrerDomainBlacklist:                   tracker.referrerDomainBlacklist,
-				ReferrerDomainBlacklistIncludesSubdomains: tracker.referrerDomainBlacklistIncludesSubdomains,
-			}
+	if tracker.accept(r) {
+		options = tracker.hitOptions(options)
+
+		if !tracker.rateLimiter.allow(options.ClientID) {
+			tracker.stats.drop(ignoredRateLimit)
+			return
 		}
 
-		if tracker.geoDB != nil {
-			tracker.geoDBMutex.RLock()
-			options.geoDB = tracker.geoDB
-			tracker.geoDBMutex.RUnlock()
+		options.geoDB = tracker.getGeoDB()
+
+		if options.IPHashSalt == "" {
+			options.IPHashSalt = tracker.getIPHashSalt()
 		}
 
 		options.Client = tracker.store
-		tracker.hits <- HitFromRequest(r, tracker.salt, options)
+		options.referrerMapping = tracker.getReferrerMapping
+		tracker.stats.accept()
+		hit := HitFromRequest(r, tracker.salt, options)
+		tracker.hits <- hit
+		tracker.publish(hit)
 	}
 }
 
 // Event stores the given request as a new event. The event name in the options must be set, or otherwise the request will be ignored.
-// The request might be ignored if it meets certain conditions. The HitOptions, if passed, will overwrite the Tracker configuration.
+// The request might be ignored if it meets certain conditions. The HitOptions, if passed, will overwrite the Tracker configuration. Options left empty fall back to it.
 // It's save (and recommended!) to call this function in its own goroutine.
 func (tracker *Tracker) Event(r *http.Request, eventOptions EventOptions, options *HitOptions) {
-	if atomic.LoadInt32(&tracker.stopped) > 0 {
+	defer tracker.recoverPanic()
+	tracker.stats.receive()
+
+	if atomic.LoadInt32(&tracker.stopped) > 0 || strings.TrimSpace(eventOptions.Name) == "" {
+		tracker.stats.drop(ignoredOther)
 		return
 	}
 
-	if strings.TrimSpace(eventOptions.Name) != "" && !IgnoreHit(r) {
-		if options == nil {
-			options = &HitOptions{
-				ReferrerDomainBlacklist:                   tracker.referrerDomainBlacklist,
-				ReferrerDomainBlacklistIncludesSubdomains: tracker.referrerDomainBlacklistIncludesSubdomains,
-			}
+	if tracker.accept(r) {
+		options = tracker.hitOptions(options)
+
+		if !tracker.rateLimiter.allow(options.ClientID) {
+			tracker.stats.drop(ignoredRateLimit)
+			return
 		}
 
-		if tracker.geoDB != nil {
-			tracker.geoDBMutex.RLock()
-			options.geoDB = tracker.geoDB
-			tracker.geoDBMutex.RUnlock()
+		options.geoDB = tracker.getGeoDB()
+
+		if options.IPHashSalt == "" {
+			options.IPHashSalt = tracker.getIPHashSalt()
 		}
 
 		options.Client = tracker.store
+		options.referrerMapping = tracker.getReferrerMapping
+		eventOptions.Name = strings.TrimSpace(eventOptions.Name)
+		eventOptions.Meta = tracker.eventMetaLimits.apply(eventOptions.Name, eventOptions.Meta)
 		metaKeys, metaValues := eventOptions.getMetaData()
-		tracker.events <- Event{
+		event := Event{
 			Hit:             HitFromRequest(r, tracker.salt, options),
-			Name:            strings.TrimSpace(eventOptions.Name),
+			Name:            eventOptions.Name,
 			DurationSeconds: eventOptions.Duration,
 			MetaKeys:        metaKeys,
 			MetaValues:      metaValues,
 		}
+
+		if eventOptions.Value != nil {
+			event.Value = *eventOptions.Value
+			event.HasValue = true
+		}
+
+		tracker.stats.accept()
+		tracker.events <- event
 	}
 }
 
+// TrackOutbound stores a click on an outbound link to given URL as an event (see EventOutbound).
+// The URL is stored as event meta data (see EventMetaURL). It's ignored if the URL is empty.
+func (tracker *Tracker) TrackOutbound(r *http.Request, url string, options *HitOptions) {
+	if url = strings.TrimSpace(url); url == "" {
+		tracker.ignore()
+		return
+	}
+
+	tracker.Event(r, EventOptions{
+		Name: EventOutbound,
+		Meta: map[string]string{EventMetaURL: url},
+	}, options)
+}
+
+// TrackDownload stores a download of given file as an event (see EventDownload).
+// The file is stored as event meta data (see EventMetaFile). It's ignored if the file is empty.
+func (tracker *Tracker) TrackDownload(r *http.Request, file string, options *HitOptions) {
+	if file = strings.TrimSpace(file); file == "" {
+		tracker.ignore()
+		return
+	}
+
+	tracker.Event(r, EventOptions{
+		Name: EventDownload,
+		Meta: map[string]string{EventMetaFile: file},
+	}, options)
+}
+
+// Heartbeat stores the number of seconds a visitor engaged with a page as an event (see EventHeartbeat).
+// Call it periodically while the page is visible, or once when the page is left (like from an unload beacon), with the seconds since the last call.
+// The seconds are summed up per session and page by Analyzer.AverageEngagedTime. It's ignored if seconds is zero or less.
+func (tracker *Tracker) Heartbeat(r *http.Request, seconds int, options *HitOptions) {
+	if seconds <= 0 {
+		tracker.ignore()
+		return
+	}
+
+	tracker.Event(r, EventOptions{
+		Name:     EventHeartbeat,
+		Duration: seconds,
+	}, options)
+}
+
 // Flush flushes all hits to client that are currently buffered by the workers.
 // Call Tracker.Stop to also save hits that are in the queue.
 func (tracker *Tracker) Flush() {
@@ -198,18 +343,243 @@ func (tracker *Tracker) Stop() {
 		tracker.stopWorker()
 		tracker.flushHits()
 		tracker.flushEvents()
+		tracker.closeSubscribers()
+	}
+}
+
+// Subscribe returns a channel receiving all hits accepted by the Tracker from now on, and a function to unsubscribe.
+// Hits are dropped for the subscriber if the channel buffer is full, so that slow subscribers don't slow down tracking.
+// The channel is closed when unsubscribing or when the Tracker is stopped. The unsubscribe function can be called multiple times.
+func (tracker *Tracker) Subscribe() (<-chan Hit, func()) {
+	subscriber := make(chan Hit, subscriberBufferSize)
+	tracker.subscribersMutex.Lock()
+	defer tracker.subscribersMutex.Unlock()
+
+	if atomic.LoadInt32(&tracker.stopped) > 0 {
+		close(subscriber)
+		return subscriber, func() {}
+	}
+
+	tracker.subscribers[subscriber] = struct{}{}
+	return subscriber, func() {
+		tracker.subscribersMutex.Lock()
+		defer tracker.subscribersMutex.Unlock()
+
+		if _, found := tracker.subscribers[subscriber]; found {
+			delete(tracker.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+func (tracker *Tracker) publish(hit Hit) {
+	tracker.subscribersMutex.RLock()
+	defer tracker.subscribersMutex.RUnlock()
+
+	for subscriber := range tracker.subscribers {
+		select {
+		case subscriber <- hit:
+		default:
+		}
+	}
+}
+
+func (tracker *Tracker) closeSubscribers() {
+	tracker.subscribersMutex.Lock()
+	defer tracker.subscribersMutex.Unlock()
+
+	for subscriber := range tracker.subscribers {
+		delete(tracker.subscribers, subscriber)
+		close(subscriber)
 	}
 }
 
 // SetGeoDB sets the GeoDB for the Tracker.
-// The call to this function is thread safe to enable live updates of the database.
-// Pass nil to disable the feature.
+// The call to this function is thread safe to enable live updates of the database while the Tracker is running.
+// Requests being tracked while the GeoDB is swapped finish their lookup using the previous database,
+// which stays valid as it is kept in memory. Pass nil to disable the feature.
 func (tracker *Tracker) SetGeoDB(geoDB *GeoDB) {
 	tracker.geoDBMutex.Lock()
 	defer tracker.geoDBMutex.Unlock()
 	tracker.geoDB = geoDB
 }
 
+// Stats returns the number of hits and events received and dropped since the Tracker was created or the stats were reset.
+// Pass true to reset the counters, to get the stats for consecutive windows of time.
+func (tracker *Tracker) Stats(reset bool) TrackerStats {
+	return tracker.stats.get(reset)
+}
+
+// ignore counts a request dropped before it is passed to Tracker.Hit or Tracker.Event.
+func (tracker *Tracker) ignore() {
+	tracker.stats.receive()
+	tracker.stats.drop(ignoredOther)
+}
+
+// accept returns whether the request should be tracked and counts it as dropped otherwise.
+func (tracker *Tracker) accept(r *http.Request) bool {
+	if reason := ignoreHitReason(r, tracker.allowPrefetch); reason != notIgnored {
+		tracker.stats.drop(reason)
+		return false
+	}
+
+	if !tracker.acceptUserAgent(r.UserAgent()) {
+		tracker.stats.drop(ignoredBot)
+		return false
+	}
+
+	return true
+}
+
+// acceptUserAgent returns false if given User-Agent matches a bot pattern, or cannot be parsed into an operating system or browser,
+// unless unknown User-Agents are allowed.
+func (tracker *Tracker) acceptUserAgent(userAgent string) bool {
+	if tracker.matchBotPattern(userAgent) {
+		return false
+	}
+
+	if tracker.allowUnknownUserAgents {
+		return true
+	}
+
+	userAgentResult := ParseUserAgent(userAgent)
+	return userAgentResult.OS != "" || userAgentResult.Browser != ""
+}
+
+// hitOptions returns a copy of the options with the tracker defaults set for all fields left empty.
+// Boolean options are enabled if they are set either on the options or the tracker.
+func (tracker *Tracker) hitOptions(options *HitOptions) *HitOptions {
+	hitOptions := new(HitOptions)
+
+	if options != nil {
+		*hitOptions = *options
+	}
+
+	if hitOptions.ReferrerDomainBlacklist == nil {
+		hitOptions.ReferrerDomainBlacklist = tracker.referrerDomainBlacklist
+	}
+
+	if hitOptions.SearchQueryParameter == "" {
+		hitOptions.SearchQueryParameter = tracker.searchQueryParameter
+	}
+
+	if hitOptions.SignificantQueryParams == nil {
+		hitOptions.SignificantQueryParams = tracker.significantQueryParams
+	}
+
+	hitOptions.ReferrerDomainBlacklistIncludesSubdomains = hitOptions.ReferrerDomainBlacklistIncludesSubdomains || tracker.referrerDomainBlacklistIncludesSubdomains
+	hitOptions.IgnoreSelfReferrer = hitOptions.IgnoreSelfReferrer || tracker.ignoreSelfReferrer
+	hitOptions.StoreLocale = hitOptions.StoreLocale || tracker.storeLocale
+	hitOptions.EntryReferrerOnly = hitOptions.EntryReferrerOnly || tracker.entryReferrerOnly
+	return hitOptions
+}
+
+func (tracker *Tracker) getGeoDB() *GeoDB {
+	tracker.geoDBMutex.RLock()
+	defer tracker.geoDBMutex.RUnlock()
+	return tracker.geoDB
+}
+
+// SetIPHashSalt sets the salt used to hash the IP of hits and events (see HitOptions.IPHashSalt).
+// Call it regularly with a new salt to rotate it, or pass an empty string to stop storing IP hashes.
+func (tracker *Tracker) SetIPHashSalt(salt string) {
+	tracker.ipHashSaltMutex.Lock()
+	defer tracker.ipHashSaltMutex.Unlock()
+	tracker.ipHashSalt = salt
+}
+
+func (tracker *Tracker) getIPHashSalt() string {
+	tracker.ipHashSaltMutex.RLock()
+	defer tracker.ipHashSaltMutex.RUnlock()
+	return tracker.ipHashSalt
+}
+
+// AddBotPattern adds a regular expression for User-Agents that are dropped as bots, in addition to the built-in list.
+// The pattern is matched case-insensitive against the User-Agent and an error is returned in case it cannot be compiled.
+// The call to this function is thread safe, so patterns can be added while the Tracker is running.
+func (tracker *Tracker) AddBotPattern(pattern string) error {
+	regex, err := regexp.Compile("(?i)" + pattern)
+
+	if err != nil {
+		return err
+	}
+
+	tracker.botPatternsMutex.Lock()
+	defer tracker.botPatternsMutex.Unlock()
+	tracker.botPatterns = append(tracker.botPatterns, regex)
+	return nil
+}
+
+// IsBot returns true if given User-Agent is empty, contains a known bot keyword, or matches a pattern added by AddBotPattern.
+// User-Agents that cannot be parsed into an operating system or browser are considered bots too, unless TrackerConfig.AllowUnknownUserAgents is set.
+// Hits and events with such a User-Agent are dropped by the Tracker. This can be used to check why a hit was dropped.
+// Other reasons, like the DNT header or referrer spam, are not checked.
+func (tracker *Tracker) IsBot(userAgent string) bool {
+	userAgent = strings.TrimSpace(userAgent)
+
+	if userAgent == "" {
+		return true
+	}
+
+	return isBotUserAgent(strings.ToLower(userAgent)) || !tracker.acceptUserAgent(userAgent)
+}
+
+func (tracker *Tracker) matchBotPattern(userAgent string) bool {
+	tracker.botPatternsMutex.RLock()
+	defer tracker.botPatternsMutex.RUnlock()
+
+	for _, pattern := range tracker.botPatterns {
+		if pattern.MatchString(userAgent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddReferrerMapping sets the name and icon stored for referrers from given host (like news.ycombinator.com).
+// Subdomains of any depth are matched too (like www.news.ycombinator.com), unless a more specific host has a mapping of its own.
+// The host is matched label by label without a list of public suffixes, so a mapping for a suffix like co.uk matches all domains below it.
+// The call to this function is thread safe, so mappings can be added while the Tracker is running.
+func (tracker *Tracker) AddReferrerMapping(host, name, icon string) {
+	tracker.AddReferrerMappings(map[string]ReferrerMapping{
+		host: {Name: name, Icon: icon},
+	})
+}
+
+// AddReferrerMappings sets the name and icon stored for referrers for all hosts in given map.
+// See AddReferrerMapping for details.
+func (tracker *Tracker) AddReferrerMappings(mappings map[string]ReferrerMapping) {
+	tracker.referrerMappingMutex.Lock()
+	defer tracker.referrerMappingMutex.Unlock()
+
+	for host, mapping := range mappings {
+		tracker.referrerMapping[strings.ToLower(strings.TrimSpace(host))] = mapping
+	}
+}
+
+func (tracker *Tracker) getReferrerMapping(referrer string) (ReferrerMapping, bool) {
+	hostname := getReferrerHostname(referrer)
+	tracker.referrerMappingMutex.RLock()
+	defer tracker.referrerMappingMutex.RUnlock()
+
+	for hostname != "" {
+		if mapping, found := tracker.referrerMapping[hostname]; found {
+			return mapping, true
+		}
+
+		i := strings.Index(hostname, ".")
+
+		if i < 0 {
+			break
+		}
+
+		hostname = hostname[i+1:]
+	}
+
+	return ReferrerMapping{}, false
+}
+
 func (tracker *Tracker) startWorker() {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	tracker.workerCancel = cancelFunc
@@ -284,6 +654,8 @@ func (tracker *Tracker) aggregateHits(ctx context.Context) {
 }
 
 func (tracker *Tracker) saveHits(hits []Hit) {
+	defer tracker.recoverPanic()
+
 	if len(hits) > 0 {
 		if err := tracker.store.SaveHits(hits); err != nil {
 			tracker.logger.Printf("error saving hits: %s", err)
@@ -347,9 +719,21 @@ func (tracker *Tracker) aggregateEvents(ctx context.Context) {
 }
 
 func (tracker *Tracker) saveEvents(events []Event) {
+	defer tracker.recoverPanic()
+
 	if len(events) > 0 {
 		if err := tracker.store.SaveEvents(events); err != nil {
 			tracker.logger.Printf("error saving events: %s", err)
 		}
 	}
 }
+
+func (tracker *Tracker) recoverPanic() {
+	if err := recover(); err != nil {
+		tracker.logger.Printf("recovered from panic: %v", err)
+
+		if tracker.panicHandler != nil {
+			tracker.panicHandler(err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package pirsch
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TrackerStats are the number of hits and events received by the Tracker since a point in time.
+// Dropped requests are not stored, so this is the only place to see how much traffic is filtered.
+type TrackerStats struct {
+	// Since is the time the counters were (re)set.
+	Since time.Time `json:"since"`
+
+	// Received is the total number of hits and events passed to the Tracker, which is the sum of the accepted and dropped ones.
+	Received int `json:"received"`
+
+	// Accepted is the number of hits and events that have been accepted for storage.
+	Accepted int `json:"accepted"`
+
+	// DoNotTrack is the number of requests dropped because of the DNT header.
+	DoNotTrack int `json:"do_not_track"`
+
+	// Bots is the number of requests dropped because the User-Agent belongs to a bot or is unknown.
+	Bots int `json:"bots"`
+
+	// Ignored is the number of requests dropped for other reasons, like prefetching, referrer spam, outdated browsers,
+	// events without a name, or the Tracker being stopped.
+	Ignored int `json:"ignored"`
+
+	// RateLimited is the number of requests dropped because the client exceeded its RateLimit.
+	RateLimited int `json:"rate_limited"`
+}
+
+// Dropped returns the total number of dropped hits and events.
+func (stats TrackerStats) Dropped() int {
+	return stats.DoNotTrack + stats.Bots + stats.Ignored + stats.RateLimited
+}
+
+// DroppedRatio returns the share of dropped hits and events in the total received, between 0 and 1.
+func (stats TrackerStats) DroppedRatio() float64 {
+	if stats.Received == 0 {
+		return 0
+	}
+
+	return float64(stats.Dropped()) / float64(stats.Received)
+}
+
+// trackerStats counts the hits and events received by the Tracker.
+// The counters are updated atomically, as they are updated on every request.
+// The int64 fields must come first to be 64-bit aligned on 32-bit platforms.
+type trackerStats struct {
+	received    int64
+	accepted    int64
+	doNotTrack  int64
+	bots        int64
+	ignored     int64
+	rateLimited int64
+	since       int64
+}
+
+func newTrackerStats() *trackerStats {
+	return &trackerStats{
+		since: time.Now().UTC().UnixNano(),
+	}
+}
+
+// receive counts a hit or event passed to the Tracker. It must be called once for each of them, before it's accepted or dropped.
+func (stats *trackerStats) receive() {
+	atomic.AddInt64(&stats.received, 1)
+}
+
+func (stats *trackerStats) accept() {
+	atomic.AddInt64(&stats.accepted, 1)
+}
+
+// drop counts a hit or event dropped for given reason.
+func (stats *trackerStats) drop(reason ignoreReason) {
+	switch reason {
+	case ignoredDoNotTrack:
+		atomic.AddInt64(&stats.doNotTrack, 1)
+	case ignoredBot:
+		atomic.AddInt64(&stats.bots, 1)
+	case ignoredRateLimit:
+		atomic.AddInt64(&stats.rateLimited, 1)
+	default:
+		atomic.AddInt64(&stats.ignored, 1)
+	}
+}
+
+// get returns the current stats. The counters are read (and reset) one by one,
+// so requests being tracked at the same time might be included in some of them only.
+func (stats *trackerStats) get(reset bool) TrackerStats {
+	load := atomic.LoadInt64
+
+	if reset {
+		load = func(counter *int64) int64 {
+			return atomic.SwapInt64(counter, 0)
+		}
+	}
+
+	since := atomic.LoadInt64(&stats.since)
+
+	if reset {
+		atomic.StoreInt64(&stats.since, time.Now().UTC().UnixNano())
+	}
+
+	return TrackerStats{
+		Since:       time.Unix(0, since).UTC(),
+		Received:    int(load(&stats.received)),
+		Accepted:    int(load(&stats.accepted)),
+		DoNotTrack:  int(load(&stats.doNotTrack)),
+		Bots:        int(load(&stats.bots)),
+		Ignored:     int(load(&stats.ignored)),
+		RateLimited: int(load(&stats.rateLimited)),
+	}
+}
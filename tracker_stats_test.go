@@ -0,0 +1,55 @@
+package pirsch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrackerStats(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		RateLimit: RateLimit{Rate: 1, Burst: 2},
+	})
+	assert.Zero(t, tracker.Stats(false).DroppedRatio())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	tracker.Hit(req, nil)
+	tracker.Event(req, EventOptions{Name: "event"}, nil)
+	tracker.Hit(req, nil)
+	dnt := httptest.NewRequest(http.MethodGet, "/", nil)
+	dnt.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	dnt.Header.Set("DNT", "1")
+	tracker.Hit(dnt, nil)
+	bot := httptest.NewRequest(http.MethodGet, "/", nil)
+	bot.Header.Set("User-Agent", "Googlebot/2.1")
+	tracker.Hit(bot, nil)
+	tracker.Hit(httptest.NewRequest(http.MethodGet, "/", nil), nil)
+	prefetch := httptest.NewRequest(http.MethodGet, "/", nil)
+	prefetch.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	prefetch.Header.Set("Sec-Purpose", "prefetch")
+	tracker.Event(prefetch, EventOptions{Name: "event"}, nil)
+	tracker.Event(req, EventOptions{Name: " "}, nil)
+	tracker.TrackOutbound(req, "", nil)
+	stats := tracker.Stats(true)
+	assert.False(t, stats.Since.IsZero())
+	assert.Equal(t, 9, stats.Received)
+	assert.Equal(t, 2, stats.Accepted)
+	assert.Equal(t, 1, stats.DoNotTrack)
+	assert.Equal(t, 2, stats.Bots)
+	assert.Equal(t, 3, stats.Ignored)
+	assert.Equal(t, 1, stats.RateLimited)
+	assert.Equal(t, 7, stats.Dropped())
+	assert.InDelta(t, 7.0/9.0, stats.DroppedRatio(), 0.001)
+	stats = tracker.Stats(false)
+	assert.Zero(t, stats.Received)
+	assert.Zero(t, stats.Dropped())
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+	assert.Len(t, client.Events, 1)
+	tracker.Hit(req, nil)
+	stats = tracker.Stats(false)
+	assert.Equal(t, 1, stats.Received)
+	assert.Equal(t, 1, stats.Ignored)
+}
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -92,6 +94,138 @@ func TestTrackerHitDiscard(t *testing.T) {
 	assert.Len(t, client.Hits, 5)
 }
 
+func TestTrackerHitUnknownUserAgent(t *testing.T) {
+	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
+	req1.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req2 := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	req2.Header.Add("User-Agent", "foobar/1.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	tracker.Hit(req1, nil)
+	tracker.Hit(req2, nil)
+	tracker.Event(req2, EventOptions{Name: "event"}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+	assert.Equal(t, "/", client.Hits[0].Path)
+	assert.Len(t, client.Events, 0)
+	client = NewMockClient()
+	tracker = NewTracker(client, "salt", &TrackerConfig{
+		AllowUnknownUserAgents: true,
+	})
+	tracker.Hit(req1, nil)
+	tracker.Hit(req2, nil)
+	tracker.Event(req2, EventOptions{Name: "event"}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 2)
+	assert.Len(t, client.Events, 1)
+}
+
+func TestTrackerBotPattern(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0 AcmeUptime/1.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	assert.False(t, tracker.IsBot(req.UserAgent()))
+	assert.True(t, tracker.IsBot(""))
+	assert.True(t, tracker.IsBot("Googlebot/2.1"))
+	assert.True(t, tracker.IsBot("foo"))
+	unknownUserAgentTracker := NewTracker(NewMockClient(), "salt", &TrackerConfig{AllowUnknownUserAgents: true})
+	assert.False(t, unknownUserAgentTracker.IsBot("foo"))
+	unknownUserAgentTracker.Stop()
+	assert.Error(t, tracker.AddBotPattern("uptime/("))
+	assert.NoError(t, tracker.AddBotPattern(`acmeuptime/\d+`))
+	assert.True(t, tracker.IsBot(req.UserAgent()))
+	tracker.Hit(req, nil)
+	tracker.Event(req, EventOptions{Name: "event"}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 0)
+	assert.Len(t, client.Events, 0)
+}
+
+func TestTrackerHitPrefetch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req.Header.Set("Sec-Purpose", "prefetch")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	tracker.Hit(req, nil)
+	tracker.Event(req, EventOptions{Name: "event"}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 0)
+	assert.Len(t, client.Events, 0)
+	client = NewMockClient()
+	tracker = NewTracker(client, "salt", &TrackerConfig{
+		AllowPrefetch: true,
+	})
+	tracker.Hit(req, nil)
+	tracker.Event(req, EventOptions{Name: "event"}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+	assert.Len(t, client.Events, 1)
+}
+
+func TestTrackerIPHash(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	req.RemoteAddr = "81.2.69.142"
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	tracker.Hit(req, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+	assert.Empty(t, client.Hits[0].IPHash)
+	client = NewMockClient()
+	tracker = NewTracker(client, "salt", &TrackerConfig{
+		IPHashSalt: "ip",
+	})
+	tracker.Hit(req, nil)
+	tracker.Event(req, EventOptions{Name: "event"}, nil)
+	tracker.SetIPHashSalt("rotated")
+	tracker.Hit(req, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 2)
+	assert.Len(t, client.Events, 1)
+	assert.Equal(t, IPHash(req, "ip"), client.Hits[0].IPHash)
+	assert.Equal(t, IPHash(req, "ip"), client.Events[0].IPHash)
+	assert.Equal(t, IPHash(req, "rotated"), client.Hits[1].IPHash)
+}
+
+func TestTrackerSubscribe(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	hits1, unsubscribe1 := tracker.Subscribe()
+	hits2, unsubscribe2 := tracker.Subscribe()
+	tracker.Hit(req, nil)
+	hit := <-hits1
+	assert.Equal(t, "/", hit.Path)
+	hit = <-hits2
+	assert.Equal(t, "/", hit.Path)
+	unsubscribe1()
+	unsubscribe1()
+	_, open := <-hits1
+	assert.False(t, open)
+
+	for i := 0; i < subscriberBufferSize+10; i++ {
+		tracker.Hit(req, nil)
+	}
+
+	assert.Len(t, hits2, subscriberBufferSize)
+	tracker.Stop()
+	assert.Len(t, client.Hits, subscriberBufferSize+11)
+
+	for range hits2 {
+		// drain the channel until it's closed
+	}
+
+	unsubscribe2()
+	hits3, unsubscribe3 := tracker.Subscribe()
+	_, open = <-hits3
+	assert.False(t, open)
+	unsubscribe3()
+}
+
 func TestTrackerHitCountryCode(t *testing.T) {
 	geoDB, err := NewGeoDB(GeoDBConfig{
 		File: filepath.Join("geodb/GeoIP2-Country-Test.mmdb"),
@@ -127,6 +261,48 @@ func TestTrackerHitCountryCode(t *testing.T) {
 	assert.True(t, foundEmpty)
 }
 
+func TestTrackerSetGeoDBConcurrent(t *testing.T) {
+	geoDB, err := NewGeoDB(GeoDBConfig{
+		File: filepath.Join("geodb/GeoIP2-Country-Test.mmdb"),
+	})
+	assert.NoError(t, err)
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		WorkerTimeout: time.Second,
+	})
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+			req.RemoteAddr = "81.2.69.142"
+			tracker.Hit(req, nil)
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+
+			if i%2 == 0 {
+				tracker.SetGeoDB(geoDB)
+			} else {
+				tracker.SetGeoDB(nil)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	tracker.Stop()
+	assert.Len(t, client.Hits, 10)
+
+	for _, hit := range client.Hits {
+		assert.Contains(t, []string{"", "gb"}, hit.CountryCode)
+	}
+}
+
 func TestTrackerHitSession(t *testing.T) {
 	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
 	req1.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
@@ -180,6 +356,92 @@ func TestTrackerHitIgnoreSubdomain(t *testing.T) {
 	}
 }
 
+func TestTrackerHitReferrerMapping(t *testing.T) {
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		WorkerTimeout: time.Second,
+	})
+	tracker.AddReferrerMapping("News.YCombinator.com", "Hacker News", "https://news.ycombinator.com/favicon.ico")
+	tracker.AddReferrerMappings(map[string]ReferrerMapping{
+		"example.com": {Name: "Example"},
+		"newsletter":  {Name: "Newsletter"},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	tracker.Hit(req, &HitOptions{Referrer: "https://news.ycombinator.com/item?id=42"})
+	tracker.Hit(req, &HitOptions{Referrer: "https://blog.example.com/"})
+	tracker.Hit(req, &HitOptions{Referrer: "Newsletter"})
+	tracker.Hit(req, &HitOptions{Referrer: "https://unknown.com/"})
+	tracker.Hit(req, &HitOptions{Referrer: "https://a.b.example.com/"})
+	tracker.Hit(req, &HitOptions{Referrer: "https://example.co.uk/"})
+	tracker.Stop()
+	assert.Len(t, client.Hits, 6)
+	mapping := make(map[string]Hit)
+
+	for _, hit := range client.Hits {
+		mapping[hit.Referrer] = hit
+	}
+
+	assert.Equal(t, "Hacker News", mapping["https://news.ycombinator.com/item"].ReferrerName)
+	assert.Equal(t, "https://news.ycombinator.com/favicon.ico", mapping["https://news.ycombinator.com/item"].ReferrerIcon)
+	assert.Equal(t, "Example", mapping["https://blog.example.com/"].ReferrerName)
+	assert.Empty(t, mapping["https://blog.example.com/"].ReferrerIcon)
+	assert.Equal(t, "Newsletter", mapping["Newsletter"].ReferrerName)
+	assert.Empty(t, mapping["https://unknown.com/"].ReferrerName)
+	assert.Equal(t, "Example", mapping["https://a.b.example.com/"].ReferrerName)
+	assert.Empty(t, mapping["https://example.co.uk/"].ReferrerName)
+}
+
+type panicClient struct {
+	*MockClient
+	panicOnSession bool
+	saves          int32
+}
+
+func (client *panicClient) SaveHits(hits []Hit) error {
+	if atomic.AddInt32(&client.saves, 1) == 1 {
+		panic("save hits")
+	}
+
+	return client.MockClient.SaveHits(hits)
+}
+
+func (client *panicClient) Session(clientID int64, fingerprint string, maxAge time.Time) (string, time.Time, time.Time, error) {
+	if client.panicOnSession {
+		panic("session")
+	}
+
+	return client.MockClient.Session(clientID, fingerprint, maxAge)
+}
+
+func TestTrackerPanicRecovery(t *testing.T) {
+	client := &panicClient{MockClient: NewMockClient(), panicOnSession: true}
+	var m sync.Mutex
+	recovered := make([]interface{}, 0)
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		Worker:           1,
+		WorkerBufferSize: 1,
+		PanicHandler: func(err interface{}) {
+			m.Lock()
+			defer m.Unlock()
+			recovered = append(recovered, err)
+		},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	tracker.Hit(req, nil) // panics looking up the session
+	client.panicOnSession = false
+	tracker.Hit(req, nil) // panics saving the first batch
+	tracker.Hit(req, nil)
+	tracker.Stop()
+	assert.Len(t, client.Hits, 1)
+	m.Lock()
+	defer m.Unlock()
+	assert.Len(t, recovered, 2)
+	assert.Contains(t, recovered, "session")
+	assert.Contains(t, recovered, "save hits")
+}
+
 func TestTrackerEvent(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
@@ -200,6 +462,115 @@ func TestTrackerEvent(t *testing.T) {
 	assert.Contains(t, client.Events[0].MetaValues, "data")
 }
 
+func TestTrackerEventValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	value := 0.0
+	tracker.Event(req, EventOptions{Name: "order", Value: &value}, nil)
+	tracker.Event(req, EventOptions{Name: "signup"}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Events, 2)
+	events := make(map[string]Event)
+
+	for _, event := range client.Events {
+		events[event.Name] = event
+	}
+
+	assert.True(t, events["order"].HasValue)
+	assert.InDelta(t, 0, events["order"].Value, 0.001)
+	assert.False(t, events["signup"].HasValue)
+}
+
+func TestTrackerRateLimit(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		RateLimit:        RateLimit{Rate: 0.001, Burst: 2},
+		ClientRateLimits: map[int64]RateLimit{42: {}},
+	})
+
+	for i := 0; i < 5; i++ {
+		tracker.Hit(req, nil)
+		tracker.Hit(req, &HitOptions{ClientID: 1})
+		tracker.Hit(req, &HitOptions{ClientID: 42})
+		tracker.Event(req, EventOptions{Name: "event"}, &HitOptions{ClientID: 2})
+	}
+
+	tracker.Stop()
+	clients := make(map[int64]int)
+
+	for _, hit := range client.Hits {
+		clients[hit.ClientID]++
+	}
+
+	assert.Equal(t, 2, clients[0])
+	assert.Equal(t, 2, clients[1])
+	assert.Equal(t, 5, clients[42])
+	assert.Len(t, client.Events, 2)
+}
+
+func TestTrackerEventMetaLimits(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	client := NewMockClient()
+	var eventName string
+	tracker := NewTracker(client, "salt", &TrackerConfig{
+		EventMetaLimits: &EventMetaLimits{
+			MaxKeys:        1,
+			MaxValueLength: 3,
+			OnLimit: func(name string, removed, truncated []string) {
+				eventName = name
+			},
+		},
+	})
+	tracker.Event(req, EventOptions{Name: " event ", Meta: map[string]string{"a": "value", "b": "value"}}, nil)
+	tracker.Stop()
+	assert.Len(t, client.Events, 1)
+	assert.Equal(t, []string{"a"}, client.Events[0].MetaKeys)
+	assert.Equal(t, []string{"val"}, client.Events[0].MetaValues)
+	assert.Equal(t, "event", eventName)
+}
+
+func TestTrackerOutboundAndDownload(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	tracker.TrackOutbound(req, " ", nil) // ignore (empty URL)
+	tracker.TrackDownload(req, "", nil)  // ignore (empty file)
+	tracker.TrackOutbound(req, "https://example.com/", nil)
+	tracker.TrackDownload(req, " /files/report.pdf ", &HitOptions{ClientID: 42})
+	tracker.Stop()
+	assert.Len(t, client.Events, 2)
+	events := make(map[string]Event)
+
+	for _, event := range client.Events {
+		events[event.Name] = event
+	}
+
+	assert.Equal(t, []string{EventMetaURL}, events[EventOutbound].MetaKeys)
+	assert.Equal(t, []string{"https://example.com/"}, events[EventOutbound].MetaValues)
+	assert.Equal(t, []string{EventMetaFile}, events[EventDownload].MetaKeys)
+	assert.Equal(t, []string{"/files/report.pdf"}, events[EventDownload].MetaValues)
+	assert.Equal(t, int64(42), events[EventDownload].ClientID)
+}
+
+func TestTrackerHeartbeat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	client := NewMockClient()
+	tracker := NewTracker(client, "salt", nil)
+	tracker.Heartbeat(req, 0, nil) // ignore
+	tracker.Heartbeat(req, 15, nil)
+	tracker.Stop()
+	assert.Len(t, client.Events, 1)
+	assert.Equal(t, EventHeartbeat, client.Events[0].Name)
+	assert.Equal(t, 15, client.Events[0].DurationSeconds)
+}
+
 func TestTrackerEventTimeout(t *testing.T) {
 	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
 	req1.Header.Add("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
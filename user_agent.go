@@ -63,6 +63,8 @@ type UserAgent struct {
 
 	// OSVersion is the operating system version number.
 	OSVersion string
+
+	tablet bool
 }
 
 // IsDesktop returns true if the user agent is a desktop device.
@@ -75,6 +77,12 @@ func (ua *UserAgent) IsMobile() bool {
 	return ua.OS == OSAndroid || ua.OS == OSiOS || ua.OS == OSWindowsMobile
 }
 
+// IsTablet returns true if the user agent is a tablet.
+// Tablets are mobile devices too, so IsMobile returns true as well.
+func (ua *UserAgent) IsTablet() bool {
+	return ua.tablet
+}
+
 // ParseUserAgent parses given User-Agent header and returns the extracted information.
 // This just supports major browsers and operating systems, we don't care about browsers and OSes that have no market share,
 // unless you prove us wrong.
@@ -83,9 +91,26 @@ func ParseUserAgent(ua string) UserAgent {
 	userAgent := UserAgent{}
 	userAgent.OS, userAgent.OSVersion = getOS(system)
 	userAgent.Browser, userAgent.BrowserVersion = getBrowser(products, system, userAgent.OS)
+	userAgent.tablet = isTablet(system, products, userAgent.OS)
 	return userAgent
 }
 
+// iPads identify themselves in the system information,
+// while Android tablets are missing the "Mobile" token or explicitly set "Tablet" (Firefox)
+func isTablet(system, products []string, os string) bool {
+	if os == OSiOS {
+		return findPrefix(system, "iPad") != ""
+	} else if os == OSAndroid {
+		if findPrefix(system, "Tablet") != "" {
+			return true
+		}
+
+		return findPrefix(system, "Mobile") == "" && findPrefix(products, "Mobile") == ""
+	}
+
+	return false
+}
+
 func getOS(system []string) (string, string) {
 	os := ""
 	version := ""
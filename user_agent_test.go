@@ -14,6 +14,30 @@ func TestParseUserAgent(t *testing.T) {
 	assert.Equal(t, "79.0", ua.BrowserVersion)
 }
 
+func TestParseUserAgentTablet(t *testing.T) {
+	input := []struct {
+		ua     string
+		tablet bool
+	}{
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:79.0) Gecko/20100101 Firefox/79.0", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 14_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0 Mobile/15E148 Safari/604.1", false},
+		{"Mozilla/5.0 (iPad; CPU OS 6_0 like Mac OS X) AppleWebKit/536.26 (KHTML, like Gecko) Version/6.0 Mobile/10A5355d Safari/8536.25", true},
+		{"Mozilla/5.0 (Linux; Android 10; SM-G973F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/84.0.4147.125 Mobile Safari/537.36", false},
+		{"Mozilla/5.0 (Linux; Android 10; SM-T510) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/84.0.4147.125 Safari/537.36", true},
+		{"Mozilla/5.0 (Android 10; Mobile; rv:68.0) Gecko/68.0 Firefox/79.0", false},
+		{"Mozilla/5.0 (Android 10; Tablet; rv:68.0) Gecko/68.0 Firefox/79.0", true},
+	}
+
+	for _, in := range input {
+		ua := ParseUserAgent(in.ua)
+		assert.Equal(t, in.tablet, ua.IsTablet(), in.ua)
+
+		if in.tablet {
+			assert.True(t, ua.IsMobile())
+		}
+	}
+}
+
 func TestGetBrowser(t *testing.T) {
 	for _, ua := range userAgentsAll {
 		system, products := parseUserAgent(ua.ua)